@@ -18,12 +18,19 @@
 package elasticsearch
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -35,6 +42,12 @@ import (
 
 const (
 	defaultURL = "http://localhost:9200"
+
+	defaultProductCheckHeaderValue = "Elasticsearch"
+
+	maxDeprecationWarnings = 1000
+
+	maxStreamErrorBodySize = 64 << 10
 )
 
 // Version returns the package version as a string.
@@ -44,6 +57,30 @@ const (
 	unknownProduct = "the client noticed that the server is not Elasticsearch and we do not support this unknown product"
 )
 
+// defaultFlavorAPIs lists the URL path parts of the APIs which require the default distribution of Elasticsearch.
+//
+var defaultFlavorAPIs = map[string]bool{
+	"_async_search":         true,
+	"_autoscaling":          true,
+	"_ccr":                  true,
+	"_enrich":               true,
+	"_eql":                  true,
+	"_graph":                true,
+	"_ilm":                  true,
+	"_license":              true,
+	"_migration":            true,
+	"_ml":                   true,
+	"_monitoring":           true,
+	"_rollup":               true,
+	"_searchable_snapshots": true,
+	"_security":             true,
+	"_slm":                  true,
+	"_sql":                  true,
+	"_transform":            true,
+	"_watcher":              true,
+	"_xpack":                true,
+}
+
 // Config represents the client configuration.
 //
 type Config struct {
@@ -55,34 +92,222 @@ type Config struct {
 	APIKey       string // Base64-encoded token for authorization; if set, overrides username/password and service token.
 	ServiceToken string // Service token for authorization; if set, overrides username/password.
 
-	Header http.Header // Global HTTP request header.
+	// Route the read requests and the write requests to separate lists of nodes. The GET, HEAD and OPTIONS requests,
+	// and the POST requests to the search and multi-get endpoints, are read requests; all other requests are write requests.
+	// Use estransport.WithReadRequest or estransport.WithWriteRequest on the request context to override the type.
+	// The requests fall back to Addresses when the list for their type is empty. The lists are not updated by the node discovery.
+	// Default: nil, all the requests use Addresses.
+	ReadAddresses  []string
+	WriteAddresses []string
+
+	// Return an error from NewClient when no address is configured with Addresses, CloudID,
+	// or the ELASTICSEARCH_URL environment variable, instead of connecting to http://localhost:9200.
+	// Default: false.
+	RequireExplicitAddress bool
+
+	Header    http.Header // Global HTTP request header.
+	UserAgent string      // Custom "User-Agent" HTTP header. Default: "go-elasticsearch/<version> (...)".
+
+	// Default media types for the "Content-Type" header of requests with a body, and the "Accept" header
+	// of requests which don't set it explicitly. Only the media types supported by Elasticsearch are valid,
+	// eg. "application/json" or "application/vnd.elasticsearch+json;compatible-with=8". Default: not set.
+	ContentType string
+	Accept      string
 
 	// PEM-encoded certificate authorities.
 	// When set, an empty certificate pool will be created, and the certificates will be appended to it.
 	// The option is only valid when the transport is not specified, or when it's http.Transport.
-	CACert []byte
+	//
+	// CACert can contain multiple PEM blocks, and CACerts allows to pass multiple inputs,
+	// eg. to trust both the old and the new root during a rotation. Every block must be a valid certificate.
+	CACert  []byte
+	CACerts [][]byte
 
 	RetryOnStatus        []int // List of status codes for retry. Default: 502, 503, 504.
 	DisableRetry         bool  // Default: false.
 	EnableRetryOnTimeout bool  // Default: false.
 	MaxRetries           int   // Default: 3.
 
-	CompressRequestBody bool // Default: false.
+	CompressRequestBody        bool // Default: false.
+	CompressRequestBodyMinSize int  // Don't compress request bodies smaller than the size, in bytes; a negative value compresses all bodies. Default: 1KB.
+
+	// Buffer request bodies of unknown length, to send the "Content-Length" header instead of using
+	// the chunked transfer encoding, which some proxies don't support. Bodies buffered for retries
+	// always send the length. Default: false.
+	ForceContentLength bool
+
+	// Send "refresh=false" instead of any other value of the "refresh" parameter of the write requests,
+	// eg. Index, Create, Update, Delete, Bulk, DeleteByQuery, UpdateByQuery or Reindex, so an accidental
+	// "refresh=true" doesn't degrade the indexing performance. The read requests, such as Get, are not affected.
+	// The write requests are recognized by the method and path, regardless of estransport.WithReadRequest.
+	// Use estransport.WithRefresh on the request context to send the parameter as set. Default: false.
+	ForceRefreshFalse bool
+
+	// Send a copy of a GET, HEAD or OPTIONS request to another node when the response doesn't arrive within
+	// the duration, and use the response which arrives first, to reduce the tail latency; the other request
+	// is cancelled. The number of hedged requests, and of the ones which won, is recorded in the metrics.
+	// Default: 0, disabled.
+	HedgeAfter time.Duration
+
+	// Follow the redirect responses, eg. from a proxy in front of the cluster, up to 10 times per request.
+	// The request body is replayed for the 307 and 308 responses only, which requires a buffered body,
+	// eg. with the retries enabled; the 301, 302 and 303 responses are followed for the GET and HEAD requests
+	// without a body only. A redirect which cannot be followed is returned as is, without marking the node
+	// as dead. Default: false, the redirect response is returned.
+	FollowRedirects bool
+
+	// Disable the HTTP keep-alives, and use a new connection for every request. Default: false.
+	// The option is only applied when the transport is not specified. Note that it has a significant
+	// performance cost, since every request has to establish a new TCP connection and TLS session.
+	DisableKeepAlives bool
+
+	// Sizing of the idle connection pool of the default transport; the options are only applied
+	// when the transport is not specified. Default: http.DefaultTransport values.
+	MaxIdleConns        int           // Maximum number of idle connections across all hosts.
+	MaxIdleConnsPerHost int           // Maximum number of idle connections to keep per host.
+	IdleConnTimeout     time.Duration // Maximum amount of time an idle connection remains open.
+
+	// The maximum amount of time to wait for the response headers after the request has been written,
+	// which doesn't include the time to establish the connection, nor the time to read the response body.
+	// It's applied independently of the request context: the request fails at whichever expires first,
+	// so a long context deadline for slow aggregations is still cut short by the timeout.
+	// The option is only applied when the transport is not specified. Default: 0, no timeout.
+	ResponseHeaderTimeout time.Duration
+
+	// Maximum number of connections to a single node, including the active ones; the requests beyond
+	// the limit wait for a connection. The option is only applied when the transport is not specified.
+	// Default: unlimited.
+	MaxConnectionsPerNode int
+
+	// Close the network connection after every n-th request to a node, and open a new one for the next request,
+	// eg. to work around a proxy leaking memory on long-lived connections. The requests are counted per node,
+	// so with more connections to a node, each of them serves about n requests in total before it's closed.
+	// The number of closed connections is recorded in the metrics. Default: 0, unlimited.
+	MaxRequestsPerConnection int
+
+	// The minimum TLS version, eg. tls.VersionTLS12, and the names of the allowed TLS cipher suites,
+	// eg. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"; an unknown name is an error. The cipher suites
+	// don't apply to TLS 1.3, which isn't configurable. The options are only applied when the transport
+	// is not specified. Default: the Go defaults.
+	TLSMinVersion   uint16
+	TLSCipherSuites []string
+
+	// The name used for the TLS server name indication and the verification of the certificates of all nodes,
+	// instead of the host of the node URL, eg. "es.example.com" for a certificate issued for "*.example.com".
+	// It allows to connect to the discovered nodes, which are published by IP address, when the certificates
+	// don't include the IP addresses. The option is only applied when the transport is not specified.
+	TLSServerName string
+
+	// Open the number of connections to every node when initializing the client, by sending concurrent
+	// "HEAD /" requests, so the first requests don't pay the cost of establishing the connections.
+	// Only the connections up to MaxIdleConnsPerHost are kept open. Default: 0, disabled.
+	WarmupConnections int
+
+	// Maximum amount of time to block the client initialization while the connections are warming up;
+	// the warm-up continues in the background afterwards. Default: 1s.
+	WarmupTimeout time.Duration
 
 	DiscoverNodesOnStart  bool          // Discover nodes when initializing the client. Default: false.
 	DiscoverNodesInterval time.Duration // Discover nodes periodically. Default: disabled.
 
+	// Randomize each discovery interval by up to the fraction of DiscoverNodesInterval in either direction,
+	// eg. 0.1 for ±10%, so many clients started together don't discover the nodes at the same time.
+	// It must be lower than 1. Default: 0, no jitter.
+	DiscoverNodesJitter float64
+
+	// Discover nodes when a request fails with a connection error, or with the 502 or 503 response status.
+	// Only one discovery runs at a time. Default: false, the nodes are discovered only on start and periodically,
+	// according to DiscoverNodesOnStart and DiscoverNodesInterval, and failures never trigger the discovery.
+	DiscoverNodesOnFailure bool
+
+	// Use at most the number of discovered nodes, chosen randomly, and re-sampled on every discovery,
+	// eg. with DiscoverNodesInterval, so the client doesn't spread its connections across a large cluster.
+	// Default: 0, all the discovered nodes are used.
+	MaxDiscoveredNodes int
+
+	// The endpoint returning the nodes information in the format of the Nodes Info API. Default: "/_nodes/http".
+	DiscoverNodesEndpoint string
+
+	// Optional function returning the URL for a discovered node, eg. to map the internal address
+	// to an external hostname. The node URL contains the published address; return nil to skip the node.
+	DiscoverNodesURLFunc func(estransport.NodeInfo) *url.URL
+
+	// Optional function called with the discovered nodes, before the connections are updated.
+	// The returned nodes become the active connections, so it can be used to filter or modify them.
+	OnDiscoveredNodes func([]estransport.NodeInfo) []estransport.NodeInfo
+
 	EnableMetrics     bool // Enable the metrics collection.
 	EnableDebugLogger bool // Enable the debug logging.
 
+	// The fraction of requests, between 0 and 1, recorded in the metrics which require a lock: the responses
+	// by status code, the request labels and the connection reuse. The number of requests, failures and bytes
+	// is always exact. The sampled counts are not scaled, so they are approximate, and the accuracy is lower
+	// for rare events and low rates. Default: 1, all requests are recorded.
+	MetricsSampleRate float64
+
 	DisableMetaHeader bool // Disable the additional "X-Elastic-Client-Meta" HTTP header.
 
+	// The expected value of the "X-Elastic-Product" response header, eg. for API-compatible servers
+	// which identify themselves differently. Default: "Elasticsearch".
+	ProductCheckHeaderValue string
+
+	// Return an error for requests to the APIs which require the default distribution of Elasticsearch,
+	// such as security or machine learning, when the server build flavor is "oss". The flavor is detected
+	// from the root endpoint response, eg. a call to ServerInfo, so the check is skipped until it's known.
+	RequireDefaultBuildFlavor bool
+
+	// Collect the unique deprecation warnings from the "Warning" response headers, eg. to find the deprecated
+	// features used by the application before upgrading the cluster; use Client.Warnings to retrieve them.
+	// At most 1000 warnings are kept. Default: false.
+	CollectDeprecationWarnings bool
+
+	// Send the W3C trace context headers ("traceparent", "tracestate") with every request,
+	// using the trace context stored in the request context with estransport.WithTraceContext.
+	PropagateTraceContext bool
+
 	RetryBackoff func(attempt int) time.Duration // Optional backoff duration. Default: nil.
 
+	// The maximum delay honored from the "Retry-After" header of the retried 429 and 503 responses;
+	// the delay replaces the backoff duration, unless it exceeds the maximum, and then the backoff
+	// duration is used. Set to a negative value to ignore the header. Default: 60s.
+	MaxRetryAfter time.Duration
+
+	// Optional function to sign the request, eg. with a custom HMAC signature header.
+	// It's called before every attempt, including retries, after the URL, headers and body are set.
+	RequestSigner func(*http.Request) error
+
+	// Optional rate limiter, called before every attempt, including retries, with the request labels
+	// set with estransport.WithRequestLabels, eg. to implement per-tenant token buckets.
+	RateLimiter estransport.RateLimiter
+
+	// Optional function called with the response after every successful round-trip, including the retried
+	// attempts, before the response is returned, eg. to record the "Warning" headers. It must not read
+	// the body; it can replace the body with a wrapper, or modify the headers. Default: nil.
+	ResponseInterceptor func(*http.Response)
+
+	// Optional function called after every round-trip, including the retried and failed attempts,
+	// with the request and response bodies and the metadata, eg. to persist the requests for auditing.
+	// When set, the request and response bodies are buffered in memory, so they can be passed
+	// to the function and still be sent on retries and read by the caller; the request body is passed
+	// before compression. The function is called synchronously, and must not modify the slices.
+	// Default: nil, disabled.
+	AuditSink func(ctx context.Context, reqBody, resBody []byte, meta estransport.RequestMeta)
+
 	Transport http.RoundTripper    // The HTTP transport object.
 	Logger    estransport.Logger   // The logger object.
 	Selector  estransport.Selector // The selector object.
 
+	// Optional middleware wrapping the transport, eg. to refresh credentials or to trace the requests.
+	// The first middleware is the outermost one. The middleware runs inside the retry loop: it is called
+	// for every attempt, including retries and node discovery, with the request URL and authentication set.
+	// Use estransport.RoundTripperFunc to implement it with a function. Default: nil.
+	RequestMiddleware []func(next http.RoundTripper) http.RoundTripper
+
+	// The strategy for selecting the node for a request, when Selector is not set:
+	// "round-robin", or "least-requests", which chooses the node with the lowest number
+	// of requests in flight. Default: "round-robin".
+	LoadBalancer string
+
 	// Optional constructor function for a custom ConnectionPool. Default: nil.
 	ConnectionPoolFunc func([]*estransport.Connection, estransport.Selector) estransport.ConnectionPool
 }
@@ -93,8 +318,147 @@ type Client struct {
 	*esapi.API // Embeds the API methods
 	Transport  estransport.Interface
 
+	requireDefaultBuildFlavor bool
+
+	productCheckHeaderValue string
+
 	productCheckMu      sync.RWMutex
 	productCheckSuccess bool
+
+	serverInfoMu sync.RWMutex
+	serverInfo   *InfoResponse
+
+	collectDeprecationWarnings bool
+
+	warningsMu   sync.Mutex
+	warnings     []string
+	warningsSeen map[string]bool
+}
+
+// InfoResponse represents the response of the root ("/") endpoint.
+//
+type InfoResponse struct {
+	Name        string `json:"name"`
+	ClusterName string `json:"cluster_name"`
+	ClusterUUID string `json:"cluster_uuid"`
+	Version     struct {
+		Number                           string `json:"number"`
+		BuildFlavor                      string `json:"build_flavor"`
+		BuildType                        string `json:"build_type"`
+		BuildHash                        string `json:"build_hash"`
+		BuildDate                        string `json:"build_date"`
+		BuildSnapshot                    bool   `json:"build_snapshot"`
+		LuceneVersion                    string `json:"lucene_version"`
+		MinimumWireCompatibilityVersion  string `json:"minimum_wire_compatibility_version"`
+		MinimumIndexCompatibilityVersion string `json:"minimum_index_compatibility_version"`
+	} `json:"version"`
+	Tagline string `json:"tagline"`
+}
+
+// Merge returns a copy of the configuration with the non-zero fields of override applied.
+//
+// The fields are merged as follows:
+//
+//   - The non-zero fields of override replace the fields of the configuration; note that a boolean
+//     option enabled in the configuration can't be disabled by override, since false is the zero value.
+//   - The slices, such as Addresses or RetryOnStatus, and the header are replaced, not appended to.
+//   - The credentials are replaced as a group: when override sets any of Username, Password, APIKey
+//     or ServiceToken, the credentials of the configuration are discarded.
+//   - The endpoints are replaced as a group: when override sets Addresses or CloudID,
+//     the endpoints of the configuration are discarded.
+//
+func (cfg Config) Merge(override Config) Config {
+	merged := cfg
+
+	if override.Username != "" || override.Password != "" || override.APIKey != "" || override.ServiceToken != "" {
+		merged.Username, merged.Password, merged.APIKey, merged.ServiceToken = "", "", "", ""
+	}
+	if len(override.Addresses) > 0 || override.CloudID != "" {
+		merged.Addresses, merged.CloudID = nil, ""
+	}
+
+	dst := reflect.ValueOf(&merged).Elem()
+	src := reflect.ValueOf(override)
+	for i := 0; i < src.NumField(); i++ {
+		if !isZeroValue(src.Field(i)) {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+
+	return merged
+}
+
+// isZeroValue returns true when v is the zero value of its type.
+//
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Func, reflect.Interface, reflect.Ptr, reflect.Chan:
+		return v.IsNil()
+	default:
+		return v.Interface() == reflect.Zero(v.Type()).Interface()
+	}
+}
+
+// ConfigForProduction returns a configuration with the defaults suitable for production:
+//
+//   - The requests are retried 3 times, with an exponential backoff starting at 100ms,
+//     also for the "429 Too Many Requests" responses.
+//   - The request bodies larger than 1KB are compressed.
+//   - The response headers must arrive within 60 seconds.
+//   - The addresses must be configured explicitly, instead of defaulting to localhost.
+//   - The metrics are collected.
+//
+// Customize the configuration by setting the fields, or with Merge, eg.:
+//
+//	cfg := elasticsearch.ConfigForProduction().Merge(elasticsearch.Config{CloudID: "..."})
+//
+func ConfigForProduction() Config {
+	return Config{
+		RetryOnStatus: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		MaxRetries:    3,
+		RetryBackoff:  exponentialBackoff(100*time.Millisecond, 5*time.Second),
+
+		CompressRequestBody:        true,
+		CompressRequestBodyMinSize: 1024,
+
+		ResponseHeaderTimeout: 60 * time.Second,
+
+		RequireExplicitAddress: true,
+		EnableMetrics:          true,
+	}
+}
+
+// ConfigForDevelopment returns a configuration with the defaults suitable for development:
+//
+//   - The requests are not retried, so the errors are reported immediately.
+//   - The deprecation warnings are collected, to be retrieved with Client.Warnings.
+//   - The metrics are collected.
+//
+// Customize the configuration by setting the fields, or with Merge.
+//
+func ConfigForDevelopment() Config {
+	return Config{
+		DisableRetry: true,
+
+		CollectDeprecationWarnings: true,
+		EnableMetrics:              true,
+	}
+}
+
+// exponentialBackoff returns a backoff function doubling the delay with every attempt,
+// starting at min, and capped at max.
+//
+func exponentialBackoff(min, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := min
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
 }
 
 // NewDefaultClient creates a new client with default options.
@@ -120,6 +484,9 @@ func NewDefaultClient() (*Client, error) {
 //
 // It's an error to set both cfg.Addresses and cfg.CloudID.
 //
+// When no address is configured, the client connects to http://localhost:9200,
+// unless cfg.RequireExplicitAddress is set, which makes it an error.
+//
 func NewClient(cfg Config) (*Client, error) {
 	var addrs []string
 
@@ -149,10 +516,22 @@ func NewClient(cfg Config) (*Client, error) {
 	}
 
 	if len(urls) == 0 {
+		if cfg.RequireExplicitAddress {
+			return nil, errors.New("cannot create client: no address configured with Addresses, CloudID or ELASTICSEARCH_URL")
+		}
 		u, _ := url.Parse(defaultURL) // errcheck exclude
 		urls = append(urls, u)
 	}
 
+	readURLs, err := addrsToURLs(cfg.ReadAddresses)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create client: %s", err)
+	}
+	writeURLs, err := addrsToURLs(cfg.WriteAddresses)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create client: %s", err)
+	}
+
 	// TODO(karmi): Refactor
 	if urls[0].User != nil {
 		cfg.Username = urls[0].User.Username()
@@ -162,39 +541,95 @@ func NewClient(cfg Config) (*Client, error) {
 
 	tp, err := estransport.New(estransport.Config{
 		URLs:         urls,
+		ReadURLs:     readURLs,
+		WriteURLs:    writeURLs,
 		Username:     cfg.Username,
 		Password:     cfg.Password,
 		APIKey:       cfg.APIKey,
 		ServiceToken: cfg.ServiceToken,
 
-		Header: cfg.Header,
-		CACert: cfg.CACert,
+		Header:    cfg.Header,
+		UserAgent: cfg.UserAgent,
+		CACert:    cfg.CACert,
+		CACerts:   cfg.CACerts,
+
+		ContentType: cfg.ContentType,
+		Accept:      cfg.Accept,
 
 		RetryOnStatus:        cfg.RetryOnStatus,
 		DisableRetry:         cfg.DisableRetry,
 		EnableRetryOnTimeout: cfg.EnableRetryOnTimeout,
 		MaxRetries:           cfg.MaxRetries,
 		RetryBackoff:         cfg.RetryBackoff,
+		MaxRetryAfter:        cfg.MaxRetryAfter,
+
+		RequestSigner:       cfg.RequestSigner,
+		RateLimiter:         cfg.RateLimiter,
+		ResponseInterceptor: cfg.ResponseInterceptor,
+		AuditSink:           cfg.AuditSink,
+
+		CompressRequestBody:        cfg.CompressRequestBody,
+		CompressRequestBodyMinSize: cfg.CompressRequestBodyMinSize,
+
+		ForceContentLength: cfg.ForceContentLength,
+		ForceRefreshFalse:  cfg.ForceRefreshFalse,
+
+		HedgeAfter: cfg.HedgeAfter,
+
+		FollowRedirects: cfg.FollowRedirects,
+
+		DisableKeepAlives:   cfg.DisableKeepAlives,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
 
-		CompressRequestBody: cfg.CompressRequestBody,
+		MaxConnectionsPerNode:    cfg.MaxConnectionsPerNode,
+		MaxRequestsPerConnection: cfg.MaxRequestsPerConnection,
+
+		TLSMinVersion:   cfg.TLSMinVersion,
+		TLSCipherSuites: cfg.TLSCipherSuites,
+		TLSServerName:   cfg.TLSServerName,
+
+		WarmupConnections: cfg.WarmupConnections,
+		WarmupTimeout:     cfg.WarmupTimeout,
 
 		EnableMetrics:     cfg.EnableMetrics,
+		MetricsSampleRate: cfg.MetricsSampleRate,
 		EnableDebugLogger: cfg.EnableDebugLogger,
 
 		DisableMetaHeader: cfg.DisableMetaHeader,
 
-		DiscoverNodesInterval: cfg.DiscoverNodesInterval,
+		PropagateTraceContext: cfg.PropagateTraceContext,
+
+		DiscoverNodesInterval:  cfg.DiscoverNodesInterval,
+		DiscoverNodesJitter:    cfg.DiscoverNodesJitter,
+		DiscoverNodesOnFailure: cfg.DiscoverNodesOnFailure,
+		MaxDiscoveredNodes:     cfg.MaxDiscoveredNodes,
+		DiscoverNodesEndpoint:  cfg.DiscoverNodesEndpoint,
+		DiscoverNodesURLFunc:   cfg.DiscoverNodesURLFunc,
+		OnDiscoveredNodes:      cfg.OnDiscoveredNodes,
 
 		Transport:          cfg.Transport,
+		RequestMiddleware:  cfg.RequestMiddleware,
 		Logger:             cfg.Logger,
 		Selector:           cfg.Selector,
+		LoadBalancer:       cfg.LoadBalancer,
 		ConnectionPoolFunc: cfg.ConnectionPoolFunc,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating transport: %s", err)
 	}
 
-	client := &Client{Transport: tp}
+	client := &Client{
+		Transport: tp,
+
+		requireDefaultBuildFlavor: cfg.RequireDefaultBuildFlavor,
+		productCheckHeaderValue:   cfg.ProductCheckHeaderValue,
+
+		collectDeprecationWarnings: cfg.CollectDeprecationWarnings,
+	}
 	client.API = esapi.New(client)
 
 	if cfg.DiscoverNodesOnStart {
@@ -207,20 +642,397 @@ func NewClient(cfg Config) (*Client, error) {
 // Perform delegates to Transport to execute a request and return a response.
 //
 func (c *Client) Perform(req *http.Request) (*http.Response, error) {
+	return c.perform(req, c.Transport.Perform)
+}
+
+// PerformWithResult delegates to Transport to execute a request, and returns the response
+// with the number of attempts, the total duration and the last error.
+//
+func (c *Client) PerformWithResult(req *http.Request) (estransport.PerformResult, error) {
+	rp, ok := c.Transport.(estransport.ResultPerformer)
+	if !ok {
+		return estransport.PerformResult{}, errors.New("transport is missing method PerformWithResult()")
+	}
+
+	var result estransport.PerformResult
+	res, err := c.perform(req, func(req *http.Request) (*http.Response, error) {
+		var err error
+		result, err = rp.PerformWithResult(req)
+		return result.Response, err
+	})
+	result.Response = res
+
+	return result, err
+}
+
+// perform executes the request with the transport function, and checks the response.
+//
+func (c *Client) perform(req *http.Request, performFunc func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	// Record whether the request targets the root endpoint, before the transport updates the URL.
+	isInfoRequest := req.Method == http.MethodGet && req.URL.Path == "/"
+
+	if c.requireDefaultBuildFlavor {
+		if err := c.checkBuildFlavor(req.URL.Path); err != nil {
+			return nil, err
+		}
+	}
+
 	// Retrieve the original request.
-	res, err := c.Transport.Perform(req)
+	res, err := performFunc(req)
 
 	// ResponseCheck path continues, we run the header check on the first answer from ES.
 	if err == nil {
-		checkHeader := func() error { return genuineCheckHeader(res.Header) }
+		checkHeader := func() error { return genuineCheckHeader(res.Header, c.productCheckHeaderValue) }
 		if err := c.doProductCheck(checkHeader); err != nil {
-			res.Body.Close()
+			esapi.DrainResponse(&esapi.Response{StatusCode: res.StatusCode, Header: res.Header, Body: res.Body})
 			return nil, err
 		}
+
+		if isInfoRequest {
+			c.captureServerInfo(res)
+		}
+
+		if c.collectDeprecationWarnings {
+			c.collectWarnings(res.Header)
+		}
 	}
 	return res, err
 }
 
+// Do executes the raw request, sending the body verbatim with its content type,
+// eg. to send a CSV or plain-text body, or to call an endpoint without an API method.
+//
+// The request goes through the client, with the same authentication, headers and retries
+// as the API methods; the caller is responsible for closing the response body.
+//
+func (c *Client) Do(ctx context.Context, req esapi.RawRequest) (*esapi.Response, error) {
+	return req.Do(ctx, c)
+}
+
+// DoStream executes the request, and copies the response body to w, eg. a file or an HTTP response writer,
+// without buffering it in memory; it returns the number of bytes written.
+//
+// The body is sent with the "application/json" content type, when not nil. When the response status
+// indicates failure, nothing is written to w, and the returned error includes the status and the beginning
+// of the response body.
+//
+func (c *Client) DoStream(ctx context.Context, method, path string, body io.Reader, w io.Writer) (int64, error) {
+	req, err := http.NewRequest(method, path, body)
+	if err != nil {
+		return 0, fmt.Errorf("cannot create request: %s", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+
+	res, err := c.Perform(req)
+	if err != nil {
+		return 0, err
+	}
+	if res.Body == nil {
+		res.Body = http.NoBody
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxStreamErrorBodySize))
+		return 0, fmt.Errorf("[%d %s] %s", res.StatusCode, http.StatusText(res.StatusCode), bytes.TrimSpace(b))
+	}
+
+	n, err := io.Copy(w, res.Body)
+	if err != nil {
+		return n, fmt.Errorf("cannot copy response body: %s", err)
+	}
+	return n, nil
+}
+
+// ServerVersion returns the version of the Elasticsearch server,
+// and whether it has been detected already.
+//
+// The version is populated lazily, from the first successful response
+// to the root ("/") endpoint, eg. a call to the Info API. The product check
+// relies on the response headers only, and doesn't issue a request to the root
+// endpoint, so the version is not known until the client calls it.
+//
+func (c *Client) ServerVersion() (string, bool) {
+	c.serverInfoMu.RLock()
+	defer c.serverInfoMu.RUnlock()
+	if c.serverInfo == nil {
+		return "", false
+	}
+	return c.serverInfo.Version.Number, true
+}
+
+// ServerBuildFlavor returns the build flavor of the Elasticsearch server, eg. "default" or "oss",
+// and whether it has been detected already.
+//
+// The flavor is populated lazily, together with the server version; see ServerVersion.
+//
+func (c *Client) ServerBuildFlavor() (string, bool) {
+	c.serverInfoMu.RLock()
+	defer c.serverInfoMu.RUnlock()
+	if c.serverInfo == nil || c.serverInfo.Version.BuildFlavor == "" {
+		return "", false
+	}
+	return c.serverInfo.Version.BuildFlavor, true
+}
+
+// ServerInfo returns the information about the Elasticsearch server from the root ("/") endpoint.
+//
+// The method is not called Info, so it doesn't shadow the Info API method.
+//
+func (c *Client) ServerInfo(ctx context.Context) (*InfoResponse, error) {
+	res, err := c.API.Info(c.API.Info.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get server info: %s", err)
+	}
+	defer esapi.DrainResponse(res)
+
+	if res.IsError() {
+		return nil, fmt.Errorf("cannot get server info: %s", res.Status())
+	}
+
+	var info InfoResponse
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("cannot get server info: error parsing response body: %s", err)
+	}
+
+	return &info, nil
+}
+
+// ValidateOptions configures the Validate method.
+//
+type ValidateOptions struct {
+	MinVersion string // The minimum version of Elasticsearch, eg. "8.1.0". Default: not checked.
+}
+
+// ValidationReport represents the result of the Validate method.
+//
+type ValidationReport struct {
+	Reachable     bool   // The server responded to the request.
+	Authenticated bool   // The server accepted the credentials.
+	ProductOK     bool   // The server is a genuine Elasticsearch.
+	Version       string // The version of Elasticsearch, when the server info is available.
+
+	Errors []error // The problems found; empty when the client is ready to use.
+}
+
+// OK returns true when no problem has been found.
+//
+func (r ValidationReport) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// Validate checks that the cluster is reachable, that the credentials are valid, that the server
+// is a genuine Elasticsearch, and that its version is at least the minimum version, when set,
+// eg. to fail fast during the application startup.
+//
+// It sends a single request to the root ("/") endpoint, bypassing the product check of Perform,
+// so the report includes the findings of every check; the problems are reported in the Errors.
+//
+func (c *Client) Validate(ctx context.Context, opts ValidateOptions) ValidationReport {
+	var report ValidationReport
+
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("cannot create request: %s", err))
+		return report
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+
+	res, err := c.Transport.Perform(req)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("cluster not reachable: %s", err))
+		return report
+	}
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	report.Reachable = true
+	report.Authenticated = res.StatusCode != http.StatusUnauthorized
+
+	if err := genuineCheckHeader(res.Header, c.productCheckHeaderValue); err != nil {
+		report.Errors = append(report.Errors, err)
+	} else {
+		report.ProductOK = true
+	}
+
+	switch {
+	case res.StatusCode == http.StatusUnauthorized:
+		report.Errors = append(report.Errors, fmt.Errorf("invalid credentials: %s", res.Status))
+		return report
+	case res.StatusCode > 299:
+		report.Errors = append(report.Errors, fmt.Errorf("cannot get server info: %s", res.Status))
+		return report
+	}
+
+	var info InfoResponse
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("cannot get server info: error parsing response body: %s", err))
+		return report
+	}
+	report.Version = info.Version.Number
+
+	if opts.MinVersion != "" && compareVersions(report.Version, opts.MinVersion) < 0 {
+		report.Errors = append(report.Errors, fmt.Errorf("unsupported version %q, the minimum version is %q", report.Version, opts.MinVersion))
+	}
+
+	return report
+}
+
+// compareVersions compares the numeric parts of the versions, eg. "8.1.0" and "8.10.0-SNAPSHOT",
+// and returns -1, 0 or 1, when a is lower than, equal to, or greater than b; the suffixes are ignored.
+//
+func compareVersions(a, b string) int {
+	parse := func(v string) []int {
+		if i := strings.IndexAny(v, "-+"); i > -1 {
+			v = v[:i]
+		}
+		var out []int
+		for _, p := range strings.Split(v, ".") {
+			n, _ := strconv.Atoi(p)
+			out = append(out, n)
+		}
+		return out
+	}
+
+	va, vb := parse(a), parse(b)
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkBuildFlavor returns an error when the path targets an API which requires the default distribution
+// of Elasticsearch, and the server build flavor is known to be "oss".
+//
+func (c *Client) checkBuildFlavor(path string) error {
+	flavor, ok := c.ServerBuildFlavor()
+	if !ok || flavor != "oss" {
+		return nil
+	}
+
+	for _, part := range strings.Split(path, "/") {
+		if defaultFlavorAPIs[part] {
+			return fmt.Errorf("the %s API requires the default distribution of Elasticsearch, the server build flavor is %q", part, flavor)
+		}
+	}
+
+	return nil
+}
+
+// captureServerInfo stores the server information from the root endpoint response,
+// unless it has been stored already. The response body is restored for the caller.
+//
+func (c *Client) captureServerInfo(res *http.Response) {
+	if res.StatusCode > 299 || res.Body == nil || res.Body == http.NoBody {
+		return
+	}
+
+	if _, ok := c.ServerVersion(); ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(&buf)
+	if err != nil {
+		return
+	}
+
+	var info InfoResponse
+	if err := json.Unmarshal(buf.Bytes(), &info); err != nil || info.Version.Number == "" {
+		return
+	}
+
+	c.serverInfoMu.Lock()
+	c.serverInfo = &info
+	c.serverInfoMu.Unlock()
+}
+
+// Warnings returns the unique deprecation warnings received from the server, in the order of arrival.
+//
+// The warnings are collected only when the CollectDeprecationWarnings option is enabled.
+//
+func (c *Client) Warnings() []string {
+	c.warningsMu.Lock()
+	defer c.warningsMu.Unlock()
+
+	out := make([]string, len(c.warnings))
+	copy(out, c.warnings)
+	return out
+}
+
+// collectWarnings stores the texts of the "Warning" headers which haven't been seen yet.
+//
+func (c *Client) collectWarnings(header http.Header) {
+	values := header.Values("Warning")
+	if len(values) == 0 {
+		return
+	}
+
+	c.warningsMu.Lock()
+	defer c.warningsMu.Unlock()
+
+	if c.warningsSeen == nil {
+		c.warningsSeen = make(map[string]bool)
+	}
+
+	for _, v := range values {
+		w := parseWarningHeader(v)
+		if c.warningsSeen[w] || len(c.warnings) >= maxDeprecationWarnings {
+			continue
+		}
+		c.warningsSeen[w] = true
+		c.warnings = append(c.warnings, w)
+	}
+}
+
+// parseWarningHeader returns the text of the warning header value, in the format of RFC 7234,
+// eg. `299 Elasticsearch-8.0.0 "[types removal] ..."`, or the value itself when it cannot be parsed.
+//
+func parseWarningHeader(v string) string {
+	start := strings.IndexByte(v, '"')
+	if start < 0 {
+		return v
+	}
+
+	var b strings.Builder
+	for i := start + 1; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			if i+1 < len(v) {
+				i++
+				b.WriteByte(v[i])
+			}
+		case '"':
+			return b.String()
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	return v
+}
+
 // doProductCheck calls f if there as not been a prior successful call to doProductCheck,
 // returning nil otherwise.
 func (c *Client) doProductCheck(f func() error) error {
@@ -248,14 +1060,15 @@ func (c *Client) doProductCheck(f func() error) error {
 	return nil
 }
 
-// genuineCheckHeader validates the presence of the X-Elastic-Product header
+// genuineCheckHeader validates the presence of the X-Elastic-Product header with the expected value
 //
-func genuineCheckHeader(header http.Header) error {
-	/*
-		if header.Get("X-Elastic-Product") != "Elasticsearch" {
-			return errors.New(unknownProduct)
-		}
-	 */
+func genuineCheckHeader(header http.Header, expected string) error {
+	if expected == "" {
+		expected = defaultProductCheckHeaderValue
+	}
+	if header.Get("X-Elastic-Product") != expected {
+		return errors.New(unknownProduct)
+	}
 	return nil
 }
 
@@ -277,6 +1090,17 @@ func (c *Client) DiscoverNodes() error {
 	return errors.New("transport is missing method DiscoverNodes()")
 }
 
+// RefreshConnections drops the client connections and discovers the nodes immediately.
+//
+// It is safe to call while requests are in flight.
+//
+func (c *Client) RefreshConnections(ctx context.Context) error {
+	if rt, ok := c.Transport.(estransport.Refreshable); ok {
+		return rt.RefreshConnections(ctx)
+	}
+	return errors.New("transport is missing method RefreshConnections()")
+}
+
 // addrsFromEnvironment returns a list of addresses by splitting
 // the ELASTICSEARCH_URL environment variable with comma, or an empty list.
 //
@@ -315,18 +1139,31 @@ func addrFromCloudID(input string) (string, error) {
 	var scheme = "https://"
 
 	values := strings.Split(input, ":")
-	if len(values) != 2 {
-		return "", fmt.Errorf("unexpected format: %q", input)
+	if len(values) != 2 || values[0] == "" {
+		return "", fmt.Errorf("unexpected format: invalid name prefix, want <name>:<base64 value>, got %q", truncateCloudID(input))
 	}
 	data, err := base64.StdEncoding.DecodeString(values[1])
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("cannot decode base64 value %q: %s", truncateCloudID(values[1]), err)
 	}
 	parts := strings.Split(string(data), "$")
 
-	if len(parts) < 2 {
-		return "", fmt.Errorf("invalid encoded value: %s", parts)
+	if parts[0] == "" {
+		return "", fmt.Errorf("invalid encoded value: missing host segment in %q", truncateCloudID(string(data)))
+	}
+	if len(parts) < 2 || parts[1] == "" {
+		return "", fmt.Errorf("invalid encoded value: missing es UUID segment in %q", truncateCloudID(string(data)))
 	}
 
 	return fmt.Sprintf("%s%s.%s", scheme, parts[1], parts[0]), nil
 }
+
+// truncateCloudID shortens the CloudID part for the error messages.
+//
+func truncateCloudID(s string) string {
+	const max = 64
+	if len(s) > max {
+		return s[:max] + "..."
+	}
+	return s
+}
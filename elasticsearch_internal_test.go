@@ -20,8 +20,10 @@
 package elasticsearch
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -31,7 +33,9 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
 	"github.com/Tritura/go-elasticsearch/v8/estransport"
 )
 
@@ -84,6 +88,35 @@ func TestClientConfiguration(t *testing.T) {
 		}
 	})
 
+	t.Run("With RequireExplicitAddress", func(t *testing.T) {
+		_, err := NewClient(Config{RequireExplicitAddress: true})
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+		if !strings.Contains(err.Error(), "no address configured") {
+			t.Errorf("Unexpected error: %s", err)
+		}
+
+		c, err := NewClient(Config{RequireExplicitAddress: true, Addresses: []string{"http://localhost:8080"}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if u := c.Transport.(*estransport.Client).URLs()[0].String(); u != "http://localhost:8080" {
+			t.Errorf("Unexpected URL, want=http://localhost:8080, got=%s", u)
+		}
+
+		os.Setenv("ELASTICSEARCH_URL", "http://example.com")
+		defer func() { os.Setenv("ELASTICSEARCH_URL", "") }()
+
+		c, err = NewClient(Config{RequireExplicitAddress: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if u := c.Transport.(*estransport.Client).URLs()[0].String(); u != "http://example.com" {
+			t.Errorf("Unexpected URL, want=http://example.com, got=%s", u)
+		}
+	})
+
 	t.Run("With URL from Addresses", func(t *testing.T) {
 		c, err := NewClient(Config{Addresses: []string{"http://localhost:8080//"}})
 		if err != nil {
@@ -359,11 +392,51 @@ func TestCloudID(t *testing.T) {
 		if err == nil {
 			t.Errorf("Expected error for input %q, got %v", input, err)
 		}
-		match, _ := regexp.MatchString("illegal base64 data", err.Error())
+		match, _ := regexp.MatchString(`cannot decode base64 value "xxxxx": illegal base64 data`, err.Error())
 		if !match {
 			t.Errorf("Unexpected error string: %s", err)
 		}
 	})
+
+	t.Run("Invalid segments", func(t *testing.T) {
+		var testdata = []struct {
+			in  string
+			err string
+		}{
+			{
+				in:  ":" + base64.StdEncoding.EncodeToString([]byte("host$es_uuid")),
+				err: "invalid name prefix",
+			},
+			{
+				in:  "name:" + base64.StdEncoding.EncodeToString([]byte("$es_uuid$kibana_uuid")),
+				err: `missing host segment in "\$es_uuid\$kibana_uuid"`,
+			},
+			{
+				in:  "name:" + base64.StdEncoding.EncodeToString([]byte("host")),
+				err: `missing es UUID segment in "host"`,
+			},
+			{
+				in:  "name:" + base64.StdEncoding.EncodeToString([]byte("host$$kibana_uuid")),
+				err: `missing es UUID segment in "host\$\$kibana_uuid"`,
+			},
+			{
+				in:  "name:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 100))),
+				err: `missing es UUID segment in "x{64}\.\.\."`,
+			},
+		}
+
+		for _, tt := range testdata {
+			_, err := addrFromCloudID(tt.in)
+			if err == nil {
+				t.Errorf("Expected error for input %q, got %v", tt.in, err)
+				continue
+			}
+			match, _ := regexp.MatchString(tt.err, err.Error())
+			if !match {
+				t.Errorf("Unexpected error string: %s", err)
+			}
+		}
+	})
 }
 
 func TestVersion(t *testing.T) {
@@ -468,4 +541,672 @@ func TestProductCheckError(t *testing.T) {
 	if !c.productCheckSuccess {
 		t.Fatalf("product check should be valid, got : %v", c.productCheckSuccess)
 	}
-}
\ No newline at end of file
+}
+
+func TestProductCheckHeaderValue(t *testing.T) {
+	newTransport := func(value string) *mockTransp {
+		return &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{value}},
+					Body:       ioutil.NopCloser(strings.NewReader("{}")),
+				}, nil
+			},
+		}
+	}
+
+	t.Run("Default value", func(t *testing.T) {
+		c, _ := NewClient(Config{Transport: newTransport("Elasticsearch-Compatible")})
+		if _, err := c.Cat.Indices(); err == nil || err.Error() != unknownProduct {
+			t.Errorf("Expected unknown product error, got: %v", err)
+		}
+	})
+
+	t.Run("Custom value", func(t *testing.T) {
+		c, _ := NewClient(Config{
+			Transport:               newTransport("Elasticsearch-Compatible"),
+			ProductCheckHeaderValue: "Elasticsearch-Compatible",
+		})
+		if _, err := c.Cat.Indices(); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Custom value with genuine server", func(t *testing.T) {
+		c, _ := NewClient(Config{
+			Transport:               newTransport("Elasticsearch"),
+			ProductCheckHeaderValue: "Elasticsearch-Compatible",
+		})
+		if _, err := c.Cat.Indices(); err == nil {
+			t.Errorf("Expected unknown product error")
+		}
+	})
+}
+
+func TestServerVersion(t *testing.T) {
+	c, _ := NewClient(Config{Transport: &mockTransp{}})
+
+	if v, ok := c.ServerVersion(); ok {
+		t.Fatalf("Unexpected server version before first request: %q", v)
+	}
+
+	res, err := c.Info()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	if !strings.Contains(string(body), "You Know, for Search") {
+		t.Errorf("Expected the response body to be preserved, got: %s", body)
+	}
+
+	v, ok := c.ServerVersion()
+	if !ok {
+		t.Fatalf("Expected the server version to be detected")
+	}
+	if v != "8.0.0-SNAPSHOT" {
+		t.Errorf("Unexpected server version: %q", v)
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		c, _ := NewClient(Config{Transport: &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body: ioutil.NopCloser(strings.NewReader(`{
+						"name" : "es1",
+						"cluster_name" : "elasticsearch",
+						"cluster_uuid" : "abc123",
+						"version" : {
+							"number" : "8.0.0",
+							"build_flavor" : "default",
+							"build_type" : "docker"
+						},
+						"tagline" : "You Know, for Search"
+					}`)),
+				}, nil
+			},
+		}})
+
+		info, err := c.ServerInfo(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if info.Name != "es1" || info.ClusterName != "elasticsearch" || info.ClusterUUID != "abc123" {
+			t.Errorf("Unexpected info: %+v", info)
+		}
+		if info.Version.Number != "8.0.0" || info.Version.BuildFlavor != "default" || info.Version.BuildType != "docker" {
+			t.Errorf("Unexpected version: %+v", info.Version)
+		}
+		if info.Tagline != "You Know, for Search" {
+			t.Errorf("Unexpected tagline: %s", info.Tagline)
+		}
+
+		if v, _ := c.ServerVersion(); v != "8.0.0" {
+			t.Errorf("Unexpected server version: %q", v)
+		}
+	})
+
+	t.Run("Error response", func(t *testing.T) {
+		c, _ := NewClient(Config{Transport: &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusUnauthorized,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+				}, nil
+			},
+		}})
+
+		if _, err := c.ServerInfo(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("Expected error, got: %v", err)
+		}
+	})
+}
+
+func TestRequireDefaultBuildFlavor(t *testing.T) {
+	newClient := func(flavor string, require bool) (*Client, *[]string) {
+		var paths []string
+		c, _ := NewClient(Config{
+			RequireDefaultBuildFlavor: require,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					paths = append(paths, req.URL.Path)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+						Body:       ioutil.NopCloser(strings.NewReader(`{"version":{"number":"7.10.0","build_flavor":"` + flavor + `"}}`)),
+					}, nil
+				},
+			},
+		})
+		return c, &paths
+	}
+
+	t.Run("Reject on oss", func(t *testing.T) {
+		c, paths := newClient("oss", true)
+
+		if _, err := c.Security.GetUser(); err != nil {
+			t.Fatalf("Expected the request to pass before the flavor is known, got: %s", err)
+		}
+
+		c.ServerInfo(context.Background())
+		if v, _ := c.ServerBuildFlavor(); v != "oss" {
+			t.Fatalf("Unexpected build flavor: %q", v)
+		}
+
+		_, err := c.Security.GetUser()
+		if err == nil || !strings.Contains(err.Error(), "_security API requires the default distribution") {
+			t.Errorf("Expected error, got: %v", err)
+		}
+		if _, err := c.ML.GetJobs(); err == nil {
+			t.Errorf("Expected error for ML API")
+		}
+		if _, err := c.Search(c.Search.WithIndex("test")); err != nil {
+			t.Errorf("Unexpected error for Search API: %s", err)
+		}
+
+		if len(*paths) != 3 {
+			t.Errorf("Unexpected requests: %s", *paths)
+		}
+	})
+
+	t.Run("Allow on default", func(t *testing.T) {
+		c, _ := newClient("default", true)
+		c.ServerInfo(context.Background())
+
+		if _, err := c.Security.GetUser(); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		c, _ := newClient("oss", false)
+		c.ServerInfo(context.Background())
+
+		if _, err := c.Security.GetUser(); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+}
+
+func TestCollectDeprecationWarnings(t *testing.T) {
+	newTransport := func(warnings ...string) *mockTransp {
+		return &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header: http.Header{
+						"X-Elastic-Product": []string{"Elasticsearch"},
+						"Warning":           warnings,
+					},
+					Body: ioutil.NopCloser(strings.NewReader("{}")),
+				}, nil
+			},
+		}
+	}
+
+	t.Run("Collect unique warnings", func(t *testing.T) {
+		c, _ := NewClient(Config{
+			Transport: newTransport(
+				`299 Elasticsearch-8.0.0-abc "[types removal] Specifying types is deprecated."`,
+				`299 Elasticsearch-8.0.0-abc "Field \"foo\" is deprecated" "Mon, 01 Jan 2020 00:00:00 GMT"`,
+				`invalid`,
+			),
+			CollectDeprecationWarnings: true,
+		})
+
+		for i := 0; i < 2; i++ {
+			if _, err := c.Cat.Indices(); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+		}
+
+		expected := []string{
+			"[types removal] Specifying types is deprecated.",
+			`Field "foo" is deprecated`,
+			"invalid",
+		}
+		if !reflect.DeepEqual(c.Warnings(), expected) {
+			t.Errorf("Unexpected warnings:\nwant: %q\ngot:  %q", expected, c.Warnings())
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		c, _ := NewClient(Config{Transport: newTransport(`299 Elasticsearch-8.0.0-abc "Deprecated"`)})
+
+		if _, err := c.Cat.Indices(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(c.Warnings()) != 0 {
+			t.Errorf("Unexpected warnings: %q", c.Warnings())
+		}
+	})
+}
+
+func TestConfigMerge(t *testing.T) {
+	base := Config{
+		Addresses:     []string{"http://foo:9200"},
+		Username:      "foo",
+		Password:      "bar",
+		RetryOnStatus: []int{502, 503},
+		MaxRetries:    5,
+		EnableMetrics: true,
+		Header:        http.Header{"X-Foo": []string{"bar"}},
+	}
+
+	t.Run("Non-zero fields", func(t *testing.T) {
+		cfg := base.Merge(Config{MaxRetries: 1, RetryOnStatus: []int{429}, DisableRetry: true})
+
+		if cfg.MaxRetries != 1 || !cfg.DisableRetry || !cfg.EnableMetrics {
+			t.Errorf("Unexpected config: %+v", cfg)
+		}
+		if len(cfg.RetryOnStatus) != 1 || cfg.RetryOnStatus[0] != 429 {
+			t.Errorf("Unexpected RetryOnStatus: %v", cfg.RetryOnStatus)
+		}
+		if cfg.Username != "foo" || cfg.Password != "bar" || cfg.Addresses[0] != "http://foo:9200" {
+			t.Errorf("Unexpected config: %+v", cfg)
+		}
+		if cfg.Header.Get("X-Foo") != "bar" {
+			t.Errorf("Unexpected header: %v", cfg.Header)
+		}
+		if base.MaxRetries != 5 {
+			t.Errorf("Unexpected change of the base config: %+v", base)
+		}
+	})
+
+	t.Run("Credentials", func(t *testing.T) {
+		cfg := base.Merge(Config{APIKey: "Zm9vYmFy"})
+
+		if cfg.APIKey != "Zm9vYmFy" || cfg.Username != "" || cfg.Password != "" {
+			t.Errorf("Unexpected credentials: %+v", cfg)
+		}
+	})
+
+	t.Run("Endpoints", func(t *testing.T) {
+		cfg := base.Merge(Config{CloudID: "foo:YmFyLmNsb3VkLmVzLmlvJGFiYzEyMyRkZWY0NTY="})
+
+		if cfg.Addresses != nil || cfg.CloudID == "" {
+			t.Errorf("Unexpected endpoints: %+v", cfg)
+		}
+		if _, err := NewClient(cfg); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Functions", func(t *testing.T) {
+		var backoffCalled bool
+		cfg := base.Merge(Config{RetryBackoff: func(int) time.Duration { backoffCalled = true; return 0 }})
+
+		cfg.RetryBackoff(1)
+		if !backoffCalled {
+			t.Errorf("Expected the override function to be set")
+		}
+	})
+}
+
+func TestClientPerformWithResult(t *testing.T) {
+	var i int
+	c, _ := NewClient(Config{
+		RetryBackoff: func(int) time.Duration { return time.Millisecond },
+		Transport: &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				i++
+				status := http.StatusOK
+				if i == 1 {
+					status = http.StatusServiceUnavailable
+				}
+				return &http.Response{
+					StatusCode: status,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+				}, nil
+			},
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "/_cluster/health", nil)
+	result, err := c.PerformWithResult(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if result.Response == nil || result.Response.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected response: %+v", result.Response)
+	}
+	if result.Attempts != 2 {
+		t.Errorf("Unexpected attempts: want=2, got=%d", result.Attempts)
+	}
+
+	c, _ = NewClient(Config{Transport: &mockTransp{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+		},
+	}})
+
+	req, _ = http.NewRequest("GET", "/_cluster/health", nil)
+	result, err = c.PerformWithResult(req)
+	if err == nil {
+		t.Fatalf("Expected product check error, got nil")
+	}
+	if result.Response != nil || result.Attempts != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestConfigPresets(t *testing.T) {
+	t.Run("Production", func(t *testing.T) {
+		cfg := ConfigForProduction()
+
+		if cfg.DisableRetry || cfg.MaxRetries != 3 || cfg.RetryBackoff == nil {
+			t.Errorf("Unexpected retry options: %+v", cfg)
+		}
+		if !cfg.CompressRequestBody || !cfg.RequireExplicitAddress || !cfg.EnableMetrics {
+			t.Errorf("Unexpected options: %+v", cfg)
+		}
+
+		for attempt, d := range []time.Duration{100, 200, 400, 800, 1600, 3200, 5000, 5000} {
+			if got := cfg.RetryBackoff(attempt + 1); got != d*time.Millisecond {
+				t.Errorf("Unexpected backoff for attempt %d: want=%s, got=%s", attempt+1, d*time.Millisecond, got)
+			}
+		}
+
+		if _, err := NewClient(cfg); err == nil {
+			t.Errorf("Expected error for missing address")
+		}
+
+		c, err := NewClient(cfg.Merge(Config{Addresses: []string{"http://foo:9200"}, MaxRetries: 5}))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if _, err := c.Metrics(); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Development", func(t *testing.T) {
+		cfg := ConfigForDevelopment().Merge(Config{Addresses: []string{"http://foo:9200"}})
+
+		if !cfg.DisableRetry || !cfg.CollectDeprecationWarnings || cfg.Addresses[0] != "http://foo:9200" {
+			t.Errorf("Unexpected options: %+v", cfg)
+		}
+		if _, err := NewClient(cfg); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+}
+
+func TestClientValidate(t *testing.T) {
+	newClient := func(status int, header http.Header, body string, err error) *Client {
+		c, _ := NewClient(Config{
+			DisableRetry: true,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					if err != nil {
+						return nil, err
+					}
+					return &http.Response{
+						StatusCode: status,
+						Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+						Header:     header,
+						Body:       ioutil.NopCloser(strings.NewReader(body)),
+					}, nil
+				},
+			},
+		})
+		return c
+	}
+	product := http.Header{"X-Elastic-Product": []string{"Elasticsearch"}}
+
+	t.Run("Valid", func(t *testing.T) {
+		c := newClient(200, product, `{"version":{"number":"8.1.0"}}`, nil)
+
+		report := c.Validate(context.Background(), ValidateOptions{MinVersion: "8.0.0"})
+		if !report.OK() {
+			t.Errorf("Unexpected errors: %v", report.Errors)
+		}
+		if !report.Reachable || !report.Authenticated || !report.ProductOK || report.Version != "8.1.0" {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("Unsupported version", func(t *testing.T) {
+		c := newClient(200, product, `{"version":{"number":"7.17.0"}}`, nil)
+
+		report := c.Validate(context.Background(), ValidateOptions{MinVersion: "8.0.0"})
+		if report.OK() || !strings.Contains(report.Errors[0].Error(), "unsupported version") {
+			t.Errorf("Unexpected errors: %v", report.Errors)
+		}
+		if !report.ProductOK || report.Version != "7.17.0" {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		c := newClient(0, nil, "", fmt.Errorf("connection refused"))
+
+		report := c.Validate(context.Background(), ValidateOptions{})
+		if report.OK() || report.Reachable || report.Authenticated || report.ProductOK {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("Invalid credentials", func(t *testing.T) {
+		c := newClient(401, product, `{"error":"unauthorized"}`, nil)
+
+		report := c.Validate(context.Background(), ValidateOptions{})
+		if report.OK() || !report.Reachable || report.Authenticated || !report.ProductOK {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("Unknown product", func(t *testing.T) {
+		c := newClient(200, http.Header{}, `{"version":{"number":"8.1.0"}}`, nil)
+
+		report := c.Validate(context.Background(), ValidateOptions{})
+		if report.OK() || report.ProductOK || !report.Authenticated || report.Version != "8.1.0" {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("Compare versions", func(t *testing.T) {
+		for _, tt := range []struct {
+			a, b string
+			want int
+		}{
+			{"8.1.0", "8.1.0", 0},
+			{"8.10.0", "8.9.0", 1},
+			{"7.17.3", "8.0.0", -1},
+			{"8.1.0-SNAPSHOT", "8.1.0", 0},
+			{"8.1", "8.1.0", 0},
+		} {
+			if got := compareVersions(tt.a, tt.b); got != tt.want {
+				t.Errorf("Unexpected result for %s and %s: want=%d, got=%d", tt.a, tt.b, tt.want, got)
+			}
+		}
+	})
+}
+
+func TestClientDoStream(t *testing.T) {
+	newClient := func(status int, body string, reqBody *string) *Client {
+		c, _ := NewClient(Config{
+			DisableRetry: true,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					if req.Body != nil {
+						b, _ := ioutil.ReadAll(req.Body)
+						*reqBody = req.Header.Get("Content-Type") + " " + string(b)
+					}
+					return &http.Response{
+						StatusCode: status,
+						Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+						Body:       ioutil.NopCloser(strings.NewReader(body)),
+					}, nil
+				},
+			},
+		})
+		return c
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		var (
+			reqBody string
+			buf     strings.Builder
+		)
+		c := newClient(200, `{"hits":{"hits":[]}}`, &reqBody)
+
+		n, err := c.DoStream(context.Background(), "POST", "/test/_search", strings.NewReader(`{"query":{}}`), &buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if buf.String() != `{"hits":{"hits":[]}}` || n != int64(buf.Len()) {
+			t.Errorf("Unexpected output: %q (%d bytes)", buf.String(), n)
+		}
+		if reqBody != `application/json {"query":{}}` {
+			t.Errorf("Unexpected request body: %s", reqBody)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var (
+			reqBody string
+			buf     strings.Builder
+		)
+		c := newClient(404, `{"error":{"type":"index_not_found_exception"},"status":404}`, &reqBody)
+
+		n, err := c.DoStream(context.Background(), "GET", "/missing/_search", nil, &buf)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+		if !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "index_not_found_exception") {
+			t.Errorf("Unexpected error: %s", err)
+		}
+		if n != 0 || buf.Len() != 0 {
+			t.Errorf("Unexpected output: %q", buf.String())
+		}
+	})
+}
+
+func TestClientDo(t *testing.T) {
+	var (
+		method, path, contentType, body string
+	)
+
+	newClient := func(cfg Config) *Client {
+		cfg.Transport = &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				method, path, contentType, body = req.Method, req.URL.Path, req.Header.Get("Content-Type"), ""
+				if req.Body != nil {
+					b, _ := ioutil.ReadAll(req.Body)
+					body = string(b)
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+				}, nil
+			},
+		}
+		c, _ := NewClient(cfg)
+		return c
+	}
+
+	t.Run("Explicit content type", func(t *testing.T) {
+		c := newClient(Config{ContentType: "application/vnd.elasticsearch+json;compatible-with=8"})
+
+		res, err := c.Do(context.Background(), esapi.RawRequest{
+			Method:      "POST",
+			Path:        "/test/_doc",
+			Body:        []byte("a,b\n1,2\n"),
+			ContentType: "text/csv",
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		res.Body.Close()
+
+		if method != "POST" || path != "/test/_doc" {
+			t.Errorf("Unexpected request: %s %s", method, path)
+		}
+		if contentType != "text/csv" {
+			t.Errorf("Unexpected content type: %s", contentType)
+		}
+		if body != "a,b\n1,2\n" {
+			t.Errorf("Unexpected body: %q", body)
+		}
+	})
+
+	t.Run("Default content type", func(t *testing.T) {
+		c := newClient(Config{})
+
+		res, err := c.Do(context.Background(), esapi.RawRequest{Method: "POST", Path: "/_search", Body: []byte(`{}`)})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		res.Body.Close()
+
+		if contentType != "application/json" {
+			t.Errorf("Unexpected content type: %s", contentType)
+		}
+	})
+
+	t.Run("Without body", func(t *testing.T) {
+		c := newClient(Config{})
+
+		res, err := c.Do(context.Background(), esapi.RawRequest{Path: "/_cluster/health"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		res.Body.Close()
+
+		if method != "GET" || contentType != "" || body != "" {
+			t.Errorf("Unexpected request: %s, content type=%q, body=%q", method, contentType, body)
+		}
+	})
+
+	t.Run("With params", func(t *testing.T) {
+		var query url.Values
+
+		c, _ := NewClient(Config{Transport: &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				path, query = req.URL.Path, req.URL.Query()
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+				}, nil
+			},
+		}})
+
+		res, err := c.Do(context.Background(), esapi.RawRequest{
+			Method: "PUT",
+			Path:   "/test/_doc/1?routing=a",
+			Body:   []byte(`{}`),
+			Params: url.Values{
+				"refresh": []string{"wait_for"},
+				"routing": []string{"b"},
+				"q":       []string{"a b&c=d"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		res.Body.Close()
+
+		if path != "/test/_doc/1" {
+			t.Errorf("Unexpected path: %s", path)
+		}
+		if !reflect.DeepEqual(query["routing"], []string{"a", "b"}) {
+			t.Errorf("Unexpected routing: %v", query["routing"])
+		}
+		if query.Get("refresh") != "wait_for" {
+			t.Errorf("Unexpected refresh: %v", query["refresh"])
+		}
+		if query.Get("q") != "a b&c=d" {
+			t.Errorf("Unexpected q: %v", query["q"])
+		}
+	})
+}
@@ -91,7 +91,7 @@ func (r CreateRequest) Do(ctx context.Context, transport Transport) (*Response,
 	path.WriteString("/")
 	path.WriteString("_create")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
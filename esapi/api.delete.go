@@ -87,7 +87,7 @@ func (r DeleteRequest) Do(ctx context.Context, transport Transport) (*Response,
 	path.WriteString("/")
 	path.WriteString("_doc")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
@@ -91,7 +91,7 @@ func (r ExistsSourceRequest) Do(ctx context.Context, transport Transport) (*Resp
 		path.WriteString(r.DocumentType)
 	}
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 	path.WriteString("/")
 	path.WriteString("_source")
 
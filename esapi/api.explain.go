@@ -93,7 +93,7 @@ func (r ExplainRequest) Do(ctx context.Context, transport Transport) (*Response,
 	path.WriteString("/")
 	path.WriteString("_explain")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
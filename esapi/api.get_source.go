@@ -87,7 +87,7 @@ func (r GetSourceRequest) Do(ctx context.Context, transport Transport) (*Respons
 	path.WriteString("/")
 	path.WriteString("_source")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
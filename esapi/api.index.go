@@ -98,7 +98,7 @@ func (r IndexRequest) Do(ctx context.Context, transport Transport) (*Response, e
 	path.WriteString("_doc")
 	if r.DocumentID != "" {
 		path.WriteString("/")
-		path.WriteString(r.DocumentID)
+		path.WriteString(escapePathSegment(r.DocumentID))
 	}
 
 	params = make(map[string]string)
@@ -93,7 +93,7 @@ func (r TermvectorsRequest) Do(ctx context.Context, transport Transport) (*Respo
 	path.WriteString("_termvectors")
 	if r.DocumentID != "" {
 		path.WriteString("/")
-		path.WriteString(r.DocumentID)
+		path.WriteString(escapePathSegment(r.DocumentID))
 	}
 
 	params = make(map[string]string)
@@ -94,7 +94,7 @@ func (r UpdateRequest) Do(ctx context.Context, transport Transport) (*Response,
 	path.WriteString("/")
 	path.WriteString("_update")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
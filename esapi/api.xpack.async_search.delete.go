@@ -73,7 +73,7 @@ func (r AsyncSearchDeleteRequest) Do(ctx context.Context, transport Transport) (
 	path.WriteString("/")
 	path.WriteString("_async_search")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
@@ -79,7 +79,7 @@ func (r AsyncSearchGetRequest) Do(ctx context.Context, transport Transport) (*Re
 	path.WriteString("/")
 	path.WriteString("_async_search")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
@@ -75,7 +75,7 @@ func (r AsyncSearchStatusRequest) Do(ctx context.Context, transport Transport) (
 	path.WriteString("/")
 	path.WriteString("status")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
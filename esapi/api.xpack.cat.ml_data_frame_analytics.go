@@ -90,7 +90,7 @@ func (r CatMLDataFrameAnalyticsRequest) Do(ctx context.Context, transport Transp
 	path.WriteString("analytics")
 	if r.DocumentID != "" {
 		path.WriteString("/")
-		path.WriteString(r.DocumentID)
+		path.WriteString(escapePathSegment(r.DocumentID))
 	}
 
 	params = make(map[string]string)
@@ -75,7 +75,7 @@ func (r EqlDeleteRequest) Do(ctx context.Context, transport Transport) (*Respons
 	path.WriteString("/")
 	path.WriteString("search")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
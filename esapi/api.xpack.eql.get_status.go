@@ -77,7 +77,7 @@ func (r EqlGetStatusRequest) Do(ctx context.Context, transport Transport) (*Resp
 	path.WriteString("/")
 	path.WriteString("status")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
@@ -75,7 +75,7 @@ func (r LogstashDeletePipelineRequest) Do(ctx context.Context, transport Transpo
 	path.WriteString("/")
 	path.WriteString("pipeline")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
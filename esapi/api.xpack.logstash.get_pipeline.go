@@ -75,7 +75,7 @@ func (r LogstashGetPipelineRequest) Do(ctx context.Context, transport Transport)
 	path.WriteString("/")
 	path.WriteString("pipeline")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
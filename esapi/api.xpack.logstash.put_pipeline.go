@@ -78,7 +78,7 @@ func (r LogstashPutPipelineRequest) Do(ctx context.Context, transport Transport)
 	path.WriteString("/")
 	path.WriteString("pipeline")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
@@ -81,7 +81,7 @@ func (r MLPreviewDataFrameAnalyticsRequest) Do(ctx context.Context, transport Tr
 	path.WriteString("analytics")
 	if r.DocumentID != "" {
 		path.WriteString("/")
-		path.WriteString(r.DocumentID)
+		path.WriteString(escapePathSegment(r.DocumentID))
 	}
 	path.WriteString("/")
 	path.WriteString("_preview")
@@ -80,7 +80,7 @@ func (r MLUpdateDataFrameAnalyticsRequest) Do(ctx context.Context, transport Tra
 	path.WriteString("/")
 	path.WriteString("analytics")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 	path.WriteString("/")
 	path.WriteString("_update")
 
@@ -77,7 +77,7 @@ func (r SQLDeleteAsyncRequest) Do(ctx context.Context, transport Transport) (*Re
 	path.WriteString("/")
 	path.WriteString("delete")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
@@ -81,7 +81,7 @@ func (r SQLGetAsyncRequest) Do(ctx context.Context, transport Transport) (*Respo
 	path.WriteString("/")
 	path.WriteString("async")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
@@ -77,7 +77,7 @@ func (r SQLGetAsyncStatusRequest) Do(ctx context.Context, transport Transport) (
 	path.WriteString("/")
 	path.WriteString("status")
 	path.WriteString("/")
-	path.WriteString(r.DocumentID)
+	path.WriteString(escapePathSegment(r.DocumentID))
 
 	params = make(map[string]string)
 
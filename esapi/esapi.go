@@ -19,6 +19,7 @@ package esapi
 
 import (
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -60,3 +61,10 @@ func formatDuration(d time.Duration) string {
 	}
 	return strconv.FormatInt(int64(d)/int64(time.Millisecond), 10) + "ms"
 }
+
+// escapePathSegment escapes the string so it can be safely placed
+// inside a URL path segment, eg. a document ID like "a/b#c".
+//
+func escapePathSegment(s string) string {
+	return url.PathEscape(s)
+}
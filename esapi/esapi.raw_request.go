@@ -0,0 +1,103 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esapi
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// RawRequest represents a request to an arbitrary endpoint, with the body sent verbatim,
+// eg. a CSV or newline-delimited JSON payload.
+//
+type RawRequest struct {
+	Method string
+	Path   string // The URL path, eg. "/_sql"; it's sent as is, so the parts have to be escaped.
+
+	// The query string parameters, eg. "refresh" or "routing"; they're encoded and merged
+	// with the query string of the path, when present, keeping the values of both.
+	Params url.Values
+
+	Body        []byte
+	ContentType string // The media type of the body. Default: "application/json".
+
+	Header http.Header
+}
+
+// Do executes the request and returns response or error.
+//
+func (r RawRequest) Do(ctx context.Context, transport Transport) (*Response, error) {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var req *http.Request
+	var err error
+	if r.Body != nil {
+		req, err = newRequest(method, r.Path, bytes.NewReader(r.Body))
+	} else {
+		req, err = newRequest(method, r.Path, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Params) > 0 {
+		q := req.URL.Query()
+		for k, vv := range r.Params {
+			for _, v := range vv {
+				q.Add(k, v)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+
+	for k, vv := range r.Header {
+		for _, v := range vv {
+			req.Header.Add(k, v)
+		}
+	}
+
+	if r.Body != nil {
+		if r.ContentType != "" {
+			req.Header.Set(headerContentType, r.ContentType)
+		} else if req.Header.Get(headerContentType) == "" {
+			req.Header[headerContentType] = headerContentTypeJSON
+		}
+	}
+
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+
+	res, err := transport.Perform(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response := Response{
+		StatusCode: res.StatusCode,
+		Body:       res.Body,
+		Header:     res.Header,
+	}
+
+	return &response, nil
+}
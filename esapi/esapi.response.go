@@ -74,6 +74,70 @@ func (r *Response) String() string {
 	return out.String()
 }
 
+// ReadBody reads the response body into buf, growing it only when needed, and closes the body.
+//
+// The returned slice shares the backing array with buf: passing it back to the next call
+// lets repeated calls reuse the same memory, so the contents are valid only until the
+// next call with the same buffer. Copy the bytes to retain them.
+//
+func (r *Response) ReadBody(buf []byte) ([]byte, error) {
+	buf = buf[:0]
+	if r == nil || r.Body == nil {
+		return buf, nil
+	}
+	defer r.Body.Close()
+
+	if n, err := strconv.Atoi(r.Header.Get("Content-Length")); err == nil && n > cap(buf) {
+		buf = make([]byte, 0, n)
+	}
+
+	var probe [1]byte
+	for {
+		var (
+			n   int
+			err error
+		)
+		if len(buf) < cap(buf) {
+			n, err = r.Body.Read(buf[len(buf):cap(buf)])
+			buf = buf[:len(buf)+n]
+		} else {
+			// Grow the buffer only when the body has more data
+			n, err = r.Body.Read(probe[:])
+			buf = append(buf, probe[:n]...)
+		}
+		if err == io.EOF {
+			return buf, nil
+		}
+		if err != nil {
+			return buf, fmt.Errorf("cannot read response body: %s", err)
+		}
+	}
+}
+
+// DrainResponse reads the response body to completion and closes it.
+//
+// Reading the body fully allows the HTTP client to reuse the network connection;
+// a response body which is not closed leaks the connection. It is safe to call
+// with a nil response or body, typically in a defer statement:
+//
+//	res, err := es.Info()
+//	if err != nil {
+//		// Handle error
+//	}
+//	defer esapi.DrainResponse(res)
+//
+func DrainResponse(res *Response) error {
+	if res == nil || res.Body == nil {
+		return nil
+	}
+
+	_, err := io.Copy(ioutil.Discard, res.Body)
+	if cerr := res.Body.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
 // Status returns the response status as a string.
 //
 func (r *Response) Status() string {
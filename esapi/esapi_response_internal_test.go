@@ -108,4 +108,112 @@ func TestAPIResponse(t *testing.T) {
 			t.Errorf("Expected [2] warnings, got: %d", len(res.Warnings()))
 		}
 	})
+
+	t.Run("ReadBody", func(t *testing.T) {
+		body = `{"foo":"bar"}`
+		buf := make([]byte, 0, 64)
+
+		res = &Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(body))}
+		b, err := res.ReadBody(buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if string(b) != body {
+			t.Errorf("Unexpected body: %s", b)
+		}
+		if &b[:1][0] != &buf[:1][0] {
+			t.Errorf("Expected the buffer to be reused")
+		}
+
+		res = &Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader("{}"))}
+		b, err = res.ReadBody(b)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if string(b) != "{}" || &b[:1][0] != &buf[:1][0] {
+			t.Errorf("Unexpected body: %s", b)
+		}
+	})
+
+	t.Run("ReadBody grows the buffer", func(t *testing.T) {
+		body = strings.Repeat("x", 1000)
+
+		res = &Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(body))}
+		b, err := res.ReadBody(make([]byte, 0, 8))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if string(b) != body {
+			t.Errorf("Unexpected body length: %d", len(b))
+		}
+
+		hdr := http.Header{}
+		hdr.Set("Content-Length", "1000")
+		res = &Response{StatusCode: 200, Header: hdr, Body: ioutil.NopCloser(strings.NewReader(body))}
+		b, err = res.ReadBody(nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if string(b) != body {
+			t.Errorf("Unexpected body length: %d", len(b))
+		}
+		if cap(b) != 1000 {
+			t.Errorf("Unexpected buffer capacity: %d", cap(b))
+		}
+	})
+
+	t.Run("ReadBody Error", func(t *testing.T) {
+		res = &Response{StatusCode: 200, Body: ioutil.NopCloser(errReader{})}
+
+		if _, err := res.ReadBody(nil); err == nil || !strings.Contains(err.Error(), "MOCK ERROR") {
+			t.Errorf("Expected error, got: %v", err)
+		}
+	})
+
+	t.Run("ReadBody with nil response", func(t *testing.T) {
+		res = nil
+
+		b, err := res.ReadBody(nil)
+		if err != nil || len(b) != 0 {
+			t.Errorf("Unexpected result: %q, %v", b, err)
+		}
+	})
+	t.Run("DrainResponse", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(`{"foo":"bar"}`)}
+		res = &Response{StatusCode: 200, Body: body}
+
+		if err := DrainResponse(res); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if body.Len() != 0 {
+			t.Errorf("Expected the body to be read, remaining: %d", body.Len())
+		}
+		if !body.closed {
+			t.Errorf("Expected the body to be closed")
+		}
+	})
+
+	t.Run("DrainResponse Error", func(t *testing.T) {
+		res = &Response{StatusCode: 200, Body: ioutil.NopCloser(errReader{})}
+
+		if err := DrainResponse(res); err == nil {
+			t.Errorf("Expected error, got: %v", err)
+		}
+	})
+
+	t.Run("DrainResponse with nil response", func(t *testing.T) {
+		if err := DrainResponse(nil); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+		if err := DrainResponse(&Response{}); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
 }
+
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error { r.closed = true; return nil }
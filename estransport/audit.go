@@ -0,0 +1,95 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package estransport
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// RequestMeta represents the metadata of a single round-trip passed to the audit sink.
+//
+type RequestMeta struct {
+	Method     string
+	URL        string // The URL of the request, without the credentials.
+	Path       string
+	StatusCode int           // The response status code, or 0 when the round-trip failed.
+	Duration   time.Duration // The duration of the round-trip, until the response headers were received.
+	Attempt    int           // The number of the attempt, starting with 1 for the first one.
+	Err        error         // The error of the round-trip, eg. a network error.
+}
+
+// readAuditRequestBody reads the request body for the audit sink, and replaces it
+// with a replayable copy, so it can be sent again on retries.
+//
+func readAuditRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	req.Body, _ = req.GetBody()
+	req.ContentLength = int64(len(b))
+
+	return b, nil
+}
+
+// audit passes the round-trip to the audit sink, reading the response body,
+// which is replaced with a copy, so it remains readable for the caller.
+//
+func (c *Client) audit(req *http.Request, reqBody []byte, res *http.Response, err error, attempt int, dur time.Duration) {
+	var resBody []byte
+	if res != nil && res.Body != nil && res.Body != http.NoBody {
+		var rerr error
+		resBody, rerr = ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if rerr != nil {
+			res.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(resBody), errorReader{err: rerr}))
+		} else {
+			res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+		}
+	}
+
+	u := *req.URL
+	u.User = nil
+
+	meta := RequestMeta{
+		Method:   req.Method,
+		URL:      u.String(),
+		Path:     req.URL.Path,
+		Duration: dur,
+		Attempt:  attempt,
+		Err:      err,
+	}
+	if res != nil {
+		meta.StatusCode = res.StatusCode
+	}
+
+	c.auditSink(req.Context(), reqBody, resBody, meta)
+}
@@ -0,0 +1,310 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package estransport
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock implements the clock with the time advanced manually, with Advance.
+//
+type fakeClock struct {
+	sync.Mutex
+
+	now       time.Time
+	timers    []*fakeTimer
+	scheduled chan time.Duration // Receives the duration of every new timer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	when  time.Time
+	c     chan time.Time
+	f     func()
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), scheduled: make(chan time.Duration, 100)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) timer { return c.schedule(d, nil) }
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) timer { return c.schedule(d, f) }
+
+func (c *fakeClock) schedule(d time.Duration, f func()) *fakeTimer {
+	c.Lock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), c: make(chan time.Time, 1), f: f}
+	c.timers = append(c.timers, t)
+	c.Unlock()
+
+	c.scheduled <- d
+	return t
+}
+
+// Advance moves the time forward, and fires the timers which are due.
+//
+func (c *fakeClock) Advance(d time.Duration) {
+	c.Lock()
+	c.now = c.now.Add(d)
+	var due, pending []*fakeTimer
+	for _, t := range c.timers {
+		if !t.when.After(c.now) {
+			due = append(due, t)
+		} else {
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	now := c.now
+	c.Unlock()
+
+	for _, t := range due {
+		if t.f != nil {
+			t.f()
+		} else {
+			t.c <- now
+		}
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.Lock()
+	defer t.clock.Unlock()
+	for i, tt := range t.clock.timers {
+		if tt == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func TestClock(t *testing.T) {
+	t.Run("Retry backoff", func(t *testing.T) {
+		var (
+			mu          sync.Mutex
+			numRequests int
+		)
+
+		fc := newFakeClock()
+		tp, _ := New(Config{
+			URLs:         []*url.URL{{}},
+			MaxRetries:   1,
+			RetryBackoff: func(attempt int) time.Duration { return time.Hour },
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					numRequests++
+					if numRequests == 1 {
+						return &http.Response{Status: "MOCK", StatusCode: http.StatusBadGateway}, nil
+					}
+					return &http.Response{Status: "MOCK", StatusCode: http.StatusOK}, nil
+				},
+			},
+		})
+		tp.clock = fc
+
+		done := make(chan *http.Response)
+		go func() {
+			req, _ := http.NewRequest("GET", "/abc", nil)
+			res, _ := tp.Perform(req)
+			done <- res
+		}()
+
+		if d := <-fc.scheduled; d != time.Hour {
+			t.Errorf("Unexpected backoff: %s", d)
+		}
+		mu.Lock()
+		if numRequests != 1 {
+			t.Errorf("Unexpected number of requests before the backoff: %d", numRequests)
+		}
+		mu.Unlock()
+
+		fc.Advance(time.Hour)
+		res := <-done
+		if res == nil || res.StatusCode != http.StatusOK {
+			t.Errorf("Unexpected response: %+v", res)
+		}
+	})
+
+	t.Run("Deadline before the backoff", func(t *testing.T) {
+		var numRequests int32
+
+		// The deadline is 2h away in real time, but only 30m away on the client clock
+		fc := newFakeClock()
+		fc.now = time.Now().Add(90 * time.Minute)
+		tp, _ := New(Config{
+			URLs:         []*url.URL{{}},
+			MaxRetries:   1,
+			RetryBackoff: func(attempt int) time.Duration { return time.Hour },
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					atomic.AddInt32(&numRequests, 1)
+					return &http.Response{Status: "MOCK", StatusCode: http.StatusBadGateway}, nil
+				},
+			},
+		})
+		tp.clock = fc
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+		defer cancel()
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		res, err := tp.Perform(req.WithContext(ctx))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.StatusCode != http.StatusBadGateway {
+			t.Errorf("Unexpected response: %+v", res)
+		}
+		if n := atomic.LoadInt32(&numRequests); n != 1 {
+			t.Errorf("Unexpected number of requests: %d", n)
+		}
+		if len(fc.scheduled) != 0 {
+			t.Errorf("Unexpected backoff timer")
+		}
+	})
+
+	t.Run("Perform result duration", func(t *testing.T) {
+		fc := newFakeClock()
+		tp, _ := New(Config{
+			URLs: []*url.URL{{}},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					fc.Advance(time.Minute)
+					return &http.Response{Status: "MOCK", StatusCode: http.StatusOK}, nil
+				},
+			},
+		})
+		tp.clock = fc
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		result, err := tp.PerformWithResult(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if result.TotalDuration != time.Minute {
+			t.Errorf("Unexpected duration: %s", result.TotalDuration)
+		}
+	})
+
+	t.Run("Hedge delay", func(t *testing.T) {
+		var (
+			mu    sync.Mutex
+			hosts []string
+		)
+
+		fc := newFakeClock()
+		tp, _ := New(Config{
+			URLs:       []*url.URL{{Scheme: "http", Host: "foo1"}, {Scheme: "http", Host: "foo2"}},
+			HedgeAfter: time.Hour,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					hosts = append(hosts, req.URL.Host)
+					first := len(hosts) == 1
+					mu.Unlock()
+
+					if first {
+						<-req.Context().Done()
+						return nil, req.Context().Err()
+					}
+					return &http.Response{Status: "MOCK", StatusCode: http.StatusOK}, nil
+				},
+			},
+		})
+		tp.clock = fc
+
+		done := make(chan *http.Response)
+		go func() {
+			req, _ := http.NewRequest("GET", "/abc", nil)
+			res, _ := tp.Perform(req)
+			done <- res
+		}()
+
+		if d := <-fc.scheduled; d != time.Hour {
+			t.Errorf("Unexpected hedge delay: %s", d)
+		}
+
+		fc.Advance(time.Hour)
+		res := <-done
+		if res == nil || res.StatusCode != http.StatusOK {
+			t.Errorf("Unexpected response: %+v", res)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(hosts) != 2 || hosts[0] == hosts[1] {
+			t.Errorf("Unexpected hosts: %v", hosts)
+		}
+	})
+
+	t.Run("Resurrection", func(t *testing.T) {
+		fc := newFakeClock()
+		pool := &statusConnectionPool{
+			live: []*Connection{
+				{URL: &url.URL{Scheme: "http", Host: "foo1"}},
+				{URL: &url.URL{Scheme: "http", Host: "foo2"}},
+			},
+			selector: &roundRobinSelector{curr: -1},
+			clock:    fc,
+		}
+
+		conn := pool.live[0]
+		pool.OnFailure(conn)
+
+		if d := <-fc.scheduled; d != defaultResurrectTimeoutInitial {
+			t.Errorf("Unexpected resurrection timeout: %s", d)
+		}
+		pool.Lock()
+		if len(pool.dead) != 1 || !conn.DeadSince.Equal(fc.Now()) {
+			t.Errorf("Expected the connection to be dead since %s, got: %s", fc.Now(), conn)
+		}
+		pool.Unlock()
+
+		fc.Advance(defaultResurrectTimeoutInitial - time.Second)
+		pool.Lock()
+		if len(pool.dead) != 1 {
+			t.Errorf("Unexpected resurrection before the timeout")
+		}
+		pool.Unlock()
+
+		fc.Advance(time.Second)
+		pool.Lock()
+		defer pool.Unlock()
+		if len(pool.dead) != 0 || len(pool.live) != 2 {
+			t.Errorf("Expected the connection to be resurrected, live=%s, dead=%s", pool.live, pool.dead)
+		}
+	})
+}
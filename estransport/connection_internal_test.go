@@ -20,8 +20,12 @@
 package estransport
 
 import (
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -376,3 +380,104 @@ func TestConnection(t *testing.T) {
 		}
 	})
 }
+
+func TestLeastRequestsSelector(t *testing.T) {
+	t.Run("Select", func(t *testing.T) {
+		conns := []*Connection{
+			{URL: &url.URL{Scheme: "http", Host: "foo1"}, inFlight: 3},
+			{URL: &url.URL{Scheme: "http", Host: "foo2"}, inFlight: 1},
+			{URL: &url.URL{Scheme: "http", Host: "foo3"}, inFlight: 2},
+		}
+		s := NewLeastRequestsSelector()
+
+		for i := 0; i < 3; i++ {
+			c, err := s.Select(conns)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if c.URL.Host != "foo2" {
+				t.Errorf("Unexpected connection: %s", c.URL)
+			}
+		}
+	})
+
+	t.Run("Ties", func(t *testing.T) {
+		conns := []*Connection{
+			{URL: &url.URL{Scheme: "http", Host: "foo1"}},
+			{URL: &url.URL{Scheme: "http", Host: "foo2"}},
+			{URL: &url.URL{Scheme: "http", Host: "foo3"}},
+		}
+		s := NewLeastRequestsSelector()
+
+		var hosts []string
+		for i := 0; i < 4; i++ {
+			c, _ := s.Select(conns)
+			hosts = append(hosts, c.URL.Host)
+		}
+
+		if strings.Join(hosts, ",") != "foo1,foo2,foo3,foo1" {
+			t.Errorf("Unexpected connections: %s", hosts)
+		}
+	})
+
+	t.Run("Transport", func(t *testing.T) {
+		var (
+			mu    sync.Mutex
+			hosts []string
+		)
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+
+		tp, err := New(Config{
+			URLs:         []*url.URL{{Scheme: "http", Host: "foo1"}, {Scheme: "http", Host: "foo2"}},
+			LoadBalancer: "least-requests",
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					hosts = append(hosts, req.URL.Host)
+					mu.Unlock()
+					if req.URL.Path == "/slow" {
+						close(started)
+						<-release
+					}
+					return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			req, _ := http.NewRequest("GET", "/slow", nil)
+			tp.Perform(req)
+			close(done)
+		}()
+		<-started
+
+		// The node with the slow request is avoided
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest("GET", "/fast", nil)
+			tp.Perform(req)
+		}
+		close(release)
+		<-done
+
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.Join(hosts, ",") != "foo1,foo2,foo2,foo2" {
+			t.Errorf("Unexpected hosts: %s", hosts)
+		}
+	})
+
+	t.Run("Invalid configuration", func(t *testing.T) {
+		if _, err := New(Config{LoadBalancer: "random"}); err == nil {
+			t.Errorf("Expected error for invalid load balancer")
+		}
+		if _, err := New(Config{LoadBalancer: "least-requests", Selector: &roundRobinSelector{}}); err == nil {
+			t.Errorf("Expected error for load balancer with selector")
+		}
+	})
+}
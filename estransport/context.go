@@ -0,0 +1,129 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package estransport
+
+import (
+	"context"
+	"net/url"
+)
+
+type (
+	disableCompressionKey struct{}
+	disableBufferingKey   struct{}
+	writeRequestKey       struct{}
+	allowRefreshKey       struct{}
+	requestLabelsKey      struct{}
+	forceURLKey           struct{}
+)
+
+// WithoutCompression returns a copy of ctx which disables the compression of the request body,
+// even when the CompressRequestBody option is enabled.
+//
+func WithoutCompression(ctx context.Context) context.Context {
+	return context.WithValue(ctx, disableCompressionKey{}, true)
+}
+
+// isCompressionDisabled returns true when the compression has been disabled for the request context.
+//
+func isCompressionDisabled(ctx context.Context) bool {
+	v, _ := ctx.Value(disableCompressionKey{}).(bool)
+	return v
+}
+
+// WithoutBuffering returns a copy of ctx which streams the request body to the node,
+// without buffering it in memory, eg. for large search requests.
+//
+// The request body can be read only once, so the request is not retried on failures,
+// regardless of the MaxRetries and RetryOnStatus options, and it is not hedged.
+// The body is not compressed, and it is not passed to the audit sink.
+//
+// Use it only for requests where the loss of the retry safety is acceptable.
+//
+func WithoutBuffering(ctx context.Context) context.Context {
+	return context.WithValue(ctx, disableBufferingKey{}, true)
+}
+
+// isBufferingDisabled returns true when the buffering has been disabled for the request context.
+//
+func isBufferingDisabled(ctx context.Context) bool {
+	v, _ := ctx.Value(disableBufferingKey{}).(bool)
+	return v
+}
+
+// WithReadRequest returns a copy of ctx which routes the request to the read pool,
+// configured with the ReadURLs option, regardless of its method and path.
+//
+func WithReadRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeRequestKey{}, false)
+}
+
+// WithWriteRequest returns a copy of ctx which routes the request to the write pool,
+// configured with the WriteURLs option, regardless of its method and path.
+//
+func WithWriteRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeRequestKey{}, true)
+}
+
+// WithRefresh returns a copy of ctx which sends the "refresh" parameter of the request as set,
+// when the ForceRefreshFalse option is enabled.
+//
+func WithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowRefreshKey{}, true)
+}
+
+// isRefreshAllowed returns true when the refresh has been allowed for the request context.
+//
+func isRefreshAllowed(ctx context.Context) bool {
+	v, _ := ctx.Value(allowRefreshKey{}).(bool)
+	return v
+}
+
+// WithRequestLabels returns a copy of ctx which carries the labels for the request, eg. a tenant name.
+//
+// The labels are passed to the RateLimiter, and recorded in the metrics, when enabled.
+//
+func WithRequestLabels(ctx context.Context, labels map[string]string) context.Context {
+	return context.WithValue(ctx, requestLabelsKey{}, labels)
+}
+
+// RequestLabels returns the labels stored in ctx with WithRequestLabels, or nil.
+//
+func RequestLabels(ctx context.Context) map[string]string {
+	v, _ := ctx.Value(requestLabelsKey{}).(map[string]string)
+	return v
+}
+
+// WithForceURL returns a copy of ctx which sends the request to the node at u,
+// bypassing the connection selection, eg. to diagnose a single misbehaving node.
+//
+// The authentication and headers are applied as for any other request, and the retries
+// are sent to the same node. The node doesn't have to be a part of the connection pool,
+// and its failures aren't reported to the pool.
+//
+// It is intended as a diagnostic tool, not for the general routing of requests.
+//
+func WithForceURL(ctx context.Context, u *url.URL) context.Context {
+	return context.WithValue(ctx, forceURLKey{}, u)
+}
+
+// forceURL returns the URL stored in ctx with WithForceURL, or nil.
+//
+func forceURL(ctx context.Context) *url.URL {
+	v, _ := ctx.Value(forceURLKey{}).(*url.URL)
+	return v
+}
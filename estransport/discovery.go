@@ -18,28 +18,42 @@
 package estransport
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const defaultDiscoverNodesEndpoint = "/_nodes/http"
+
 // Discoverable defines the interface for transports supporting node discovery.
 //
 type Discoverable interface {
 	DiscoverNodes() error
 }
 
-// nodeInfo represents the information about node in a cluster.
+// Refreshable defines the interface for transports supporting the refresh of connections.
+//
+type Refreshable interface {
+	RefreshConnections(ctx context.Context) error
+}
+
+// NodeInfo represents the information about node in a cluster.
+//
+// It's passed to the Config.OnDiscoveredNodes function, which can filter or modify the discovered nodes.
 //
 // See: https://www.elastic.co/guide/en/elasticsearch/reference/current/cluster-nodes-info.html
 //
-type nodeInfo struct {
+type NodeInfo struct {
 	ID         string
 	Name       string
 	URL        *url.URL
@@ -53,9 +67,48 @@ type nodeInfo struct {
 // DiscoverNodes reloads the client connections by fetching information from the cluster.
 //
 func (c *Client) DiscoverNodes() error {
-	var conns []*Connection
+	return c.discoverNodes(context.Background())
+}
+
+// RefreshConnections drops the current connections and discovers the nodes immediately,
+// eg. after a drastic change of the cluster topology, such as a blue/green cutover.
+//
+// The connection pool is reset to the configured URLs, the idle network connections are closed,
+// and the nodes are discovered through the configured URLs. The requests in flight complete
+// on their current connections. When the discovery fails, the error is returned,
+// and the pool is left with the configured URLs.
+//
+func (c *Client) RefreshConnections(ctx context.Context) error {
+	conns := make([]*Connection, 0, len(c.urls))
+	for _, u := range c.urls {
+		conns = append(conns, &Connection{URL: u})
+	}
+
+	c.Lock()
+	if c.poolFunc != nil {
+		c.pool = c.poolFunc(conns, c.selector)
+	} else {
+		c.pool, _ = NewConnectionPool(conns, c.selector)
+	}
+	c.configurePool()
+	c.Unlock()
+
+	if tp, ok := c.baseTransport.(interface{ CloseIdleConnections() }); ok {
+		tp.CloseIdleConnections()
+	}
+
+	return c.discoverNodes(ctx)
+}
+
+// discoverNodes reloads the client connections by fetching information from the cluster.
+//
+func (c *Client) discoverNodes(ctx context.Context) error {
+	var (
+		conns      []*Connection
+		candidates []NodeInfo
+	)
 
-	nodes, err := c.getNodesInfo()
+	nodes, err := c.getNodesInfoContext(ctx)
 	if err != nil {
 		if debugLogger != nil {
 			debugLogger.Logf("Error getting nodes info: %s\n", err)
@@ -89,6 +142,20 @@ func (c *Client) DiscoverNodes() error {
 			continue
 		}
 
+		candidates = append(candidates, node)
+	}
+
+	if c.onDiscoveredNodes != nil {
+		candidates = c.onDiscoveredNodes(candidates)
+	}
+
+	// Use a random subset of the nodes, re-sampled on every discovery
+	if c.maxDiscoveredNodes > 0 && len(candidates) > c.maxDiscoveredNodes {
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		candidates = candidates[:c.maxDiscoveredNodes]
+	}
+
+	for _, node := range candidates {
 		conns = append(conns, &Connection{
 			URL:        node.URL,
 			ID:         node.ID,
@@ -98,6 +165,15 @@ func (c *Client) DiscoverNodes() error {
 		})
 	}
 
+	// Keep the current connections, since an empty pool would fail every request,
+	// including the next discovery
+	if len(conns) == 0 {
+		if debugLogger != nil {
+			debugLogger.Logf("No nodes discovered, keeping the current connections\n")
+		}
+		return errors.New("discovery: no nodes to connect to")
+	}
+
 	c.Lock()
 	defer c.Unlock()
 
@@ -106,6 +182,11 @@ func (c *Client) DiscoverNodes() error {
 		defer lockable.Unlock()
 	}
 
+	var prevConns []*Connection
+	if pool, ok := c.pool.(connectionable); ok {
+		prevConns = pool.connections()
+	}
+
 	if c.poolFunc != nil {
 		c.pool = c.poolFunc(conns, c.selector)
 	} else {
@@ -115,20 +196,59 @@ func (c *Client) DiscoverNodes() error {
 			return err
 		}
 	}
+	c.configurePool()
+
+	if lifecycleLogger, ok := c.logger.(LifecycleLogger); ok {
+		added, removed := diffConnections(prevConns, conns)
+		lifecycleLogger.LogNodesDiscovered(added, removed)
+	}
 
 	return nil
 }
 
-func (c *Client) getNodesInfo() ([]nodeInfo, error) {
+// diffConnections returns the connections in next with a URL missing from prev,
+// and the connections in prev with a URL missing from next.
+//
+func diffConnections(prev, next []*Connection) (added, removed []*Connection) {
+	prevURLs := make(map[string]bool, len(prev))
+	for _, c := range prev {
+		prevURLs[c.URL.String()] = true
+	}
+	nextURLs := make(map[string]bool, len(next))
+	for _, c := range next {
+		nextURLs[c.URL.String()] = true
+		if !prevURLs[c.URL.String()] {
+			added = append(added, c)
+		}
+	}
+	for _, c := range prev {
+		if !nextURLs[c.URL.String()] {
+			removed = append(removed, c)
+		}
+	}
+	return added, removed
+}
+
+func (c *Client) getNodesInfo() ([]NodeInfo, error) {
+	return c.getNodesInfoContext(context.Background())
+}
+
+func (c *Client) getNodesInfoContext(ctx context.Context) ([]NodeInfo, error) {
 	var (
-		out    []nodeInfo
+		out    []NodeInfo
 		scheme = c.urls[0].Scheme
 	)
 
-	req, err := http.NewRequest("GET", "/_nodes/http", nil)
+	endpoint := c.discoverNodesEndpoint
+	if endpoint == "" {
+		endpoint = defaultDiscoverNodesEndpoint
+	}
+
+	req, err := http.NewRequest("GET", endpoint, nil)
 	if err != nil {
 		return out, err
 	}
+	req = req.WithContext(ctx)
 
 	c.Lock()
 	conn, err := c.pool.Next()
@@ -162,7 +282,7 @@ func (c *Client) getNodesInfo() ([]nodeInfo, error) {
 		return out, err
 	}
 
-	var nodes map[string]nodeInfo
+	var nodes map[string]NodeInfo
 	if err := json.Unmarshal(env["nodes"], &nodes); err != nil {
 		return out, err
 	}
@@ -170,13 +290,23 @@ func (c *Client) getNodesInfo() ([]nodeInfo, error) {
 	for id, node := range nodes {
 		node.ID = id
 		node.URL = c.getNodeURL(node, scheme)
+		if c.discoverNodesURLFunc != nil {
+			node.URL = c.discoverNodesURLFunc(node)
+			if node.URL == nil {
+				continue
+			}
+		}
 		out = append(out, node)
 	}
 
+	if len(nodes) > 0 && len(out) == 0 {
+		return out, errors.New("all nodes dropped by DiscoverNodesURLFunc")
+	}
+
 	return out, nil
 }
 
-func (c *Client) getNodeURL(node nodeInfo, scheme string) *url.URL {
+func (c *Client) getNodeURL(node NodeInfo, scheme string) *url.URL {
 	var (
 		host string
 		port string
@@ -200,6 +330,19 @@ func (c *Client) getNodeURL(node nodeInfo, scheme string) *url.URL {
 	return u
 }
 
+// discoverNodesAsync starts the node discovery in a goroutine, unless it's already in progress.
+//
+func (c *Client) discoverNodesAsync() {
+	if !atomic.CompareAndSwapInt32(&c.discoverNodesInProgress, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&c.discoverNodesInProgress, 0)
+		c.DiscoverNodes()
+	}()
+}
+
 func (c *Client) scheduleDiscoverNodes(d time.Duration) {
 	go c.DiscoverNodes()
 
@@ -208,7 +351,17 @@ func (c *Client) scheduleDiscoverNodes(d time.Duration) {
 	if c.discoverNodesTimer != nil {
 		c.discoverNodesTimer.Stop()
 	}
-	c.discoverNodesTimer = time.AfterFunc(c.discoverNodesInterval, func() {
+	c.discoverNodesTimer = time.AfterFunc(c.nextDiscoverNodesInterval(), func() {
 		c.scheduleDiscoverNodes(c.discoverNodesInterval)
 	})
 }
+
+// nextDiscoverNodesInterval returns the discovery interval, randomized by the jitter, when configured,
+// so the clients started together don't discover the nodes at the same time.
+//
+func (c *Client) nextDiscoverNodesInterval() time.Duration {
+	if c.discoverNodesJitter <= 0 {
+		return c.discoverNodesInterval
+	}
+	return time.Duration(float64(c.discoverNodesInterval) * (1 + c.discoverNodesJitter*(2*rand.Float64()-1)))
+}
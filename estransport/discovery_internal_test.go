@@ -21,6 +21,7 @@ package estransport
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -30,6 +31,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -127,6 +129,245 @@ func TestDiscovery(t *testing.T) {
 		}
 	})
 
+	t.Run("DiscoverNodes() with OnDiscoveredNodes", func(t *testing.T) {
+		var discovered []string
+
+		u, _ := url.Parse("http://" + srv.Addr)
+		tp, _ := New(Config{
+			URLs: []*url.URL{u},
+			OnDiscoveredNodes: func(nodes []NodeInfo) []NodeInfo {
+				var out []NodeInfo
+				for _, node := range nodes {
+					discovered = append(discovered, node.Name)
+					if node.Name == "es2" {
+						continue
+					}
+					node.Attributes = map[string]interface{}{"zone": "a"}
+					out = append(out, node)
+				}
+				return out
+			},
+		})
+
+		if err := tp.DiscoverNodes(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(discovered) != 2 {
+			t.Errorf("Unexpected discovered nodes, want=2, got=%d: %s", len(discovered), discovered)
+		}
+
+		pool, ok := tp.pool.(*singleConnectionPool)
+		if !ok {
+			t.Fatalf("Unexpected pool, want=singleConnectionPool, got=%T", tp.pool)
+		}
+		if pool.connection.Name != "es1" {
+			t.Errorf("Unexpected node: %s", pool.connection.Name)
+		}
+		if pool.connection.Attributes["zone"] != "a" {
+			t.Errorf("Unexpected attributes: %v", pool.connection.Attributes)
+		}
+	})
+
+	t.Run("DiscoverNodes() with OnDiscoveredNodes returning no nodes", func(t *testing.T) {
+		u, _ := url.Parse("http://" + srv.Addr)
+		tp, _ := New(Config{
+			URLs:              []*url.URL{u},
+			OnDiscoveredNodes: func(nodes []NodeInfo) []NodeInfo { return []NodeInfo{} },
+		})
+
+		err := tp.DiscoverNodes()
+		if err == nil || !strings.Contains(err.Error(), "no nodes to connect to") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		pool, ok := tp.pool.(*singleConnectionPool)
+		if !ok {
+			t.Fatalf("Unexpected pool, want=singleConnectionPool, got=%T", tp.pool)
+		}
+		if pool.connection.URL.String() != u.String() {
+			t.Errorf("Expected the current connection to be kept, got: %s", pool.connection.URL)
+		}
+
+		// The next discovery can still reach the cluster
+		tp.onDiscoveredNodes = nil
+		if err := tp.DiscoverNodes(); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("DiscoverNodes() with MaxDiscoveredNodes", func(t *testing.T) {
+		seen := make(map[string]bool)
+
+		u, _ := url.Parse("http://" + srv.Addr)
+
+		for i := 0; i < 50 && len(seen) < 2; i++ {
+			// Use a new client every time, since the discovered nodes aren't reachable
+			tp, _ := New(Config{URLs: []*url.URL{u}, MaxDiscoveredNodes: 1})
+			if err := tp.DiscoverNodes(); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			pool, ok := tp.pool.(*singleConnectionPool)
+			if !ok {
+				t.Fatalf("Unexpected pool, want=singleConnectionPool, got=%T", tp.pool)
+			}
+			seen[pool.connection.Name] = true
+		}
+
+		if !seen["es1"] || !seen["es2"] {
+			t.Errorf("Expected the nodes to be re-sampled, got: %v", seen)
+		}
+	})
+
+	t.Run("DiscoverNodes() with custom endpoint and URL mapping", func(t *testing.T) {
+		var endpoint string
+
+		u, _ := url.Parse("http://" + srv.Addr)
+		tp, _ := New(Config{
+			URLs:                  []*url.URL{u},
+			DiscoverNodesEndpoint: "/_nodes/data:true/http",
+			DiscoverNodesURLFunc: func(node NodeInfo) *url.URL {
+				if node.Name == "es2" {
+					return nil
+				}
+				return &url.URL{Scheme: "https", Host: node.Name + ".example.com:443"}
+			},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					endpoint = req.URL.Path
+					f, _ := os.Open("testdata/nodes.info.json")
+					return &http.Response{StatusCode: http.StatusOK, Body: f}, nil
+				},
+			},
+		})
+
+		if err := tp.DiscoverNodes(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if endpoint != "/_nodes/data:true/http" {
+			t.Errorf("Unexpected endpoint: %s", endpoint)
+		}
+
+		pool, ok := tp.pool.(*singleConnectionPool)
+		if !ok {
+			t.Fatalf("Unexpected pool, want=singleConnectionPool, got=%T", tp.pool)
+		}
+		if pool.connection.URL.String() != "https://es1.example.com:443" {
+			t.Errorf("Unexpected URL: %s", pool.connection.URL)
+		}
+	})
+
+	t.Run("DiscoverNodes() with URL mapping dropping all nodes", func(t *testing.T) {
+		u, _ := url.Parse("http://" + srv.Addr)
+		tp, _ := New(Config{
+			URLs:                 []*url.URL{u},
+			DiscoverNodesURLFunc: func(node NodeInfo) *url.URL { return nil },
+		})
+
+		err := tp.DiscoverNodes()
+		if err == nil || !strings.Contains(err.Error(), "all nodes dropped by DiscoverNodesURLFunc") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		pool, ok := tp.pool.(*singleConnectionPool)
+		if !ok {
+			t.Fatalf("Unexpected pool, want=singleConnectionPool, got=%T", tp.pool)
+		}
+		if pool.connection.URL.String() != u.String() {
+			t.Errorf("Expected the current connection to be kept, got: %s", pool.connection.URL)
+		}
+	})
+
+	t.Run("RefreshConnections()", func(t *testing.T) {
+		var hosts []string
+
+		u, _ := url.Parse("http://seed.example.com:9200")
+		tp, _ := New(Config{
+			URLs: []*url.URL{u},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					hosts = append(hosts, req.URL.Host)
+					f, _ := os.Open("testdata/nodes.info.json")
+					return &http.Response{StatusCode: http.StatusOK, Body: f}, nil
+				},
+			},
+		})
+
+		if err := tp.DiscoverNodes(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(tp.pool.URLs()) != 2 {
+			t.Fatalf("Unexpected number of nodes, want=2, got=%d", len(tp.pool.URLs()))
+		}
+
+		hosts = nil
+		if err := tp.RefreshConnections(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(hosts) != 1 || hosts[0] != "seed.example.com:9200" {
+			t.Errorf("Expected discovery through the configured URL, got: %v", hosts)
+		}
+		if len(tp.pool.URLs()) != 2 {
+			t.Errorf("Unexpected number of nodes, want=2, got=%d", len(tp.pool.URLs()))
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		tp.transport = &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return nil, req.Context().Err()
+			},
+		}
+		if err := tp.RefreshConnections(ctx); err == nil {
+			t.Errorf("Expected error for canceled context")
+		}
+		urls := tp.pool.URLs()
+		if len(urls) != 1 || urls[0].String() != u.String() {
+			t.Errorf("Expected pool with the configured URL, got: %v", urls)
+		}
+	})
+
+	t.Run("DiscoverNodesOnFailure", func(t *testing.T) {
+		for _, enabled := range []bool{false, true} {
+			discovered := make(chan struct{}, 10)
+
+			u, _ := url.Parse("http://" + srv.Addr)
+			tp, _ := New(Config{
+				URLs:                   []*url.URL{u},
+				DisableRetry:           true,
+				DiscoverNodesOnFailure: enabled,
+				Transport: &mockTransp{
+					RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+						if req.URL.Path == "/_nodes/http" {
+							discovered <- struct{}{}
+							f, _ := os.Open("testdata/nodes.info.json")
+							return &http.Response{StatusCode: http.StatusOK, Body: f}, nil
+						}
+						return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+					},
+				},
+			})
+
+			req, _ := http.NewRequest("GET", "/abc", nil)
+			tp.Perform(req)
+
+			select {
+			case <-discovered:
+				if !enabled {
+					t.Errorf("Unexpected node discovery when DiscoverNodesOnFailure is disabled")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if enabled {
+					t.Errorf("Expected node discovery when DiscoverNodesOnFailure is enabled")
+				}
+			}
+		}
+	})
+
 	t.Run("DiscoverNodes() with SSL and authorization", func(t *testing.T) {
 		u, _ := url.Parse("https://" + srvTLS.Addr)
 		tp, _ := New(Config{
@@ -191,6 +432,39 @@ func TestDiscovery(t *testing.T) {
 		}
 	})
 
+	t.Run("DiscoverNodesJitter", func(t *testing.T) {
+		tp, err := New(Config{URLs: []*url.URL{{}}, DiscoverNodesJitter: 0.2})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		tp.discoverNodesInterval = time.Minute
+
+		var varied bool
+		for i := 0; i < 100; i++ {
+			d := tp.nextDiscoverNodesInterval()
+			if d < 48*time.Second || d > 72*time.Second {
+				t.Fatalf("Unexpected interval: %s", d)
+			}
+			if d != time.Minute {
+				varied = true
+			}
+		}
+		if !varied {
+			t.Errorf("Expected the interval to vary")
+		}
+
+		tp.discoverNodesJitter = 0
+		if d := tp.nextDiscoverNodesInterval(); d != time.Minute {
+			t.Errorf("Unexpected interval without jitter: %s", d)
+		}
+
+		for _, jitter := range []float64{-0.1, 1} {
+			if _, err := New(Config{DiscoverNodesJitter: jitter}); err == nil || !strings.Contains(err.Error(), "invalid discover nodes jitter") {
+				t.Errorf("Unexpected error for jitter %v: %v", jitter, err)
+			}
+		}
+	})
+
 	t.Run("Role based nodes discovery", func(t *testing.T) {
 		type Node struct {
 			URL string
@@ -355,10 +629,10 @@ func TestDiscovery(t *testing.T) {
 				newRoundTripper := func() http.RoundTripper {
 					return &mockTransp{
 						RoundTripFunc: func(req *http.Request) (*http.Response, error) {
-							nodes := make(map[string]map[string]nodeInfo)
-							nodes["nodes"] = make(map[string]nodeInfo)
+							nodes := make(map[string]map[string]NodeInfo)
+							nodes["nodes"] = make(map[string]NodeInfo)
 							for name, node := range tt.args.Nodes {
-								nodes["nodes"][name] = nodeInfo{Roles: node.Roles}
+								nodes["nodes"][name] = NodeInfo{Roles: node.Roles}
 							}
 
 							b, _ := json.Marshal(nodes)
@@ -20,13 +20,18 @@ package estransport
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
 	"regexp"
@@ -34,6 +39,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Tritura/go-elasticsearch/v8/internal/version"
@@ -55,6 +61,20 @@ var (
 
 	defaultMaxRetries    = 3
 	defaultRetryOnStatus = [...]int{502, 503, 504}
+	defaultMaxRetryAfter = 60 * time.Second
+
+	defaultCompressRequestBodyMinSize = 1024
+
+	supportedMediaTypes = map[string]bool{
+		"application/json":                       true,
+		"application/x-ndjson":                   true,
+		"application/vnd.elasticsearch+json":     true,
+		"application/vnd.elasticsearch+x-ndjson": true,
+		"application/cbor":                       true,
+		"application/smile":                      true,
+		"application/yaml":                       true,
+		"text/plain":                             true,
+	}
 )
 
 func init() {
@@ -71,36 +91,119 @@ type Interface interface {
 	Perform(*http.Request) (*http.Response, error)
 }
 
+// ResultPerformer defines the interface for transports reporting the attempts of the requests.
+//
+type ResultPerformer interface {
+	PerformWithResult(*http.Request) (PerformResult, error)
+}
+
+// PerformResult represents the outcome of a request performed with PerformWithResult.
+//
+type PerformResult struct {
+	Response      *http.Response
+	Attempts      int           // The number of attempts, including the retries; 0 when no request was sent.
+	TotalDuration time.Duration // The duration of all attempts, including the backoff delays.
+	LastError     error         // The error of the last failed attempt, or nil when no attempt failed.
+}
+
+// RateLimiter defines the interface for limiting the rate of requests.
+//
+type RateLimiter interface {
+	// Wait blocks until the request is allowed to proceed. It returns an error when the request
+	// is rejected, or when the context is done. The labels are set with WithRequestLabels.
+	Wait(ctx context.Context, labels map[string]string) error
+}
+
+// RoundTripperFunc is an adapter to allow the use of ordinary functions as http.RoundTripper,
+// eg. in the request middleware.
+//
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+//
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 // Config represents the configuration of HTTP client.
 //
 type Config struct {
 	URLs         []*url.URL
+	ReadURLs     []*url.URL
+	WriteURLs    []*url.URL
 	Username     string
 	Password     string
 	APIKey       string
 	ServiceToken string
 
-	Header http.Header
-	CACert []byte
+	Header    http.Header
+	UserAgent string
+	CACert    []byte
+	CACerts   [][]byte
+
+	ContentType string
+	Accept      string
 
 	RetryOnStatus        []int
 	DisableRetry         bool
 	EnableRetryOnTimeout bool
 	MaxRetries           int
 	RetryBackoff         func(attempt int) time.Duration
+	MaxRetryAfter        time.Duration
+
+	RequestSigner       func(*http.Request) error
+	RateLimiter         RateLimiter
+	ResponseInterceptor func(*http.Response)
+	AuditSink           func(ctx context.Context, reqBody, resBody []byte, meta RequestMeta)
 
-	CompressRequestBody bool
+	CompressRequestBody        bool
+	CompressRequestBodyMinSize int
+
+	ForceContentLength bool
+	ForceRefreshFalse  bool
+
+	HedgeAfter time.Duration
+
+	FollowRedirects bool
+
+	DisableKeepAlives   bool
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+
+	ResponseHeaderTimeout time.Duration
+
+	MaxConnectionsPerNode    int
+	MaxRequestsPerConnection int
+
+	TLSMinVersion   uint16
+	TLSCipherSuites []string
+	TLSServerName   string
+
+	WarmupConnections int
+	WarmupTimeout     time.Duration
 
 	EnableMetrics     bool
+	MetricsSampleRate float64
 	EnableDebugLogger bool
 
 	DisableMetaHeader bool
 
-	DiscoverNodesInterval time.Duration
+	PropagateTraceContext bool
 
-	Transport http.RoundTripper
-	Logger    Logger
-	Selector  Selector
+	DiscoverNodesInterval  time.Duration
+	DiscoverNodesJitter    float64
+	DiscoverNodesOnFailure bool
+	MaxDiscoveredNodes     int
+	DiscoverNodesEndpoint  string
+	DiscoverNodesURLFunc   func(NodeInfo) *url.URL
+	OnDiscoveredNodes      func([]NodeInfo) []NodeInfo
+
+	Transport         http.RoundTripper
+	RequestMiddleware []func(next http.RoundTripper) http.RoundTripper
+	Logger            Logger
+	Selector          Selector
+	LoadBalancer      string
 
 	ConnectionPoolFunc func([]*Connection, Selector) ConnectionPool
 }
@@ -116,6 +219,9 @@ type Client struct {
 	apikey       string
 	servicetoken string
 	header       http.Header
+	userAgent    string
+	contentType  string
+	accept       string
 
 	retryOnStatus         []int
 	disableRetry          bool
@@ -123,18 +229,48 @@ type Client struct {
 	disableMetaHeader     bool
 	maxRetries            int
 	retryBackoff          func(attempt int) time.Duration
+	maxRetryAfter         time.Duration
 	discoverNodesInterval time.Duration
+	discoverNodesJitter   float64
 	discoverNodesTimer    *time.Timer
 
-	compressRequestBody bool
+	discoverNodesOnFailure  bool
+	discoverNodesInProgress int32
+	maxDiscoveredNodes      int
+	discoverNodesEndpoint   string
+	discoverNodesURLFunc    func(NodeInfo) *url.URL
+	onDiscoveredNodes       func([]NodeInfo) []NodeInfo
 
-	metrics *metrics
+	requestSigner       func(*http.Request) error
+	rateLimiter         RateLimiter
+	responseInterceptor func(*http.Response)
+	auditSink           func(ctx context.Context, reqBody, resBody []byte, meta RequestMeta)
+
+	compressRequestBody        bool
+	compressRequestBodyMinSize int
+
+	forceContentLength bool
+	forceRefreshFalse  bool
+
+	hedgeAfter time.Duration
+
+	followRedirects bool
+
+	maxRequestsPerConnection int
+
+	propagateTraceContext bool
 
-	transport http.RoundTripper
-	logger    Logger
-	selector  Selector
-	pool      ConnectionPool
-	poolFunc  func([]*Connection, Selector) ConnectionPool
+	metrics *metrics
+	clock   clock
+
+	transport     http.RoundTripper
+	baseTransport http.RoundTripper
+	logger        Logger
+	selector      Selector
+	pool          ConnectionPool
+	readPool      ConnectionPool
+	writePool     ConnectionPool
+	poolFunc      func([]*Connection, Selector) ConnectionPool
 }
 
 // New creates new transport client.
@@ -143,25 +279,67 @@ type Client struct {
 //
 func New(cfg Config) (*Client, error) {
 	if cfg.Transport == nil {
-		cfg.Transport = http.DefaultTransport
+		tp, err := newDefaultTransport(cfg)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Transport = tp
 	}
 
-	if cfg.CACert != nil {
+	if cfg.CACert != nil || cfg.CACerts != nil {
 		httpTransport, ok := cfg.Transport.(*http.Transport)
 		if !ok {
 			return nil, fmt.Errorf("unable to set CA certificate for transport of type %T", cfg.Transport)
 		}
 
 		httpTransport = httpTransport.Clone()
+		if httpTransport.TLSClientConfig == nil {
+			httpTransport.TLSClientConfig = &tls.Config{}
+		}
 		httpTransport.TLSClientConfig.RootCAs = x509.NewCertPool()
 
-		if ok := httpTransport.TLSClientConfig.RootCAs.AppendCertsFromPEM(cfg.CACert); !ok {
-			return nil, errors.New("unable to add CA certificate")
+		certs := cfg.CACerts
+		if cfg.CACert != nil {
+			certs = append([][]byte{cfg.CACert}, certs...)
+		}
+		if err := appendCACerts(httpTransport.TLSClientConfig.RootCAs, certs); err != nil {
+			return nil, fmt.Errorf("unable to add CA certificate: %s", err)
 		}
 
 		cfg.Transport = httpTransport
 	}
 
+	if cfg.ContentType != "" {
+		if err := validateMediaType(cfg.ContentType); err != nil {
+			return nil, fmt.Errorf("invalid content type: %s", err)
+		}
+	}
+
+	if cfg.Accept != "" {
+		if err := validateMediaType(cfg.Accept); err != nil {
+			return nil, fmt.Errorf("invalid accept: %s", err)
+		}
+	}
+
+	if cfg.MetricsSampleRate < 0 || cfg.MetricsSampleRate > 1 {
+		return nil, fmt.Errorf("invalid metrics sample rate: %v", cfg.MetricsSampleRate)
+	}
+
+	if cfg.DiscoverNodesJitter < 0 || cfg.DiscoverNodesJitter >= 1 {
+		return nil, fmt.Errorf("invalid discover nodes jitter: %v", cfg.DiscoverNodesJitter)
+	}
+
+	switch cfg.LoadBalancer {
+	case "", "round-robin":
+	case "least-requests":
+		if cfg.Selector != nil {
+			return nil, errors.New("cannot set both selector and load balancer")
+		}
+		cfg.Selector = NewLeastRequestsSelector()
+	default:
+		return nil, fmt.Errorf("invalid load balancer: %q", cfg.LoadBalancer)
+	}
+
 	if len(cfg.RetryOnStatus) == 0 {
 		cfg.RetryOnStatus = defaultRetryOnStatus[:]
 	}
@@ -170,9 +348,12 @@ func New(cfg Config) (*Client, error) {
 		cfg.MaxRetries = defaultMaxRetries
 	}
 
-	var conns []*Connection
-	for _, u := range cfg.URLs {
-		conns = append(conns, &Connection{URL: u})
+	if cfg.MaxRetryAfter == 0 {
+		cfg.MaxRetryAfter = defaultMaxRetryAfter
+	}
+
+	if cfg.CompressRequestBodyMinSize == 0 {
+		cfg.CompressRequestBodyMinSize = defaultCompressRequestBodyMinSize
 	}
 
 	client := Client{
@@ -182,6 +363,9 @@ func New(cfg Config) (*Client, error) {
 		apikey:       cfg.APIKey,
 		servicetoken: cfg.ServiceToken,
 		header:       cfg.Header,
+		userAgent:    cfg.UserAgent,
+		contentType:  cfg.ContentType,
+		accept:       cfg.Accept,
 
 		retryOnStatus:         cfg.RetryOnStatus,
 		disableRetry:          cfg.DisableRetry,
@@ -189,20 +373,55 @@ func New(cfg Config) (*Client, error) {
 		disableMetaHeader:     cfg.DisableMetaHeader,
 		maxRetries:            cfg.MaxRetries,
 		retryBackoff:          cfg.RetryBackoff,
+		maxRetryAfter:         cfg.MaxRetryAfter,
 		discoverNodesInterval: cfg.DiscoverNodesInterval,
+		discoverNodesJitter:   cfg.DiscoverNodesJitter,
+		discoverNodesEndpoint: cfg.DiscoverNodesEndpoint,
+		discoverNodesURLFunc:  cfg.DiscoverNodesURLFunc,
+		onDiscoveredNodes:     cfg.OnDiscoveredNodes,
+
+		discoverNodesOnFailure: cfg.DiscoverNodesOnFailure,
+		maxDiscoveredNodes:     cfg.MaxDiscoveredNodes,
+
+		requestSigner:       cfg.RequestSigner,
+		rateLimiter:         cfg.RateLimiter,
+		responseInterceptor: cfg.ResponseInterceptor,
+		auditSink:           cfg.AuditSink,
+
+		compressRequestBody:        cfg.CompressRequestBody,
+		compressRequestBodyMinSize: cfg.CompressRequestBodyMinSize,
 
-		compressRequestBody: cfg.CompressRequestBody,
+		forceContentLength: cfg.ForceContentLength,
+		forceRefreshFalse:  cfg.ForceRefreshFalse,
 
-		transport: cfg.Transport,
-		logger:    cfg.Logger,
-		selector:  cfg.Selector,
-		poolFunc:  cfg.ConnectionPoolFunc,
+		hedgeAfter: cfg.HedgeAfter,
+
+		followRedirects: cfg.FollowRedirects,
+
+		maxRequestsPerConnection: cfg.MaxRequestsPerConnection,
+
+		propagateTraceContext: cfg.PropagateTraceContext,
+
+		clock: defaultClock,
+
+		transport:     cfg.Transport,
+		baseTransport: cfg.Transport,
+		logger:        cfg.Logger,
+		selector:      cfg.Selector,
+		poolFunc:      cfg.ConnectionPoolFunc,
 	}
 
-	if client.poolFunc != nil {
-		client.pool = client.poolFunc(conns, client.selector)
-	} else {
-		client.pool, _ = NewConnectionPool(conns, client.selector)
+	// Wrap the transport with the middleware, so the first one is the outermost
+	for i := len(cfg.RequestMiddleware) - 1; i >= 0; i-- {
+		client.transport = cfg.RequestMiddleware[i](client.transport)
+	}
+
+	client.pool = client.newPool(cfg.URLs)
+	if len(cfg.ReadURLs) > 0 {
+		client.readPool = client.newPool(cfg.ReadURLs)
+	}
+	if len(cfg.WriteURLs) > 0 {
+		client.writePool = client.newPool(cfg.WriteURLs)
 	}
 
 	if cfg.EnableDebugLogger {
@@ -210,18 +429,19 @@ func New(cfg Config) (*Client, error) {
 	}
 
 	if cfg.EnableMetrics {
-		client.metrics = &metrics{responses: make(map[int]int)}
-		// TODO(karmi): Type assertion to interface
-		if pool, ok := client.pool.(*singleConnectionPool); ok {
-			pool.metrics = client.metrics
-		}
-		if pool, ok := client.pool.(*statusConnectionPool); ok {
-			pool.metrics = client.metrics
+		client.metrics = &metrics{responses: make(map[int]int), labels: make(map[string]int), sampleRate: cfg.MetricsSampleRate}
+		if client.metrics.sampleRate == 0 {
+			client.metrics.sampleRate = 1
 		}
 	}
+	client.configurePool()
+
+	if cfg.WarmupConnections > 0 {
+		client.warmupConnections(cfg.WarmupConnections, cfg.WarmupTimeout)
+	}
 
 	if client.discoverNodesInterval > 0 {
-		time.AfterFunc(client.discoverNodesInterval, func() {
+		time.AfterFunc(client.nextDiscoverNodesInterval(), func() {
 			client.scheduleDiscoverNodes(client.discoverNodesInterval)
 		})
 	}
@@ -229,9 +449,199 @@ func New(cfg Config) (*Client, error) {
 	return &client, nil
 }
 
+// transportDecompresses returns true when the transport decompresses the responses transparently.
+//
+func (c *Client) transportDecompresses() bool {
+	tp, ok := c.baseTransport.(*http.Transport)
+	return ok && !tp.DisableCompression
+}
+
+// newDefaultTransport returns http.DefaultTransport, or its copy customized by the configuration.
+//
+func newDefaultTransport(cfg Config) (http.RoundTripper, error) {
+	if !cfg.DisableKeepAlives &&
+		cfg.MaxIdleConns == 0 &&
+		cfg.MaxIdleConnsPerHost == 0 &&
+		cfg.IdleConnTimeout == 0 &&
+		cfg.ResponseHeaderTimeout == 0 &&
+		cfg.MaxConnectionsPerNode == 0 &&
+		cfg.TLSMinVersion == 0 &&
+		len(cfg.TLSCipherSuites) == 0 &&
+		cfg.TLSServerName == "" {
+		return http.DefaultTransport, nil
+	}
+
+	defaultTransport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("unable to set connection pool options for transport of type %T", http.DefaultTransport)
+	}
+
+	tp := defaultTransport.Clone()
+	tp.DisableKeepAlives = cfg.DisableKeepAlives
+
+	if cfg.MaxIdleConns > 0 {
+		tp.MaxIdleConns = cfg.MaxIdleConns
+	}
+
+	if cfg.MaxIdleConnsPerHost > 0 {
+		tp.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+
+	if cfg.IdleConnTimeout > 0 {
+		tp.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+
+	if cfg.ResponseHeaderTimeout > 0 {
+		tp.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+
+	if cfg.MaxConnectionsPerNode > 0 {
+		tp.MaxConnsPerHost = cfg.MaxConnectionsPerNode
+	}
+
+	if cfg.TLSMinVersion > 0 || len(cfg.TLSCipherSuites) > 0 || cfg.TLSServerName != "" {
+		if tp.TLSClientConfig == nil {
+			tp.TLSClientConfig = &tls.Config{}
+		}
+
+		if cfg.TLSMinVersion > 0 {
+			switch cfg.TLSMinVersion {
+			case tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13:
+			default:
+				return nil, fmt.Errorf("invalid TLS minimum version: %#04x", cfg.TLSMinVersion)
+			}
+			tp.TLSClientConfig.MinVersion = cfg.TLSMinVersion
+		}
+
+		if len(cfg.TLSCipherSuites) > 0 {
+			ids, err := cipherSuiteIDs(cfg.TLSCipherSuites)
+			if err != nil {
+				return nil, err
+			}
+			tp.TLSClientConfig.CipherSuites = ids
+		}
+
+		if cfg.TLSServerName != "" {
+			tp.TLSClientConfig.ServerName = cfg.TLSServerName
+		}
+	}
+
+	return tp, nil
+}
+
+// cipherSuiteIDs returns the IDs of the TLS cipher suites with the names, eg. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+//
+func cipherSuiteIDs(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+	for _, cs := range tls.InsecureCipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS cipher suite: %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// appendCACerts validates the PEM-encoded certificates and adds them to the pool.
+//
+// Every PEM block must contain a certificate; the blocks are indexed in order across all the inputs.
+//
+func appendCACerts(pool *x509.CertPool, pems [][]byte) error {
+	var idx int
+	for i, data := range pems {
+		var found bool
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			found = true
+
+			if block.Type != "CERTIFICATE" {
+				return fmt.Errorf("invalid certificate at index %d: unexpected PEM block type %q", idx, block.Type)
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return fmt.Errorf("invalid certificate at index %d: %s", idx, err)
+			}
+			pool.AddCert(cert)
+			idx++
+		}
+		if !found {
+			return fmt.Errorf("no PEM data found in input %d", i)
+		}
+	}
+	return nil
+}
+
+// configurePool passes the metrics and the lifecycle logger, when set, to the default connection pool.
+//
+func (c *Client) configurePool() {
+	lifecycleLogger, _ := c.logger.(LifecycleLogger)
+
+	// TODO(karmi): Type assertion to interface
+	for _, p := range []ConnectionPool{c.pool, c.readPool, c.writePool} {
+		if pool, ok := p.(*singleConnectionPool); ok {
+			pool.metrics = c.metrics
+		}
+		if pool, ok := p.(*statusConnectionPool); ok {
+			pool.metrics = c.metrics
+			pool.logger = lifecycleLogger
+			pool.clock = c.clock
+		}
+	}
+}
+
+// newPool returns a connection pool for the URLs, created with the ConnectionPoolFunc, when set.
+//
+func (c *Client) newPool(urls []*url.URL) ConnectionPool {
+	var conns []*Connection
+	for _, u := range urls {
+		conns = append(conns, &Connection{URL: u})
+	}
+
+	if c.poolFunc != nil {
+		return c.poolFunc(conns, c.selector)
+	}
+	pool, _ := NewConnectionPool(conns, c.selector)
+	return pool
+}
+
 // Perform executes the request and returns a response or error.
 //
 func (c *Client) Perform(req *http.Request) (*http.Response, error) {
+	var result PerformResult
+	return c.perform(req, &result)
+}
+
+// PerformWithResult executes the request like Perform, and returns the response
+// with the number of attempts, the total duration and the last error, eg. to measure
+// the amplification of the requests by the retries.
+//
+func (c *Client) PerformWithResult(req *http.Request) (PerformResult, error) {
+	var result PerformResult
+
+	start := c.clock.Now()
+	res, err := c.perform(req, &result)
+	result.Response = res
+	result.TotalDuration = c.clock.Now().Sub(start)
+
+	return result, err
+}
+
+// perform executes the request, recording the attempts in the result.
+//
+func (c *Client) perform(req *http.Request, result *PerformResult) (*http.Response, error) {
 	var (
 		res *http.Response
 		err error
@@ -245,20 +655,75 @@ func (c *Client) Perform(req *http.Request) (*http.Response, error) {
 		req.Header.Set("Accept", "application/vnd.elasticsearch+json;compatible-with=7")
 	}
 
-	// Record metrics, when enabled
+	// Default media types
+	c.setReqMediaTypes(req)
+
+	// Record metrics, when enabled; the metrics requiring a lock are recorded for the sampled requests only
+	var sampled bool
 	if c.metrics != nil {
-		c.metrics.Lock()
-		c.metrics.requests++
-		c.metrics.Unlock()
+		atomic.AddInt64(&c.metrics.requests, 1)
+
+		if sampled = c.metrics.sample(); sampled {
+			c.metrics.Lock()
+			for k, v := range RequestLabels(req.Context()) {
+				c.metrics.labels[k+"="+v]++
+			}
+			c.metrics.Unlock()
+
+			// Trace the reuse of connections
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), c.metrics.clientTrace()))
+		}
+	}
+
+	// Decompress the responses manually, to measure the size of the compressed response bodies
+	decompressResponse := c.metrics != nil && req.Header.Get("Accept-Encoding") == "" && c.transportDecompresses()
+	if decompressResponse {
+		req.Header.Set("Accept-Encoding", "gzip")
 	}
 
 	// Update request
 	c.setReqUserAgent(req)
 	c.setReqGlobalHeader(req)
 	c.setMetaHeader(req)
+	c.setReqTraceContext(req)
+	c.setReqRefresh(req)
+
+	// Stream the request body without buffering it, when requested, disabling the retries
+	streamBody := isBufferingDisabled(req.Context())
+
+	// Buffer the request body for the audit sink, before it's compressed
+	var auditReqBody []byte
+	if c.auditSink != nil && !streamBody {
+		if auditReqBody, err = readAuditRequestBody(req); err != nil {
+			return nil, fmt.Errorf("cannot read request body: %s", err)
+		}
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && !streamBody {
+		compress := c.compressRequestBody && !isCompressionDisabled(req.Context())
+
+		// Skip compression for bodies smaller than the threshold; buffer the body when its length is unknown
+		if compress && c.compressRequestBodyMinSize > 0 {
+			if req.ContentLength <= 0 {
+				var buf bytes.Buffer
+				if _, err := buf.ReadFrom(req.Body); err != nil {
+					return nil, fmt.Errorf("cannot read request body: %s", err)
+				}
 
-	if req.Body != nil && req.Body != http.NoBody {
-		if c.compressRequestBody {
+				req.GetBody = func() (io.ReadCloser, error) {
+					r := buf
+					return ioutil.NopCloser(&r), nil
+				}
+				req.Body, _ = req.GetBody()
+				req.ContentLength = int64(buf.Len())
+			}
+
+			if req.ContentLength < int64(c.compressRequestBodyMinSize) {
+				compress = false
+			}
+		}
+
+		if compress {
 			var buf bytes.Buffer
 			zw := gzip.NewWriter(&buf)
 			if _, err := io.Copy(zw, req.Body); err != nil {
@@ -278,30 +743,47 @@ func (c *Client) Perform(req *http.Request) (*http.Response, error) {
 			req.ContentLength = int64(buf.Len())
 
 		} else if req.GetBody == nil {
-			if !c.disableRetry || (c.logger != nil && c.logger.RequestBodyEnabled()) {
+			if !c.disableRetry || c.forceContentLength || c.followRedirects || (c.logger != nil && c.logger.RequestBodyEnabled()) {
 				var buf bytes.Buffer
-				buf.ReadFrom(req.Body)
+				if _, err := buf.ReadFrom(req.Body); err != nil {
+					return nil, fmt.Errorf("cannot read request body: %s", err)
+				}
 
 				req.GetBody = func() (io.ReadCloser, error) {
 					r := buf
 					return ioutil.NopCloser(&r), nil
 				}
 				req.Body, _ = req.GetBody()
+
+				// Send the length of the buffered body, to prevent the chunked transfer encoding
+				req.ContentLength = int64(buf.Len())
 			}
 		}
 	}
 
+	// Keep the original value, since the request is reused for the retries
+	closeConn := req.Close
+
+	// Send the request to the forced node, when set, bypassing the pool
+	var forcedConn *Connection
+	if u := forceURL(req.Context()); u != nil {
+		forcedConn = &Connection{URL: u}
+	}
+
 	for i := 0; i <= c.maxRetries; i++ {
 		var (
 			conn            *Connection
+			pool            ConnectionPool
 			shouldRetry     bool
 			shouldCloseBody bool
 		)
 
-		// Get connection from the pool
-		c.Lock()
-		conn, err = c.pool.Next()
-		c.Unlock()
+		// Get connection from the pool, for the request type
+		if forcedConn != nil {
+			conn, err = forcedConn, nil
+		} else {
+			conn, pool, err = c.nextConnection(req)
+		}
 		if err != nil {
 			if c.logger != nil {
 				c.logRoundTrip(req, nil, err, time.Time{}, time.Duration(0))
@@ -321,14 +803,73 @@ func (c *Client) Perform(req *http.Request) (*http.Response, error) {
 			req.Body = body
 		}
 
+		// Wait for the rate limiter, when configured
+		if c.rateLimiter != nil {
+			if err := c.rateLimiter.Wait(req.Context(), RequestLabels(req.Context())); err != nil {
+				return nil, fmt.Errorf("rate limiter: %s", err)
+			}
+		}
+
+		// Sign the request, when configured
+		if c.requestSigner != nil {
+			if err := c.requestSigner(req); err != nil {
+				return nil, fmt.Errorf("cannot sign request: %s", err)
+			}
+		}
+
+		// Close the network connection after the request, when the node reached the maximum number of requests
+		req.Close = closeConn
+		if c.maxRequestsPerConnection > 0 && conn.countRequest(c.maxRequestsPerConnection) {
+			req.Close = true
+			if c.metrics != nil {
+				atomic.AddInt64(&c.metrics.recycled, 1)
+			}
+		}
+
+		// Measure the size of the request body, when metrics are enabled
+		if c.metrics != nil && req.Body != nil && req.Body != http.NoBody {
+			req.Body = &countingReadCloser{ReadCloser: req.Body, n: &c.metrics.bytesSent}
+		}
+
 		// Set up time measures and execute the request
-		start := time.Now().UTC()
-		res, err = c.transport.RoundTrip(req)
-		dur := time.Since(start)
+		start := c.clock.Now().UTC()
+		if c.hedgeAfter > 0 && forcedConn == nil && !streamBody && isHedgeable(req) {
+			res, conn, err = c.hedgedRoundTrip(req, conn, pool)
+		} else {
+			res, err = c.roundTrip(req, conn)
+		}
+		dur := c.clock.Now().Sub(start)
+
+		result.Attempts = i + 1
+		if err != nil {
+			result.LastError = err
+		}
+
+		// Measure the size of the response body, when metrics are enabled
+		if c.metrics != nil && res != nil && res.Body != nil && res.Body != http.NoBody {
+			res.Body = &countingReadCloser{ReadCloser: res.Body, n: &c.metrics.bytesReceived}
+			if decompressResponse && strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+				res.Body = &gzipReadCloser{body: res.Body}
+				res.Header.Del("Content-Encoding")
+				res.Header.Del("Content-Length")
+				res.ContentLength = -1
+				res.Uncompressed = true
+			}
+		}
+
+		// Pass the response to the interceptor, when configured
+		if c.responseInterceptor != nil && err == nil && res != nil {
+			c.responseInterceptor(res)
+		}
+
+		// Pass the request and response to the audit sink, when configured
+		if c.auditSink != nil {
+			c.audit(req, auditReqBody, res, err, i+1, dur)
+		}
 
 		// Log request and response
 		if c.logger != nil {
-			if c.logger.RequestBodyEnabled() && req.Body != nil && req.Body != http.NoBody {
+			if c.logger.RequestBodyEnabled() && req.Body != nil && req.Body != http.NoBody && req.GetBody != nil {
 				req.Body, _ = req.GetBody()
 			}
 			c.logRoundTrip(req, res, err, start, dur)
@@ -337,15 +878,15 @@ func (c *Client) Perform(req *http.Request) (*http.Response, error) {
 		if err != nil {
 			// Record metrics, when enabled
 			if c.metrics != nil {
-				c.metrics.Lock()
-				c.metrics.failures++
-				c.metrics.Unlock()
+				atomic.AddInt64(&c.metrics.failures, 1)
 			}
 
 			// Report the connection as unsuccessful
-			c.Lock()
-			c.pool.OnFailure(conn)
-			c.Unlock()
+			if conn != forcedConn {
+				c.Lock()
+				pool.OnFailure(conn)
+				c.Unlock()
+			}
 
 			// Retry on EOF errors
 			if err == io.EOF {
@@ -360,17 +901,27 @@ func (c *Client) Perform(req *http.Request) (*http.Response, error) {
 			}
 		} else {
 			// Report the connection as succesfull
-			c.Lock()
-			c.pool.OnSuccess(conn)
-			c.Unlock()
+			if conn != forcedConn {
+				c.Lock()
+				pool.OnSuccess(conn)
+				c.Unlock()
+			}
 		}
 
-		if res != nil && c.metrics != nil {
+		if res != nil && sampled {
 			c.metrics.Lock()
 			c.metrics.responses[res.StatusCode]++
 			c.metrics.Unlock()
 		}
 
+		// Discover nodes on connection failures and unavailable nodes, when enabled
+		if c.discoverNodesOnFailure {
+			if (err != nil && req.Context().Err() == nil) ||
+				(res != nil && (res.StatusCode == http.StatusBadGateway || res.StatusCode == http.StatusServiceUnavailable)) {
+				c.discoverNodesAsync()
+			}
+		}
+
 		// Retry on configured response statuses
 		if res != nil && !c.disableRetry {
 			for _, code := range c.retryOnStatus {
@@ -382,10 +933,40 @@ func (c *Client) Perform(req *http.Request) (*http.Response, error) {
 		}
 
 		// Break if retry should not be performed
-		if !shouldRetry {
+		if !shouldRetry || streamBody {
 			break
 		}
 
+		// Break if the request context is done, discarding the response
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			if res != nil && res.Body != nil {
+				io.Copy(ioutil.Discard, res.Body)
+				res.Body.Close()
+			}
+			res, err = nil, ctxErr
+			break
+		}
+
+		// Break if the context deadline expires before the backoff delay elapses
+		var backoff time.Duration
+		if i < c.maxRetries {
+			if c.retryBackoff != nil {
+				backoff = c.retryBackoff(i + 1)
+			}
+			// Honor the "Retry-After" header of the retried response, unless it exceeds the maximum
+			var honored bool
+			if shouldCloseBody && (res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable) {
+				if d, ok := retryAfter(res.Header, c.clock.Now()); ok && d <= c.maxRetryAfter {
+					backoff, honored = d, true
+				}
+			}
+			if c.retryBackoff != nil || honored {
+				if deadline, ok := req.Context().Deadline(); ok && deadline.Sub(c.clock.Now()) < backoff {
+					break
+				}
+			}
+		}
+
 		// Drain and close body when retrying after response
 		if shouldCloseBody && i < c.maxRetries {
 			if res.Body != nil {
@@ -395,16 +976,15 @@ func (c *Client) Perform(req *http.Request) (*http.Response, error) {
 		}
 
 		// Delay the retry if a backoff function is configured
-		if c.retryBackoff != nil {
+		if backoff > 0 {
 			var cancelled bool
-			backoff := c.retryBackoff(i + 1)
-			timer := time.NewTimer(backoff)
+			timer := c.clock.NewTimer(backoff)
 			select {
 			case <-req.Context().Done():
 				err = req.Context().Err()
 				cancelled = true
 				timer.Stop()
-			case <-timer.C:
+			case <-timer.C():
 			}
 			if cancelled {
 				break
@@ -412,6 +992,10 @@ func (c *Client) Perform(req *http.Request) (*http.Response, error) {
 		}
 	}
 
+	if err != nil {
+		result.LastError = err
+	}
+
 	// TODO(karmi): Wrap error
 	return res, err
 }
@@ -423,6 +1007,32 @@ func (c *Client) URLs() []*url.URL {
 	return c.pool.URLs()
 }
 
+// retryAfter returns the delay from the "Retry-After" header, in seconds or as an HTTP date,
+// and whether the header is present and valid.
+//
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
 func (c *Client) setReqURL(u *url.URL, req *http.Request) *http.Request {
 	req.URL.Scheme = u.Scheme
 	req.URL.Host = u.Host
@@ -433,6 +1043,11 @@ func (c *Client) setReqURL(u *url.URL, req *http.Request) *http.Request {
 		b.WriteString(u.Path)
 		b.WriteString(req.URL.Path)
 		req.URL.Path = b.String()
+
+		// Keep the escaped path in sync, eg. for document IDs containing a slash
+		if req.URL.RawPath != "" {
+			req.URL.RawPath = u.EscapedPath() + req.URL.RawPath
+		}
 	}
 
 	return req
@@ -474,10 +1089,27 @@ func (c *Client) setReqAuth(u *url.URL, req *http.Request) *http.Request {
 }
 
 func (c *Client) setReqUserAgent(req *http.Request) *http.Request {
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+		return req
+	}
 	req.Header.Set("User-Agent", userAgent)
 	return req
 }
 
+func (c *Client) setReqMediaTypes(req *http.Request) *http.Request {
+	// Replace the default JSON content type only, keeping the explicit ones, eg. "text/csv"
+	if c.contentType != "" && req.Body != nil && req.Body != http.NoBody {
+		if ct := req.Header.Get("Content-Type"); ct == "" || ct == "application/json" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+	}
+	if c.accept != "" && req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", c.accept)
+	}
+	return req
+}
+
 func (c *Client) setReqGlobalHeader(req *http.Request) *http.Request {
 	if len(c.header) > 0 {
 		for k, v := range c.header {
@@ -506,6 +1138,26 @@ func (c *Client) setMetaHeader(req *http.Request) *http.Request {
 	return req
 }
 
+// setReqRefresh replaces the "refresh" parameter of the write requests with "false",
+// when the ForceRefreshFalse option is enabled, unless the context allows the refresh.
+//
+// The write requests are recognized by the method and path only, regardless of the routing
+// set with WithReadRequest or WithWriteRequest.
+//
+func (c *Client) setReqRefresh(req *http.Request) *http.Request {
+	if !c.forceRefreshFalse || req.URL == nil || isRefreshAllowed(req.Context()) || !isWriteOperation(req) {
+		return req
+	}
+
+	q := req.URL.Query()
+	if v, ok := q["refresh"]; !ok || (len(v) == 1 && v[0] == "false") {
+		return req
+	}
+	q.Set("refresh", "false")
+	req.URL.RawQuery = q.Encode()
+	return req
+}
+
 func (c *Client) logRoundTrip(
 	req *http.Request,
 	res *http.Response,
@@ -549,3 +1201,16 @@ func initUserAgent() string {
 
 	return b.String()
 }
+
+// validateMediaType returns an error when the media type is not supported by Elasticsearch.
+//
+func validateMediaType(v string) error {
+	mediaType, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return err
+	}
+	if !supportedMediaTypes[mediaType] {
+		return fmt.Errorf("unsupported media type %q", mediaType)
+	}
+	return nil
+}
@@ -23,14 +23,21 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -146,6 +153,260 @@ func TestTransportConfig(t *testing.T) {
 			t.Errorf("Unexpected compressRequestBody: %v", tp.compressRequestBody)
 		}
 	})
+
+	t.Run("Default transport", func(t *testing.T) {
+		tp, _ := New(Config{})
+
+		if tp.transport != http.DefaultTransport {
+			t.Errorf("Expected http.DefaultTransport, got: %T", tp.transport)
+		}
+	})
+
+	t.Run("Disable keep-alives", func(t *testing.T) {
+		tp, _ := New(Config{DisableKeepAlives: true})
+
+		httpTransport, ok := tp.transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("Unexpected transport: %T", tp.transport)
+		}
+		if !httpTransport.DisableKeepAlives {
+			t.Errorf("Expected keep-alives to be disabled")
+		}
+		if http.DefaultTransport.(*http.Transport).DisableKeepAlives {
+			t.Errorf("Unexpected modification of http.DefaultTransport")
+		}
+
+		custom := &mockTransp{}
+		tp, _ = New(Config{DisableKeepAlives: true, Transport: custom})
+		if tp.transport != custom {
+			t.Errorf("Expected custom transport to be left intact, got: %T", tp.transport)
+		}
+	})
+
+	t.Run("Idle connection pool sizing", func(t *testing.T) {
+		tp, _ := New(Config{
+			MaxIdleConns:        500,
+			MaxIdleConnsPerHost: 100,
+			IdleConnTimeout:     5 * time.Second,
+		})
+
+		httpTransport, ok := tp.transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("Unexpected transport: %T", tp.transport)
+		}
+		if httpTransport.MaxIdleConns != 500 {
+			t.Errorf("Unexpected MaxIdleConns: %d", httpTransport.MaxIdleConns)
+		}
+		if httpTransport.MaxIdleConnsPerHost != 100 {
+			t.Errorf("Unexpected MaxIdleConnsPerHost: %d", httpTransport.MaxIdleConnsPerHost)
+		}
+		if httpTransport.IdleConnTimeout != 5*time.Second {
+			t.Errorf("Unexpected IdleConnTimeout: %s", httpTransport.IdleConnTimeout)
+		}
+		if httpTransport.DisableKeepAlives {
+			t.Errorf("Unexpected DisableKeepAlives: %v", httpTransport.DisableKeepAlives)
+		}
+
+		custom := &mockTransp{}
+		tp, _ = New(Config{MaxIdleConnsPerHost: 100, Transport: custom})
+		if tp.transport != custom {
+			t.Errorf("Expected custom transport to be left intact, got: %T", tp.transport)
+		}
+	})
+
+	t.Run("Response header timeout", func(t *testing.T) {
+		tp, _ := New(Config{ResponseHeaderTimeout: 30 * time.Second})
+
+		httpTransport, ok := tp.transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("Unexpected transport: %T", tp.transport)
+		}
+		if httpTransport.ResponseHeaderTimeout != 30*time.Second {
+			t.Errorf("Unexpected ResponseHeaderTimeout: %s", httpTransport.ResponseHeaderTimeout)
+		}
+
+		custom := &mockTransp{}
+		tp, _ = New(Config{ResponseHeaderTimeout: 30 * time.Second, Transport: custom})
+		if tp.transport != custom {
+			t.Errorf("Expected custom transport to be left intact, got: %T", tp.transport)
+		}
+	})
+
+	t.Run("Maximum connections per node", func(t *testing.T) {
+		tp, _ := New(Config{MaxConnectionsPerNode: 10})
+
+		httpTransport, ok := tp.transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("Unexpected transport: %T", tp.transport)
+		}
+		if httpTransport.MaxConnsPerHost != 10 {
+			t.Errorf("Unexpected MaxConnsPerHost: %d", httpTransport.MaxConnsPerHost)
+		}
+	})
+
+	t.Run("TLS options", func(t *testing.T) {
+		tp, err := New(Config{
+			TLSMinVersion:   tls.VersionTLS12,
+			TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		httpTransport, ok := tp.transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("Unexpected transport: %T", tp.transport)
+		}
+		if httpTransport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+			t.Errorf("Unexpected MinVersion: %#04x", httpTransport.TLSClientConfig.MinVersion)
+		}
+		ciphers := httpTransport.TLSClientConfig.CipherSuites
+		if len(ciphers) != 2 || ciphers[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || ciphers[1] != tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384 {
+			t.Errorf("Unexpected CipherSuites: %v", ciphers)
+		}
+		if dt := http.DefaultTransport.(*http.Transport); dt.TLSClientConfig != nil && dt.TLSClientConfig.MinVersion != 0 {
+			t.Errorf("Unexpected modification of http.DefaultTransport")
+		}
+
+		if _, err := New(Config{TLSCipherSuites: []string{"TLS_FOO"}}); err == nil || !strings.Contains(err.Error(), "unknown TLS cipher suite") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if _, err := New(Config{TLSMinVersion: 1}); err == nil || !strings.Contains(err.Error(), "invalid TLS minimum version") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("TLS server name", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+		server.StartTLS()
+		defer server.Close()
+
+		cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		u, _ := url.Parse(server.URL)
+
+		for _, tt := range []struct {
+			serverName string
+			wantErr    bool
+		}{
+			{"example.com", false},
+			{"foo.org", true},
+		} {
+			tp, err := New(Config{URLs: []*url.URL{u}, CACert: cert, TLSServerName: tt.serverName, DisableRetry: true})
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			req, _ := http.NewRequest("GET", "/", nil)
+			res, err := tp.Perform(req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unexpected error for server name %q: %v", tt.serverName, err)
+			}
+			if res != nil {
+				res.Body.Close()
+			}
+		}
+	})
+
+	t.Run("CA certificate with transport without TLS configuration", func(t *testing.T) {
+		cert, err := ioutil.ReadFile("testdata/cert.pem")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		tp, err := New(Config{CACert: cert, Transport: &http.Transport{}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if tp.transport.(*http.Transport).TLSClientConfig.RootCAs == nil {
+			t.Errorf("Expected RootCAs to be set")
+		}
+	})
+
+	t.Run("Multiple CA certificates", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+		server.StartTLS()
+		defer server.Close()
+
+		u, _ := url.Parse(server.URL)
+		oldCert, err := ioutil.ReadFile("testdata/cert.pem")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		newCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+		for _, cfg := range []Config{
+			{CACerts: [][]byte{oldCert, newCert}},
+			{CACert: append(append([]byte{}, oldCert...), newCert...)},
+			{CACert: oldCert, CACerts: [][]byte{newCert}},
+		} {
+			cfg.URLs = []*url.URL{u}
+			cfg.DisableRetry = true
+
+			tp, err := New(cfg)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if n := len(tp.transport.(*http.Transport).TLSClientConfig.RootCAs.Subjects()); n != 2 {
+				t.Errorf("Unexpected number of certificates in the pool: %d", n)
+			}
+
+			req, _ := http.NewRequest("GET", "/", nil)
+			res, err := tp.Perform(req)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+				continue
+			}
+			res.Body.Close()
+		}
+	})
+
+	t.Run("Invalid CA certificates", func(t *testing.T) {
+		cert, err := ioutil.ReadFile("testdata/cert.pem")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		invalid := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("foo")})
+		key := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("foo")})
+
+		for _, tt := range []struct {
+			cfg Config
+			err string
+		}{
+			{Config{CACerts: [][]byte{cert, invalid}}, "invalid certificate at index 1"},
+			{Config{CACert: append(append([]byte{}, cert...), invalid...)}, "invalid certificate at index 1"},
+			{Config{CACert: key}, `invalid certificate at index 0: unexpected PEM block type "PRIVATE KEY"`},
+			{Config{CACerts: [][]byte{cert, []byte("foo")}}, "no PEM data found in input 1"},
+		} {
+			_, err := New(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Unexpected error, want=%q, got=%v", tt.err, err)
+			}
+		}
+	})
+
+	t.Run("Replaced default transport", func(t *testing.T) {
+		defaultTransport := http.DefaultTransport
+		http.DefaultTransport = &mockTransp{}
+		defer func() { http.DefaultTransport = defaultTransport }()
+
+		_, err := New(Config{MaxIdleConnsPerHost: 100})
+		if err == nil {
+			t.Fatalf("Expected error, got: %v", err)
+		}
+		if !strings.Contains(err.Error(), "unable to set connection pool options") {
+			t.Errorf("Unexpected error: %s", err)
+		}
+
+		tp, err := New(Config{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if tp.transport != http.DefaultTransport {
+			t.Errorf("Expected the default transport, got: %T", tp.transport)
+		}
+	})
 }
 
 func TestTransportConnectionPool(t *testing.T) {
@@ -398,6 +659,61 @@ func TestTransportPerform(t *testing.T) {
 		}
 	})
 
+	t.Run("Sets custom UserAgent", func(t *testing.T) {
+		u, _ := url.Parse("http://example.com")
+		tp, _ := New(Config{URLs: []*url.URL{u}, UserAgent: "my-app/1.0"})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		tp.setReqUserAgent(req)
+
+		if req.UserAgent() != "my-app/1.0" {
+			t.Errorf("Unexpected user agent: %s", req.UserAgent())
+		}
+	})
+
+	t.Run("Sets default media types", func(t *testing.T) {
+		tp, err := New(Config{
+			URLs:        []*url.URL{{}},
+			ContentType: "application/vnd.elasticsearch+json; compatible-with=8",
+			Accept:      "application/json",
+			Transport:   &mockTransp{},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		req, _ := http.NewRequest("POST", "/abc", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		tp.setReqMediaTypes(req)
+
+		if v := req.Header.Get("Content-Type"); v != "application/vnd.elasticsearch+json; compatible-with=8" {
+			t.Errorf("Unexpected Content-Type: %s", v)
+		}
+		if v := req.Header.Get("Accept"); v != "application/json" {
+			t.Errorf("Unexpected Accept: %s", v)
+		}
+
+		req, _ = http.NewRequest("GET", "/abc", nil)
+		req.Header.Set("Accept", "text/plain")
+		tp.setReqMediaTypes(req)
+
+		if v := req.Header.Get("Content-Type"); v != "" {
+			t.Errorf("Unexpected Content-Type for request without body: %s", v)
+		}
+		if v := req.Header.Get("Accept"); v != "text/plain" {
+			t.Errorf("Expected the explicit Accept to be kept, got: %s", v)
+		}
+	})
+
+	t.Run("Rejects unsupported media types", func(t *testing.T) {
+		if _, err := New(Config{ContentType: "text/html"}); err == nil {
+			t.Errorf("Expected error for unsupported Content-Type")
+		}
+		if _, err := New(Config{Accept: "application/json;;"}); err == nil {
+			t.Errorf("Expected error for invalid Accept")
+		}
+	})
+
 	t.Run("Sets global HTTP request headers", func(t *testing.T) {
 		hdr := http.Header{}
 		hdr.Set("X-Foo", "bar")
@@ -817,6 +1133,184 @@ func TestTransportPerformRetries(t *testing.T) {
 			t.Fatalf("unexpected number of requests: expected 1, got got %d", i)
 		}
 	})
+
+	t.Run("Don't delay the retry beyond the context deadline", func(t *testing.T) {
+		var i int
+		u, _ := url.Parse("http://foo.bar")
+		tp, _ := New(Config{
+			RetryBackoff: func(i int) time.Duration { return time.Hour },
+			URLs:         []*url.URL{u},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					i++
+					return &http.Response{
+						StatusCode: http.StatusBadGateway,
+						Body:       ioutil.NopCloser(strings.NewReader("MOCK")),
+					}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		ctx, cancel := context.WithTimeout(req.Context(), time.Minute)
+		defer cancel()
+		req = req.WithContext(ctx)
+
+		start := time.Now()
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("Unexpected duration: %s", time.Since(start))
+		}
+		if i != 1 {
+			t.Errorf("Unexpected number of requests, want=1, got=%d", i)
+		}
+		if res.StatusCode != http.StatusBadGateway {
+			t.Errorf("Unexpected response status: %d", res.StatusCode)
+		}
+		if body, _ := ioutil.ReadAll(res.Body); string(body) != "MOCK" {
+			t.Errorf("Expected the response body to be readable, got: %q", body)
+		}
+	})
+
+	t.Run("Don't delay after the last attempt", func(t *testing.T) {
+		var (
+			i       int
+			backoff int
+		)
+		u, _ := url.Parse("http://foo.bar")
+		tp, _ := New(Config{
+			MaxRetries: 2,
+			RetryBackoff: func(i int) time.Duration {
+				backoff++
+				return time.Millisecond
+			},
+			URLs: []*url.URL{u},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					i++
+					return nil, &mockNetError{error: fmt.Errorf("Mock network error (%d)", i)}
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		tp.Perform(req)
+
+		if i != 3 {
+			t.Errorf("Unexpected number of requests, want=3, got=%d", i)
+		}
+		if backoff != 2 {
+			t.Errorf("Unexpected number of backoff calls, want=2, got=%d", backoff)
+		}
+	})
+
+	t.Run("Don't retry when the context is cancelled", func(t *testing.T) {
+		var i int
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		u, _ := url.Parse("http://foo.bar")
+		tp, _ := New(Config{
+			MaxRetries: 10,
+			URLs:       []*url.URL{u},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					i++
+					cancel()
+					return nil, &mockNetError{error: fmt.Errorf("Mock network error (%d)", i)}
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		req = req.WithContext(ctx)
+
+		res, err := tp.Perform(req)
+		if err != context.Canceled {
+			t.Fatalf("Expected context.Canceled, got: %v", err)
+		}
+		if res != nil {
+			t.Errorf("Unexpected response: %+v", res)
+		}
+		if i != 1 {
+			t.Errorf("Unexpected number of requests, want=1, got=%d", i)
+		}
+	})
+
+}
+
+func TestTransportPerformContext(t *testing.T) {
+	t.Run("Cancel in-flight request", func(t *testing.T) {
+		done := make(chan struct{})
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(ioutil.Discard, r.Body)
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+		}))
+		defer server.Close()
+		defer close(done)
+
+		u, _ := url.Parse(server.URL)
+		tp, _ := New(Config{
+			URLs:                 []*url.URL{u},
+			Transport:            &http.Transport{},
+			EnableRetryOnTimeout: true,
+			RetryBackoff:         func(i int) time.Duration { return time.Millisecond },
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		req, _ := http.NewRequest("POST", "/abc", strings.NewReader(`{"query":{}}`))
+		req = req.WithContext(ctx)
+
+		start := time.Now()
+		_, err := tp.Perform(req)
+		if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+			t.Fatalf("Expected context.Canceled, got: %v", err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("Expected the request to be aborted promptly, took: %s", d)
+		}
+	})
+
+	t.Run("Context is attached during retries", func(t *testing.T) {
+		type ctxKey string
+
+		var contexts []interface{}
+
+		u, _ := url.Parse("http://foo.bar")
+		tp, _ := New(Config{
+			URLs:          []*url.URL{u},
+			EnableMetrics: true,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					contexts = append(contexts, req.Context().Value(ctxKey("foo")))
+					return &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		req = req.WithContext(context.WithValue(req.Context(), ctxKey("foo"), "bar"))
+		tp.Perform(req)
+
+		if len(contexts) != 4 {
+			t.Fatalf("Unexpected number of requests, want=4, got=%d", len(contexts))
+		}
+		for i, v := range contexts {
+			if v != "bar" {
+				t.Errorf("Unexpected context value for attempt %d: %v", i+1, v)
+			}
+		}
+	})
 }
 
 func TestURLs(t *testing.T) {
@@ -959,10 +1453,177 @@ func TestMaxRetries(t *testing.T) {
 	}
 }
 
-func TestCompatibilityHeader(t *testing.T) {
-	tests := []struct {
-		name                string
-		compatibilityHeader bool
+func TestPerformWithResult(t *testing.T) {
+	t.Run("Retries", func(t *testing.T) {
+		var i int
+		c, _ := New(Config{
+			URLs: []*url.URL{{Scheme: "http", Host: "foo"}},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					i++
+					switch i {
+					case 1:
+						return nil, &mockNetError{error: fmt.Errorf("Mock network error (%d)", i)}
+					case 2:
+						return &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+					default:
+						return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("OK"))}, nil
+					}
+				},
+			},
+			RetryBackoff: func(attempt int) time.Duration { return time.Millisecond },
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		result, err := c.PerformWithResult(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if result.Response == nil || result.Response.StatusCode != http.StatusOK {
+			t.Errorf("Unexpected response: %+v", result.Response)
+		}
+		if result.Attempts != 3 {
+			t.Errorf("Unexpected attempts: want=3, got=%d", result.Attempts)
+		}
+		if result.LastError == nil || !strings.Contains(result.LastError.Error(), "Mock network error (1)") {
+			t.Errorf("Unexpected last error: %v", result.LastError)
+		}
+		if result.TotalDuration < 2*time.Millisecond {
+			t.Errorf("Unexpected total duration: %s", result.TotalDuration)
+		}
+	})
+
+	t.Run("Single attempt", func(t *testing.T) {
+		c, _ := New(Config{
+			URLs: []*url.URL{{Scheme: "http", Host: "foo"}},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("OK"))}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		result, err := c.PerformWithResult(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if result.Attempts != 1 || result.LastError != nil {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		c, _ := New(Config{
+			URLs:       []*url.URL{{Scheme: "http", Host: "foo"}},
+			MaxRetries: 2,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					return nil, &mockNetError{error: fmt.Errorf("Mock network error")}
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		result, err := c.PerformWithResult(req)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+		if result.Attempts != 3 || result.LastError != err || result.Response != nil {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+}
+
+func TestMaxRetryAfter(t *testing.T) {
+	newClient := func(retryAfter string, maxRetryAfter time.Duration, calls *int) *Client {
+		c, _ := New(Config{
+			URLs:          []*url.URL{{Scheme: "http", Host: "foo"}},
+			RetryOnStatus: []int{429},
+			MaxRetries:    1,
+			RetryBackoff:  func(attempt int) time.Duration { return time.Millisecond },
+			MaxRetryAfter: maxRetryAfter,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					*calls++
+					return &http.Response{
+						StatusCode: http.StatusTooManyRequests,
+						Header:     http.Header{"Retry-After": []string{retryAfter}},
+						Body:       ioutil.NopCloser(strings.NewReader("")),
+					}, nil
+				},
+			},
+		})
+		return c
+	}
+
+	// The delay longer than the context deadline stops the retries, so the honored delay isn't awaited
+	perform := func(c *Client) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, _ := http.NewRequest("GET", "/", nil)
+		c.Perform(req.WithContext(ctx))
+	}
+
+	t.Run("Honored", func(t *testing.T) {
+		var calls int
+		perform(newClient("30", 0, &calls))
+		if calls != 1 {
+			t.Errorf("Expected the Retry-After delay to be honored, got %d calls", calls)
+		}
+	})
+
+	t.Run("Above the maximum", func(t *testing.T) {
+		var calls int
+		perform(newClient("3600", 0, &calls))
+		if calls != 2 {
+			t.Errorf("Expected the fallback to the backoff, got %d calls", calls)
+		}
+	})
+
+	t.Run("Custom maximum", func(t *testing.T) {
+		var calls int
+		perform(newClient("30", 10*time.Second, &calls))
+		if calls != 2 {
+			t.Errorf("Expected the fallback to the backoff, got %d calls", calls)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		var calls int
+		perform(newClient("30", -1, &calls))
+		if calls != 2 {
+			t.Errorf("Expected the header to be ignored, got %d calls", calls)
+		}
+	})
+
+	t.Run("Parse", func(t *testing.T) {
+		now := time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC)
+		for _, tt := range []struct {
+			value string
+			want  time.Duration
+			ok    bool
+		}{
+			{"", 0, false},
+			{"120", 2 * time.Minute, true},
+			{"-1", 0, false},
+			{"Mon, 03 May 2021 10:00:30 GMT", 30 * time.Second, true},
+			{"Mon, 03 May 2021 09:00:00 GMT", 0, true},
+			{"foo", 0, false},
+		} {
+			d, ok := retryAfter(http.Header{"Retry-After": []string{tt.value}}, now)
+			if d != tt.want || ok != tt.ok {
+				t.Errorf("Unexpected result for %q: %s, %v", tt.value, d, ok)
+			}
+		}
+	})
+}
+
+func TestCompatibilityHeader(t *testing.T) {
+	tests := []struct {
+		name                string
+		compatibilityHeader bool
 		bodyPresent         bool
 		expectsHeader       []string
 	}{
@@ -1030,25 +1691,66 @@ func TestRequestCompression(t *testing.T) {
 	tests := []struct {
 		name            string
 		compressionFlag bool
+		minSize         int
+		withoutCompress bool
 		inputBody       string
+		wantCompressed  bool
 	}{
 		{
 			name:            "Uncompressed",
 			compressionFlag: false,
 			inputBody:       "elasticsearch",
+			wantCompressed:  false,
 		},
 		{
 			name:            "Compressed",
 			compressionFlag: true,
+			minSize:         -1,
+			inputBody:       "elasticsearch",
+			wantCompressed:  true,
+		},
+		{
+			name:            "Uncompressed below default minimum size",
+			compressionFlag: true,
+			inputBody:       "elasticsearch",
+			wantCompressed:  false,
+		},
+		{
+			name:            "Compressed above default minimum size",
+			compressionFlag: true,
+			inputBody:       strings.Repeat("elasticsearch", 100),
+			wantCompressed:  true,
+		},
+		{
+			name:            "Uncompressed with context",
+			compressionFlag: true,
+			minSize:         -1,
+			withoutCompress: true,
+			inputBody:       "elasticsearch",
+			wantCompressed:  false,
+		},
+		{
+			name:            "Uncompressed below minimum size",
+			compressionFlag: true,
+			minSize:         100,
+			inputBody:       "elasticsearch",
+			wantCompressed:  false,
+		},
+		{
+			name:            "Compressed above minimum size",
+			compressionFlag: true,
+			minSize:         10,
 			inputBody:       "elasticsearch",
+			wantCompressed:  true,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			tp, _ := New(Config{
-				URLs:                []*url.URL{{}},
-				CompressRequestBody: test.compressionFlag,
+				URLs:                       []*url.URL{{}},
+				CompressRequestBody:        test.compressionFlag,
+				CompressRequestBodyMinSize: test.minSize,
 				Transport: &mockTransp{
 					RoundTripFunc: func(req *http.Request) (*http.Response, error) {
 						if req.Body == nil || req.Body == http.NoBody {
@@ -1062,7 +1764,11 @@ func TestRequestCompression(t *testing.T) {
 							return nil, fmt.Errorf("mismatched Content-Length: %d vs actual %d", req.ContentLength, buf.Len())
 						}
 
-						if test.compressionFlag {
+						if (req.Header.Get("Content-Encoding") == "gzip") != test.wantCompressed {
+							return nil, fmt.Errorf("unexpected Content-Encoding: %q", req.Header.Get("Content-Encoding"))
+						}
+
+						if test.wantCompressed {
 							var unBuf bytes.Buffer
 							zr, err := gzip.NewReader(&buf)
 							if err != nil {
@@ -1082,6 +1788,9 @@ func TestRequestCompression(t *testing.T) {
 			})
 
 			req, _ := http.NewRequest("POST", "/abc", bytes.NewBufferString(test.inputBody))
+			if test.withoutCompress {
+				req = req.WithContext(WithoutCompression(req.Context()))
+			}
 
 			res, err := tp.Perform(req)
 			if err != nil {
@@ -1093,4 +1802,601 @@ func TestRequestCompression(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Unknown body length below minimum size", func(t *testing.T) {
+		var encoding string
+
+		tp, _ := New(Config{
+			URLs:                       []*url.URL{{}},
+			CompressRequestBody:        true,
+			CompressRequestBodyMinSize: 100,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					encoding = req.Header.Get("Content-Encoding")
+					return &http.Response{Status: "MOCK"}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("POST", "/abc", ioutil.NopCloser(strings.NewReader("elasticsearch")))
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if encoding != "" {
+			t.Errorf("Unexpected Content-Encoding: %q", encoding)
+		}
+		if req.ContentLength != int64(len("elasticsearch")) {
+			t.Errorf("Unexpected Content-Length: %d", req.ContentLength)
+		}
+	})
+
+	t.Run("Unknown body length with read error", func(t *testing.T) {
+		tp, _ := New(Config{
+			URLs:                       []*url.URL{{}},
+			CompressRequestBody:        true,
+			CompressRequestBodyMinSize: 100,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					t.Fatalf("Unexpected request")
+					return nil, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("POST", "/abc", &ErrorReader{r: strings.NewReader("elasticsearch")})
+		_, err := tp.Perform(req)
+		if err == nil || !strings.Contains(err.Error(), "cannot read request body") {
+			t.Errorf("Expected read error, got: %v", err)
+		}
+	})
+}
+
+func TestWithoutBuffering(t *testing.T) {
+	var (
+		i        int
+		body     string
+		encoding string
+		getBody  bool
+	)
+
+	tp, _ := New(Config{
+		URLs:                       []*url.URL{{}},
+		CompressRequestBody:        true,
+		CompressRequestBodyMinSize: -1,
+		RetryOnStatus:              []int{http.StatusServiceUnavailable},
+		Transport: &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				i++
+				b, _ := ioutil.ReadAll(req.Body)
+				body, encoding, getBody = string(b), req.Header.Get("Content-Encoding"), req.GetBody != nil
+				return &http.Response{Status: "MOCK", StatusCode: http.StatusServiceUnavailable}, nil
+			},
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "/_search", ioutil.NopCloser(strings.NewReader(`{"query":{}}`)))
+	req = req.WithContext(WithoutBuffering(req.Context()))
+
+	res, err := tp.Perform(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected response: %+v", res)
+	}
+	if i != 1 {
+		t.Errorf("Unexpected number of requests, want=1, got=%d", i)
+	}
+	if body != `{"query":{}}` {
+		t.Errorf("Unexpected body: %s", body)
+	}
+	if encoding != "" {
+		t.Errorf("Unexpected Content-Encoding: %q", encoding)
+	}
+	if getBody {
+		t.Errorf("Expected the request body to not be buffered")
+	}
+}
+
+func TestForceRefreshFalse(t *testing.T) {
+	var query string
+
+	tp, _ := New(Config{
+		URLs:              []*url.URL{{}},
+		ForceRefreshFalse: true,
+		Transport: &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				query = req.URL.RawQuery
+				return &http.Response{Status: "MOCK"}, nil
+			},
+		},
+	})
+
+	for _, tt := range []struct {
+		name    string
+		method  string
+		path    string
+		refresh bool
+		want    string
+	}{
+		{"Index", "PUT", "/test/_doc/1?refresh=true", false, "refresh=false"},
+		{"Bulk", "POST", "/_bulk?refresh=wait_for&timeout=1m", false, "refresh=false&timeout=1m"},
+		{"Empty value", "DELETE", "/test/_doc/1?refresh", false, "refresh=false"},
+		{"Already false", "POST", "/test/_doc?refresh=false", false, "refresh=false"},
+		{"Without refresh", "POST", "/test/_doc?routing=foo", false, "routing=foo"},
+		{"Read request", "GET", "/test/_doc/1?refresh=true", false, "refresh=true"},
+		{"Allowed refresh", "PUT", "/test/_doc/1?refresh=true", true, "refresh=true"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			if tt.refresh {
+				req = req.WithContext(WithRefresh(req.Context()))
+			}
+			if _, err := tp.Perform(req); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if query != tt.want {
+				t.Errorf("Unexpected query, want=%q, got=%q", tt.want, query)
+			}
+		})
+	}
+
+	t.Run("Routing override", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/test/_doc/1?refresh=true", nil)
+		req = req.WithContext(WithReadRequest(req.Context()))
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if query != "refresh=false" {
+			t.Errorf("Unexpected query for a write routed as a read: %q", query)
+		}
+
+		req, _ = http.NewRequest("GET", "/test/_doc/1?refresh=true", nil)
+		req = req.WithContext(WithWriteRequest(req.Context()))
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if query != "refresh=true" {
+			t.Errorf("Unexpected query for a read routed as a write: %q", query)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		tp, _ := New(Config{
+			URLs: []*url.URL{{}},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					query = req.URL.RawQuery
+					return &http.Response{Status: "MOCK"}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("PUT", "/test/_doc/1?refresh=true", nil)
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if query != "refresh=true" {
+			t.Errorf("Unexpected query: %q", query)
+		}
+	})
+}
+
+func TestRequestSigner(t *testing.T) {
+	t.Run("Sign every attempt", func(t *testing.T) {
+		var (
+			i          int
+			signatures []string
+		)
+
+		u, _ := url.Parse("http://foo.bar")
+		tp, _ := New(Config{
+			URLs: []*url.URL{u},
+			RequestSigner: func(req *http.Request) error {
+				body, err := ioutil.ReadAll(req.Body)
+				if err != nil {
+					return err
+				}
+				req.Body = ioutil.NopCloser(bytes.NewReader(body))
+				req.Header.Set("X-Signature", fmt.Sprintf("%s %s %s %d", req.Method, req.URL.Path, body, len(signatures)))
+				signatures = append(signatures, req.Header.Get("X-Signature"))
+				return nil
+			},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					i++
+					if req.Header.Get("X-Signature") != signatures[len(signatures)-1] {
+						t.Errorf("Unexpected signature: %s", req.Header.Get("X-Signature"))
+					}
+					if i < 2 {
+						return &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("POST", "/abc", strings.NewReader("{}"))
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		expected := []string{"POST /abc {} 0", "POST /abc {} 1"}
+		if !reflect.DeepEqual(signatures, expected) {
+			t.Errorf("Unexpected signatures:\nwant: %q\ngot:  %q", expected, signatures)
+		}
+	})
+
+	t.Run("Signer error", func(t *testing.T) {
+		tp, _ := New(Config{
+			URLs:          []*url.URL{{}},
+			RequestSigner: func(req *http.Request) error { return errors.New("MOCK ERROR") },
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					t.Fatalf("Unexpected request")
+					return nil, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		_, err := tp.Perform(req)
+		if err == nil || !strings.Contains(err.Error(), "cannot sign request: MOCK ERROR") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestRequestMiddleware(t *testing.T) {
+	t.Run("Order and retries", func(t *testing.T) {
+		var (
+			i     int
+			calls []string
+		)
+
+		middleware := func(name string) func(http.RoundTripper) http.RoundTripper {
+			return func(next http.RoundTripper) http.RoundTripper {
+				return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					calls = append(calls, name+">")
+					res, err := next.RoundTrip(req)
+					calls = append(calls, "<"+name)
+					return res, err
+				})
+			}
+		}
+
+		u, _ := url.Parse("http://foo.bar")
+		tp, _ := New(Config{
+			URLs:              []*url.URL{u},
+			RequestMiddleware: []func(http.RoundTripper) http.RoundTripper{middleware("a"), middleware("b")},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					i++
+					calls = append(calls, "transport")
+					if req.URL.Host != "foo.bar" {
+						t.Errorf("Unexpected URL: %s", req.URL)
+					}
+					if i < 2 {
+						return &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		expected := []string{"a>", "b>", "transport", "<b", "<a", "a>", "b>", "transport", "<b", "<a"}
+		if !reflect.DeepEqual(calls, expected) {
+			t.Errorf("Unexpected calls:\nwant: %q\ngot:  %q", expected, calls)
+		}
+	})
+
+	t.Run("Short circuit", func(t *testing.T) {
+		tp, _ := New(Config{
+			URLs: []*url.URL{{}},
+			RequestMiddleware: []func(http.RoundTripper) http.RoundTripper{
+				func(next http.RoundTripper) http.RoundTripper {
+					return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+						return nil, errors.New("MOCK ERROR")
+					})
+				},
+			},
+			DisableRetry: true,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					t.Fatalf("Unexpected call to transport")
+					return nil, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		if _, err := tp.Perform(req); err == nil || err.Error() != "MOCK ERROR" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestResponseInterceptor(t *testing.T) {
+	var (
+		i        int
+		warnings []string
+	)
+
+	tp, _ := New(Config{
+		URLs: []*url.URL{{Scheme: "http", Host: "foo"}},
+		ResponseInterceptor: func(res *http.Response) {
+			warnings = append(warnings, res.Header.Get("Warning"))
+			res.Header.Set("X-Intercepted", "true")
+		},
+		Transport: &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				i++
+				switch i {
+				case 1:
+					return nil, &mockNetError{error: errors.New("MOCK ERROR")}
+				case 2:
+					return &http.Response{
+						StatusCode: http.StatusBadGateway,
+						Header:     http.Header{"Warning": []string{"299 - first"}},
+						Body:       ioutil.NopCloser(strings.NewReader("")),
+					}, nil
+				default:
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Warning": []string{"299 - second"}},
+						Body:       ioutil.NopCloser(strings.NewReader("{}")),
+					}, nil
+				}
+			},
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	res, err := tp.Perform(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := []string{"299 - first", "299 - second"}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("Unexpected warnings:\nwant: %q\ngot:  %q", expected, warnings)
+	}
+	if res.Header.Get("X-Intercepted") != "true" {
+		t.Errorf("Expected the response to be modified, got: %v", res.Header)
+	}
+	if body, _ := ioutil.ReadAll(res.Body); string(body) != "{}" {
+		t.Errorf("Unexpected body: %q", body)
+	}
+}
+
+func TestRequestContentLength(t *testing.T) {
+	tests := []struct {
+		name               string
+		disableRetry       bool
+		forceContentLength bool
+		wantLength         int64
+	}{
+		{"Buffered for retries", false, false, 13},
+		{"Unbuffered", true, false, 0},
+		{"Forced", true, true, 13},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				contentLength int64
+				body          string
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := ioutil.ReadAll(r.Body)
+				contentLength, body = r.ContentLength, string(b)
+			}))
+			defer server.Close()
+
+			u, _ := url.Parse(server.URL)
+			tp, _ := New(Config{
+				URLs:               []*url.URL{u},
+				DisableRetry:       tt.disableRetry,
+				ForceContentLength: tt.forceContentLength,
+				Transport:          &http.Transport{},
+			})
+
+			req, _ := http.NewRequest("POST", "/abc", ioutil.NopCloser(strings.NewReader("elasticsearch")))
+			res, err := tp.Perform(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			res.Body.Close()
+
+			if body != "elasticsearch" {
+				t.Errorf("Unexpected body: %q", body)
+			}
+			if tt.wantLength > 0 && contentLength != tt.wantLength {
+				t.Errorf("Unexpected Content-Length: want=%d, got=%d", tt.wantLength, contentLength)
+			}
+			if tt.wantLength == 0 && contentLength != -1 {
+				t.Errorf("Expected chunked transfer encoding, got Content-Length: %d", contentLength)
+			}
+		})
+	}
+}
+
+type mockRateLimiter struct {
+	labels []map[string]string
+	err    error
+}
+
+func (l *mockRateLimiter) Wait(ctx context.Context, labels map[string]string) error {
+	l.labels = append(l.labels, labels)
+	return l.err
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("Wait on every attempt", func(t *testing.T) {
+		var i int
+
+		limiter := &mockRateLimiter{}
+		tp, _ := New(Config{
+			URLs:        []*url.URL{{}},
+			RateLimiter: limiter,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					i++
+					if i < 2 {
+						return &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		req = req.WithContext(WithRequestLabels(req.Context(), map[string]string{"tenant": "foo"}))
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(limiter.labels) != 2 {
+			t.Fatalf("Unexpected number of calls to Wait, want=2, got=%d", len(limiter.labels))
+		}
+		for _, labels := range limiter.labels {
+			if labels["tenant"] != "foo" {
+				t.Errorf("Unexpected labels: %v", labels)
+			}
+		}
+	})
+
+	t.Run("Rejected request", func(t *testing.T) {
+		tp, _ := New(Config{
+			URLs:        []*url.URL{{}},
+			RateLimiter: &mockRateLimiter{err: errors.New("MOCK ERROR")},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					t.Fatalf("Unexpected request")
+					return nil, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		_, err := tp.Perform(req)
+		if err == nil || !strings.Contains(err.Error(), "rate limiter: MOCK ERROR") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestForceURL(t *testing.T) {
+	t.Run("Bypass the pool and retry on the same node", func(t *testing.T) {
+		var hosts []string
+
+		u1, _ := url.Parse("http://foo1:9200")
+		u2, _ := url.Parse("http://foo2:9200")
+		forced, _ := url.Parse("http://bar:9200")
+
+		tp, _ := New(Config{
+			URLs:     []*url.URL{u1, u2},
+			Username: "foo",
+			Password: "bar",
+			Header:   http.Header{"X-Foo": []string{"bar"}},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					hosts = append(hosts, req.URL.Host)
+					if _, _, ok := req.BasicAuth(); !ok {
+						t.Errorf("Expected the request to be authenticated")
+					}
+					if req.Header.Get("X-Foo") != "bar" {
+						t.Errorf("Unexpected header: %v", req.Header)
+					}
+					if len(hosts) < 2 {
+						return &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		req = req.WithContext(WithForceURL(req.Context(), forced))
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(hosts) != 2 || hosts[0] != "bar:9200" || hosts[1] != "bar:9200" {
+			t.Errorf("Unexpected hosts: %v", hosts)
+		}
+	})
+
+	t.Run("Failures not reported to the pool", func(t *testing.T) {
+		u1, _ := url.Parse("http://foo1:9200")
+		u2, _ := url.Parse("http://foo2:9200")
+		forced, _ := url.Parse("http://bar:9200")
+
+		tp, _ := New(Config{
+			URLs:         []*url.URL{u1, u2},
+			DisableRetry: true,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					return nil, &mockNetError{error: fmt.Errorf("MOCK ERROR")}
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		req = req.WithContext(WithForceURL(req.Context(), forced))
+		if _, err := tp.Perform(req); err == nil {
+			t.Fatalf("Expected error")
+		}
+
+		pool := tp.pool.(*statusConnectionPool)
+		if len(pool.live) != 2 || len(pool.dead) != 0 {
+			t.Errorf("Unexpected pool: live=%d, dead=%d", len(pool.live), len(pool.dead))
+		}
+	})
+}
+
+func TestMaxRequestsPerConnection(t *testing.T) {
+	var newConns int32
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	tp, _ := New(Config{
+		URLs:                     []*url.URL{u},
+		MaxRequestsPerConnection: 2,
+		EnableMetrics:            true,
+		Transport:                &http.Transport{},
+	})
+
+	for i := 0; i < 6; i++ {
+		req, _ := http.NewRequest("GET", "/", nil)
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+	}
+
+	if n := atomic.LoadInt32(&newConns); n != 3 {
+		t.Errorf("Unexpected number of connections: %d", n)
+	}
+
+	m, _ := tp.Metrics()
+	if m.ConnectionsRecycled != 3 {
+		t.Errorf("Unexpected ConnectionsRecycled: %d", m.ConnectionsRecycled)
+	}
 }
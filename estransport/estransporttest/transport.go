@@ -0,0 +1,293 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+/*
+Package estransporttest provides a fake transport for testing code using the Elasticsearch client.
+
+The Transport type implements the estransport.Interface, estransport.Measurable and estransport.Discoverable
+interfaces. It records the requests, and returns the scripted responses in order:
+
+	tp := &estransporttest.Transport{}
+	tp.AddResponse(200, `{"acknowledged":true}`)
+
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: tp})
+	es.Indices.Create("test")
+
+	log.Println(tp.Requests()[0].URL.Path) // => /test
+
+The transport also implements http.RoundTripper, so it can be passed in the Transport field
+of elasticsearch.Config, as above; in that case, the requests are processed by the estransport.Client,
+eg. retried, before reaching the fake. To test the full transport contract, assign it to the Transport
+field of elasticsearch.Client directly, which makes the Perform method receive the requests unchanged.
+*/
+package estransporttest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Tritura/go-elasticsearch/v8/estransport"
+)
+
+var (
+	_ estransport.Interface    = (*Transport)(nil)
+	_ estransport.Measurable   = (*Transport)(nil)
+	_ estransport.Discoverable = (*Transport)(nil)
+	_ http.RoundTripper        = (*Transport)(nil)
+
+	defaultURL = &url.URL{Scheme: "http", Host: "localhost:9200"}
+)
+
+// Transport is a fake transport, which records the requests and returns the scripted responses.
+//
+// The zero value is ready to use. It is safe for concurrent use.
+//
+type Transport struct {
+	// Addresses returned by URLs. Default: http://localhost:9200.
+	Addresses []*url.URL
+
+	// Optional function handling the requests when there are no scripted responses left.
+	// Default: nil, such requests fail with an error.
+	Handler func(*http.Request) (*http.Response, error)
+
+	// Optional function called by DiscoverNodes. Default: nil, the discovery succeeds.
+	DiscoverNodesFunc func() error
+
+	mu                 sync.Mutex
+	responses          []scriptedResponse
+	requests           []Request
+	discoverNodesCalls int
+	metrics            estransport.Metrics
+}
+
+// Request represents a request recorded by the Transport.
+//
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+type scriptedResponse struct {
+	res *http.Response
+	err error
+}
+
+// AddResponse appends a response with the status code and body to the scripted responses.
+//
+// The response includes the "Content-Type: application/json" header, and the product header
+// required by the client.
+//
+func (t *Transport) AddResponse(statusCode int, body string) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Elastic-Product", "Elasticsearch")
+
+	t.AddHTTPResponse(&http.Response{
+		StatusCode:    statusCode,
+		Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		Header:        header,
+		ContentLength: int64(len(body)),
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+	})
+}
+
+// AddHTTPResponse appends the response to the scripted responses, unchanged.
+//
+// A response can be returned only once, since its body is consumed by the caller.
+//
+func (t *Transport) AddHTTPResponse(res *http.Response) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.responses = append(t.responses, scriptedResponse{res: res})
+}
+
+// AddError appends the error to the scripted responses, eg. to simulate a network failure.
+//
+func (t *Transport) AddError(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.responses = append(t.responses, scriptedResponse{err: err})
+}
+
+// Perform records the request and returns the next scripted response.
+//
+func (t *Transport) Perform(req *http.Request) (*http.Response, error) {
+	r := Request{Method: req.Method, URL: req.URL, Header: req.Header.Clone()}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("estransporttest: cannot read request body: %s", err)
+		}
+		r.Body = body
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	t.mu.Lock()
+	t.requests = append(t.requests, r)
+	t.metrics.Requests++
+
+	var next *scriptedResponse
+	if len(t.responses) > 0 {
+		next = &t.responses[0]
+		t.responses = t.responses[1:]
+	}
+	handler := t.Handler
+	t.mu.Unlock()
+
+	var (
+		res *http.Response
+		err error
+	)
+
+	switch {
+	case next != nil:
+		res, err = next.res, next.err
+	case handler != nil:
+		res, err = handler(req)
+	default:
+		err = fmt.Errorf("estransporttest: no response for request %s %s", req.Method, req.URL)
+	}
+
+	t.mu.Lock()
+	if err != nil {
+		t.metrics.Failures++
+	}
+	if res != nil {
+		if res.Request == nil {
+			res.Request = req
+		}
+		if t.metrics.Responses == nil {
+			t.metrics.Responses = make(map[int]int)
+		}
+		t.metrics.Responses[res.StatusCode]++
+	}
+	t.mu.Unlock()
+
+	return res, err
+}
+
+// RoundTrip implements the http.RoundTripper interface; see Perform.
+//
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.Perform(req)
+}
+
+// URLs returns the configured addresses.
+//
+func (t *Transport) URLs() []*url.URL {
+	if len(t.Addresses) == 0 {
+		return []*url.URL{defaultURL}
+	}
+	return t.Addresses
+}
+
+// Metrics returns the number of requests, failures and responses by status code.
+//
+func (t *Transport) Metrics() (estransport.Metrics, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m := estransport.Metrics{
+		Requests:  t.metrics.Requests,
+		Failures:  t.metrics.Failures,
+		Responses: make(map[int]int, len(t.metrics.Responses)),
+	}
+	for code, n := range t.metrics.Responses {
+		m.Responses[code] = n
+	}
+	return m, nil
+}
+
+// DiscoverNodes records the call, and returns the result of DiscoverNodesFunc, when set.
+//
+func (t *Transport) DiscoverNodes() error {
+	t.mu.Lock()
+	t.discoverNodesCalls++
+	fn := t.DiscoverNodesFunc
+	t.mu.Unlock()
+
+	if fn != nil {
+		return fn()
+	}
+	return nil
+}
+
+// DiscoverNodesCalls returns the number of calls to DiscoverNodes.
+//
+func (t *Transport) DiscoverNodesCalls() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.discoverNodesCalls
+}
+
+// Requests returns the recorded requests, in order.
+//
+func (t *Transport) Requests() []Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]Request, len(t.requests))
+	copy(out, t.requests)
+	return out
+}
+
+// LastRequest returns the most recent request, or an error when no request has been recorded.
+//
+func (t *Transport) LastRequest() (Request, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.requests) == 0 {
+		return Request{}, errors.New("estransporttest: no requests recorded")
+	}
+	return t.requests[len(t.requests)-1], nil
+}
+
+// Pending returns the number of scripted responses which haven't been returned yet.
+//
+func (t *Transport) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.responses)
+}
+
+// Reset clears the recorded requests, the scripted responses, and the metrics.
+//
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.responses = nil
+	t.requests = nil
+	t.discoverNodesCalls = 0
+	t.metrics = estransport.Metrics{}
+}
@@ -0,0 +1,170 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package estransporttest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+func TestTransport(t *testing.T) {
+	t.Run("Scripted responses", func(t *testing.T) {
+		tp := &Transport{}
+		tp.AddResponse(200, `{"acknowledged":true}`)
+		tp.AddError(errors.New("MOCK ERROR"))
+
+		req, _ := http.NewRequest("PUT", "/test", strings.NewReader(`{"settings":{}}`))
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != 200 || string(body) != `{"acknowledged":true}` {
+			t.Errorf("Unexpected response: %d %s", res.StatusCode, body)
+		}
+		if res.Header.Get("X-Elastic-Product") != "Elasticsearch" {
+			t.Errorf("Unexpected headers: %v", res.Header)
+		}
+
+		// The request body remains readable
+		reqBody, _ := ioutil.ReadAll(req.Body)
+		if string(reqBody) != `{"settings":{}}` {
+			t.Errorf("Unexpected request body: %s", reqBody)
+		}
+
+		req, _ = http.NewRequest("GET", "/", nil)
+		if _, err := tp.Perform(req); err == nil || err.Error() != "MOCK ERROR" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if _, err := tp.Perform(req); err == nil {
+			t.Errorf("Expected error when no responses are scripted")
+		}
+
+		requests := tp.Requests()
+		if len(requests) != 3 {
+			t.Fatalf("Unexpected number of requests: %d", len(requests))
+		}
+		if requests[0].Method != "PUT" || requests[0].URL.Path != "/test" || string(requests[0].Body) != `{"settings":{}}` {
+			t.Errorf("Unexpected request: %+v", requests[0])
+		}
+
+		m, _ := tp.Metrics()
+		if m.Requests != 3 || m.Failures != 2 || m.Responses[200] != 1 {
+			t.Errorf("Unexpected metrics: %+v", m)
+		}
+
+		tp.Reset()
+		if len(tp.Requests()) != 0 || tp.Pending() != 0 {
+			t.Errorf("Expected the transport to be reset")
+		}
+		if _, err := tp.LastRequest(); err == nil {
+			t.Errorf("Expected error for empty recording")
+		}
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		tp := &Transport{
+			Handler: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: 404, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			},
+		}
+
+		req, _ := http.NewRequest("HEAD", "/test", nil)
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.StatusCode != 404 || res.Request != req {
+			t.Errorf("Unexpected response: %+v", res)
+		}
+	})
+
+	t.Run("URLs and DiscoverNodes", func(t *testing.T) {
+		tp := &Transport{DiscoverNodesFunc: func() error { return errors.New("MOCK ERROR") }}
+
+		if u := tp.URLs(); len(u) != 1 || u[0].String() != "http://localhost:9200" {
+			t.Errorf("Unexpected URLs: %v", u)
+		}
+
+		if err := tp.DiscoverNodes(); err == nil {
+			t.Errorf("Expected error")
+		}
+		if tp.DiscoverNodesCalls() != 1 {
+			t.Errorf("Unexpected number of calls: %d", tp.DiscoverNodesCalls())
+		}
+	})
+
+	t.Run("With elasticsearch.Config", func(t *testing.T) {
+		tp := &Transport{}
+		tp.AddResponse(200, `{"acknowledged":true}`)
+
+		es, err := elasticsearch.NewClient(elasticsearch.Config{Transport: tp})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		res, err := es.Indices.Create("test")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		res.Body.Close()
+
+		r, err := tp.LastRequest()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if r.Method != "PUT" || r.URL.Path != "/test" || r.URL.Host != "localhost:9200" {
+			t.Errorf("Unexpected request: %+v", r)
+		}
+	})
+
+	t.Run("With elasticsearch.Client", func(t *testing.T) {
+		tp := &Transport{}
+		tp.AddResponse(200, `{}`)
+
+		es := &elasticsearch.Client{Transport: tp}
+		es.API = esapi.New(es)
+
+		res, err := es.Indices.Refresh()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		res.Body.Close()
+
+		r, _ := tp.LastRequest()
+		if r.URL.Path != "/_refresh" || r.URL.Host != "" {
+			t.Errorf("Unexpected request: %+v", r)
+		}
+
+		if err := es.DiscoverNodes(); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+		if _, err := es.Metrics(); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package estransport
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is the error returned by the FaultInjector for the injected connection errors.
+//
+var ErrInjectedFault = &injectedFaultError{errors.New("injected connection error")}
+
+// injectedFaultError implements the net.Error interface, so the transport retries the request
+// like after a real connection error.
+//
+type injectedFaultError struct{ error }
+
+func (e *injectedFaultError) Timeout() bool   { return false }
+func (e *injectedFaultError) Temporary() bool { return true }
+
+// FaultInjector is a transport wrapper injecting failures into the requests, for resilience testing.
+//
+// For every request, it decides whether to inject a delay, and then whether to fail the request
+// with a connection error or a response with one of the status codes, according to the configured
+// probabilities; otherwise, the request is passed to the wrapped transport. The decisions are drawn
+// from a random source with the given seed, so a sequence of requests produces the same faults
+// for the same seed, provided the requests are sent sequentially.
+//
+// Use it as the Transport option of the client:
+//
+//	cfg := elasticsearch.Config{
+//		Transport: &estransport.FaultInjector{
+//			Transport:         http.DefaultTransport,
+//			Seed:              42,
+//			ErrorProbability:  0.1,
+//			StatusProbability: 0.1,
+//			StatusCodes:       []int{502, 503},
+//		},
+//	}
+//
+//
+type FaultInjector struct {
+	Transport http.RoundTripper // The wrapped transport. Default: http.DefaultTransport.
+	Seed      int64             // The seed of the random source.
+
+	DelayProbability float64       // Probability of delaying the request, between 0 and 1.
+	Delay            time.Duration // The maximum delay; the actual delay is random, up to the value.
+
+	ErrorProbability float64 // Probability of failing the request with ErrInjectedFault, between 0 and 1.
+
+	StatusProbability float64 // Probability of returning a response with one of StatusCodes, between 0 and 1.
+	StatusCodes       []int   // The status codes of the injected responses. Default: 503.
+
+	once sync.Once
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// RoundTrip executes the request, or injects a fault.
+//
+func (f *FaultInjector) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.once.Do(func() { f.rand = rand.New(rand.NewSource(f.Seed)) })
+
+	f.mu.Lock()
+	var delay time.Duration
+	if f.Delay > 0 && f.rand.Float64() < f.DelayProbability {
+		delay = time.Duration(f.rand.Int63n(int64(f.Delay)) + 1)
+	}
+	injectError := f.rand.Float64() < f.ErrorProbability
+	injectStatus := f.rand.Float64() < f.StatusProbability
+	statusCode := http.StatusServiceUnavailable
+	if len(f.StatusCodes) > 0 {
+		statusCode = f.StatusCodes[f.rand.Intn(len(f.StatusCodes))]
+	}
+	f.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	if injectError {
+		return nil, ErrInjectedFault
+	}
+
+	if injectStatus {
+		body := `{"error":{"type":"injected_fault","reason":"injected status ` + strconv.Itoa(statusCode) + `"},"status":` + strconv.Itoa(statusCode) + `}`
+		return &http.Response{
+			Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+			StatusCode:    statusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{"application/json"}, "X-Elastic-Product": []string{"Elasticsearch"}},
+			Body:          ioutil.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	tp := f.Transport
+	if tp == nil {
+		tp = http.DefaultTransport
+	}
+	return tp.RoundTrip(req)
+}
@@ -0,0 +1,130 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package estransport
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaultInjector(t *testing.T) {
+	okTransport := &mockTransp{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+		},
+	}
+
+	run := func(f *FaultInjector, n int) []string {
+		var out []string
+		for i := 0; i < n; i++ {
+			req, _ := http.NewRequest("GET", "/", nil)
+			res, err := f.RoundTrip(req)
+			if err != nil {
+				out = append(out, "error")
+				continue
+			}
+			out = append(out, res.Status)
+			res.Body.Close()
+		}
+		return out
+	}
+
+	t.Run("Deterministic faults", func(t *testing.T) {
+		newInjector := func() *FaultInjector {
+			return &FaultInjector{
+				Transport:         okTransport,
+				Seed:              42,
+				ErrorProbability:  0.3,
+				StatusProbability: 0.3,
+				StatusCodes:       []int{502, 503},
+			}
+		}
+
+		a := run(newInjector(), 50)
+		b := run(newInjector(), 50)
+
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("Expected the same faults for the same seed:\n%q\n%q", a, b)
+		}
+
+		counts := make(map[string]int)
+		for _, s := range a {
+			counts[s]++
+		}
+		if counts["error"] == 0 || counts["502 Bad Gateway"] == 0 || counts["503 Service Unavailable"] == 0 || counts[""] == 0 {
+			t.Errorf("Unexpected distribution of faults: %v", counts)
+		}
+	})
+
+	t.Run("No faults", func(t *testing.T) {
+		for _, s := range run(&FaultInjector{Transport: okTransport}, 10) {
+			if s != "" {
+				t.Errorf("Unexpected fault: %s", s)
+			}
+		}
+	})
+
+	t.Run("Injected connection error", func(t *testing.T) {
+		if _, ok := interface{}(ErrInjectedFault).(net.Error); !ok {
+			t.Errorf("Expected ErrInjectedFault to implement net.Error")
+		}
+
+		var attempts int
+		tp, _ := New(Config{
+			URLs: []*url.URL{{Scheme: "http", Host: "foo"}},
+			Transport: &FaultInjector{
+				Transport: &mockTransp{
+					RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+						attempts++
+						return okTransport.RoundTrip(req)
+					},
+				},
+				ErrorProbability: 1,
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		if _, err := tp.Perform(req); err != ErrInjectedFault {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if attempts != 0 {
+			t.Errorf("Unexpected calls to the wrapped transport: %d", attempts)
+		}
+	})
+
+	t.Run("Delay", func(t *testing.T) {
+		f := &FaultInjector{Transport: okTransport, DelayProbability: 1, Delay: time.Hour}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		if _, err := f.RoundTrip(req.WithContext(ctx)); err != context.DeadlineExceeded {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
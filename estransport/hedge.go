@@ -0,0 +1,204 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package estransport
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// roundTripResult represents the result of a single round-trip of a hedged request.
+//
+type roundTripResult struct {
+	idx  int
+	conn *Connection
+	res  *http.Response
+	err  error
+}
+
+// cancelOnCloseBody cancels the request context when the response body is closed.
+//
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// isHedgeable returns true when the request can be sent more than once, concurrently:
+// the method is idempotent, and the body, when present, can be re-read.
+//
+func isHedgeable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// roundTrip executes the request on the connection.
+//
+func (c *Client) roundTrip(req *http.Request, conn *Connection) (*http.Response, error) {
+	atomic.AddInt32(&conn.inFlight, 1)
+	defer atomic.AddInt32(&conn.inFlight, -1)
+
+	res, err := c.transport.RoundTrip(req)
+	if err != nil || !c.followRedirects {
+		return res, err
+	}
+	return c.handleRedirect(req, res)
+}
+
+// hedgedRoundTrip executes the request on the connection, and when it doesn't complete
+// within the hedgeAfter duration, sends a copy of the request to another connection.
+//
+// It returns the first successful response and its connection, or the last error when
+// all the requests fail. The other request is cancelled, and its response is discarded.
+//
+func (c *Client) hedgedRoundTrip(req *http.Request, conn *Connection, pool ConnectionPool) (*http.Response, *Connection, error) {
+	var (
+		results = make(chan roundTripResult, 2)
+		cancels []context.CancelFunc
+		pending int
+	)
+
+	send := func(r *http.Request, conn *Connection) {
+		// Send a deep copy, since the retries modify the URL and headers of the original request,
+		// while the cancelled attempt might still be in flight
+		ctx, cancel := context.WithCancel(r.Context())
+		r = r.Clone(ctx)
+		idx := len(cancels)
+		cancels = append(cancels, cancel)
+		pending++
+
+		go func() {
+			res, err := c.roundTrip(r, conn)
+			results <- roundTripResult{idx: idx, conn: conn, res: res, err: err}
+		}()
+	}
+
+	send(req, conn)
+
+	timer := c.clock.NewTimer(c.hedgeAfter)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+			if hedgeReq, hedgeConn := c.newHedgeRequest(req, conn, pool); hedgeReq != nil {
+				if c.metrics != nil {
+					atomic.AddInt64(&c.metrics.hedges, 1)
+				}
+				send(hedgeReq, hedgeConn)
+			}
+
+		case r := <-results:
+			pending--
+			if r.err != nil && pending > 0 {
+				cancels[r.idx]()
+				continue
+			}
+
+			for i, cancel := range cancels {
+				if i != r.idx {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go discardResults(results, pending)
+			}
+
+			if r.err != nil {
+				cancels[r.idx]()
+				return r.res, r.conn, r.err
+			}
+
+			if r.idx > 0 && c.metrics != nil {
+				atomic.AddInt64(&c.metrics.hedgeWins, 1)
+			}
+
+			if r.res.Body != nil {
+				r.res.Body = &cancelOnCloseBody{ReadCloser: r.res.Body, cancel: cancels[r.idx]}
+			} else {
+				cancels[r.idx]()
+			}
+			return r.res, r.conn, nil
+		}
+	}
+}
+
+// newHedgeRequest returns a copy of the request for another connection from the pool,
+// or nil when no other connection is available, or when the copy cannot be created.
+//
+func (c *Client) newHedgeRequest(req *http.Request, conn *Connection, pool ConnectionPool) (*http.Request, *Connection) {
+	c.Lock()
+	hedgeConn, err := pool.Next()
+	c.Unlock()
+	if err != nil || hedgeConn == conn {
+		return nil, nil
+	}
+
+	r := req.Clone(req.Context())
+
+	// Remove the path prefix of the original connection, before the URL is set for the other one
+	if conn.URL.Path != "" {
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, conn.URL.Path)
+		if r.URL.RawPath != "" {
+			r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, conn.URL.EscapedPath())
+		}
+	}
+	c.setReqURL(hedgeConn.URL, r)
+	c.setReqAuth(hedgeConn.URL, r)
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil
+		}
+		r.Body = body
+	}
+
+	if c.requestSigner != nil {
+		if err := c.requestSigner(r); err != nil {
+			return nil, nil
+		}
+	}
+
+	return r, hedgeConn
+}
+
+// discardResults drains and closes the response bodies of the remaining, cancelled requests.
+//
+func discardResults(results <-chan roundTripResult, n int) {
+	for i := 0; i < n; i++ {
+		r := <-results
+		if r.res != nil && r.res.Body != nil {
+			io.Copy(ioutil.Discard, r.res.Body)
+			r.res.Body.Close()
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package estransport
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgeAfter(t *testing.T) {
+	u1, _ := url.Parse("http://foo1:9200")
+	u2, _ := url.Parse("http://foo2:9200")
+
+	newResponse := func(body string) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}
+	}
+
+	t.Run("Hedged request wins", func(t *testing.T) {
+		var (
+			mu        sync.Mutex
+			hosts     []string
+			cancelled = make(chan struct{})
+		)
+
+		tp, _ := New(Config{
+			URLs:          []*url.URL{u1, u2},
+			HedgeAfter:    10 * time.Millisecond,
+			EnableMetrics: true,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					hosts = append(hosts, req.URL.Host)
+					first := len(hosts) == 1
+					mu.Unlock()
+
+					if first {
+						<-req.Context().Done()
+						close(cancelled)
+						return nil, req.Context().Err()
+					}
+					return newResponse(req.URL.Host), nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the slow request to be cancelled")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(hosts) != 2 || hosts[0] == hosts[1] || string(body) != hosts[1] {
+			t.Errorf("Unexpected hosts: %v, body: %s", hosts, body)
+		}
+
+		m, _ := tp.Metrics()
+		if m.Hedges != 1 || m.HedgeWins != 1 {
+			t.Errorf("Unexpected metrics: hedges=%d, wins=%d", m.Hedges, m.HedgeWins)
+		}
+		if !strings.Contains(m.String(), "Hedges:1 HedgeWins:1") {
+			t.Errorf("Unexpected output: %s", m)
+		}
+	})
+
+	t.Run("Retry while the original request is in flight", func(t *testing.T) {
+		var (
+			numRequests int32
+			inFlight    sync.WaitGroup
+		)
+		inFlight.Add(1)
+
+		tp, _ := New(Config{
+			URLs:         []*url.URL{u1, u2},
+			Username:     "foo",
+			Password:     "bar",
+			HedgeAfter:   10 * time.Millisecond,
+			MaxRetries:   1,
+			RetryBackoff: func(attempt int) time.Duration { return 0 },
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					switch atomic.AddInt32(&numRequests, 1) {
+					case 1:
+						// Keep reading the request after cancellation, concurrently with the retry
+						defer inFlight.Done()
+						<-req.Context().Done()
+						for i := 0; i < 100; i++ {
+							_ = req.URL.String()
+							_ = req.Header.Get("Authorization")
+						}
+						return nil, req.Context().Err()
+					case 2:
+						return &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+					default:
+						return newResponse("ok"), nil
+					}
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		res.Body.Close()
+		inFlight.Wait()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Unexpected response: %+v", res)
+		}
+	})
+
+	t.Run("Original request wins", func(t *testing.T) {
+		var (
+			numRequests int32
+			release     = make(chan struct{})
+		)
+
+		tp, _ := New(Config{
+			URLs:          []*url.URL{u1, u2},
+			HedgeAfter:    10 * time.Millisecond,
+			EnableMetrics: true,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					if atomic.AddInt32(&numRequests, 1) == 1 {
+						<-release
+						return newResponse("original"), nil
+					}
+					close(release)
+					<-req.Context().Done()
+					return nil, req.Context().Err()
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		if string(body) != "original" {
+			t.Errorf("Unexpected body: %s", body)
+		}
+
+		m, _ := tp.Metrics()
+		if m.Hedges != 1 || m.HedgeWins != 0 {
+			t.Errorf("Unexpected metrics: hedges=%d, wins=%d", m.Hedges, m.HedgeWins)
+		}
+	})
+
+	t.Run("Fast response", func(t *testing.T) {
+		var numRequests int32
+
+		tp, _ := New(Config{
+			URLs:       []*url.URL{u1, u2},
+			HedgeAfter: time.Hour,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					atomic.AddInt32(&numRequests, 1)
+					return newResponse("{}"), nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if n := atomic.LoadInt32(&numRequests); n != 1 {
+			t.Errorf("Unexpected number of requests: %d", n)
+		}
+	})
+
+	t.Run("Non-idempotent method", func(t *testing.T) {
+		var numRequests int32
+
+		tp, _ := New(Config{
+			URLs:       []*url.URL{u1, u2},
+			HedgeAfter: time.Millisecond,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					atomic.AddInt32(&numRequests, 1)
+					time.Sleep(10 * time.Millisecond)
+					return newResponse("{}"), nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("POST", "/abc", strings.NewReader(`{}`))
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if n := atomic.LoadInt32(&numRequests); n != 1 {
+			t.Errorf("Unexpected number of requests: %d", n)
+		}
+	})
+
+	t.Run("Single connection", func(t *testing.T) {
+		var numRequests int32
+
+		tp, _ := New(Config{
+			URLs:       []*url.URL{u1},
+			HedgeAfter: time.Millisecond,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					atomic.AddInt32(&numRequests, 1)
+					time.Sleep(10 * time.Millisecond)
+					return newResponse("{}"), nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/abc", nil)
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if n := atomic.LoadInt32(&numRequests); n != 1 {
+			t.Errorf("Unexpected number of requests: %d", n)
+		}
+	})
+}
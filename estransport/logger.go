@@ -45,6 +45,26 @@ type Logger interface {
 	ResponseBodyEnabled() bool
 }
 
+// LifecycleLogger defines an optional interface for loggers, which are notified
+// about the changes of the connection pool, eg. to alert on the churn of the connections.
+//
+// When the logger passed to the client implements the interface, the methods are called
+// in addition to LogRoundTrip. They are called synchronously, while the connection pool
+// is locked, so they must return quickly, and must not use the client or lock the connection.
+//
+// The connection events are reported by the default connection pool only.
+//
+type LifecycleLogger interface {
+	Logger
+
+	// LogConnectionDead is called when the connection is marked as dead after a failure.
+	LogConnectionDead(*Connection)
+	// LogConnectionResurrected is called when the dead connection is put back to the pool.
+	LogConnectionResurrected(*Connection)
+	// LogNodesDiscovered is called after the node discovery with the added and the removed connections.
+	LogNodesDiscovered(added, removed []*Connection)
+}
+
 // DebuggingLogger defines the interface for a debugging logger.
 //
 type DebuggingLogger interface {
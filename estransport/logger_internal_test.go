@@ -500,3 +500,110 @@ func (r *ErrorReader) Read(p []byte) (int, error) {
 	c, _ := lr.Read(p)
 	return c, errors.New("MOCK ERROR")
 }
+
+type mockLifecycleLogger struct {
+	TextLogger
+
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *mockLifecycleLogger) LogConnectionDead(c *Connection) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, "dead "+c.URL.Host)
+}
+
+func (l *mockLifecycleLogger) LogConnectionResurrected(c *Connection) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, "resurrected "+c.URL.Host)
+}
+
+func (l *mockLifecycleLogger) LogNodesDiscovered(added, removed []*Connection) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, c := range added {
+		l.events = append(l.events, "added "+c.URL.Host)
+	}
+	for _, c := range removed {
+		l.events = append(l.events, "removed "+c.URL.Host)
+	}
+}
+
+func TestLifecycleLogger(t *testing.T) {
+	t.Run("Dead and resurrected connections", func(t *testing.T) {
+		logger := &mockLifecycleLogger{TextLogger: TextLogger{Output: ioutil.Discard}}
+
+		u1, _ := url.Parse("http://foo1:9200")
+		u2, _ := url.Parse("http://foo2:9200")
+		tp, _ := New(Config{
+			URLs:   []*url.URL{u1, u2},
+			Logger: logger,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					if req.URL.Host == "foo1:9200" {
+						return nil, &mockNetError{error: errors.New("MOCK ERROR")}
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		pool := tp.pool.(*statusConnectionPool)
+		if len(pool.dead) != 1 {
+			t.Fatalf("Expected a dead connection")
+		}
+		pool.OnSuccess(pool.dead[0])
+
+		logger.mu.Lock()
+		defer logger.mu.Unlock()
+		if strings.Join(logger.events, ",") != "dead foo1:9200,resurrected foo1:9200" {
+			t.Errorf("Unexpected events: %v", logger.events)
+		}
+	})
+
+	t.Run("Discovered nodes", func(t *testing.T) {
+		logger := &mockLifecycleLogger{TextLogger: TextLogger{Output: ioutil.Discard}}
+
+		u1, _ := url.Parse("http://127.0.0.1:10001")
+		u2, _ := url.Parse("http://127.0.0.1:9999")
+		tp, _ := New(Config{
+			URLs:   []*url.URL{u1, u2},
+			Logger: logger,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					f, _ := os.Open("testdata/nodes.info.json")
+					return &http.Response{StatusCode: http.StatusOK, Body: f}, nil
+				},
+			},
+		})
+
+		if err := tp.DiscoverNodes(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		logger.mu.Lock()
+		defer logger.mu.Unlock()
+		if strings.Join(logger.events, ",") != "added localhost:10002,removed 127.0.0.1:9999" {
+			t.Errorf("Unexpected events: %v", logger.events)
+		}
+	})
+
+	t.Run("Basic logger", func(t *testing.T) {
+		tp, _ := New(Config{
+			URLs:      []*url.URL{{Scheme: "http", Host: "foo1"}, {Scheme: "http", Host: "foo2"}},
+			Logger:    &TextLogger{Output: ioutil.Discard},
+			Transport: &mockTransp{RoundTripFunc: func(*http.Request) (*http.Response, error) { return nil, errors.New("MOCK ERROR") }},
+		})
+
+		if pool := tp.pool.(*statusConnectionPool); pool.logger != nil {
+			t.Errorf("Unexpected lifecycle logger: %T", pool.logger)
+		}
+	})
+}
@@ -18,11 +18,17 @@
 package estransport
 
 import (
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"net/http/httptrace"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -45,6 +51,22 @@ type Metrics struct {
 	Failures  int         `json:"failures"`
 	Responses map[int]int `json:"responses"`
 
+	BytesSent     int64 `json:"bytes_sent"`     // Size of the request bodies, after compression
+	BytesReceived int64 `json:"bytes_received"` // Size of the response bodies, before decompression
+
+	Labels map[string]int `json:"labels,omitempty"` // Number of requests by label, eg. "tenant=foo"
+
+	Hedges    int64 `json:"hedges,omitempty"`     // Number of hedged requests sent
+	HedgeWins int64 `json:"hedge_wins,omitempty"` // Number of hedged requests which completed first
+
+	// The fraction of requests recorded in Responses, Labels, ConnectionsReused and ConnectionsNew;
+	// the other counters record every request. The sampled counts are not scaled.
+	SampleRate float64 `json:"sample_rate"`
+
+	ConnectionsReused   int `json:"connections_reused"`
+	ConnectionsNew      int `json:"connections_new"`
+	ConnectionsRecycled int `json:"connections_recycled"` // Number of connections closed after MaxRequestsPerConnection
+
 	Connections []fmt.Stringer `json:"connections"`
 }
 
@@ -66,15 +88,34 @@ type ConnectionMetric struct {
 // metrics represents the inner state of metrics.
 //
 type metrics struct {
+	// Accessed atomically; kept at the top of the struct for 64-bit alignment
+	requests      int64
+	failures      int64
+	bytesSent     int64
+	bytesReceived int64
+	hedges        int64
+	hedgeWins     int64
+	recycled      int64
+
+	sampleRate float64 // The fraction of requests recorded in the sampled metrics
+
 	sync.RWMutex
 
-	requests  int
-	failures  int
 	responses map[int]int
+	labels    map[string]int
+
+	connectionsReused int
+	connectionsNew    int
 
 	connections []*Connection
 }
 
+// sample returns true when the request should be recorded in the sampled metrics.
+//
+func (m *metrics) sample() bool {
+	return m.sampleRate >= 1 || rand.Float64() < m.sampleRate
+}
+
 // Metrics returns the transport metrics.
 //
 func (c *Client) Metrics() (Metrics, error) {
@@ -90,9 +131,28 @@ func (c *Client) Metrics() (Metrics, error) {
 	}
 
 	m := Metrics{
-		Requests:  c.metrics.requests,
-		Failures:  c.metrics.failures,
+		Requests:  int(atomic.LoadInt64(&c.metrics.requests)),
+		Failures:  int(atomic.LoadInt64(&c.metrics.failures)),
 		Responses: c.metrics.responses,
+
+		SampleRate: c.metrics.sampleRate,
+
+		BytesSent:     atomic.LoadInt64(&c.metrics.bytesSent),
+		BytesReceived: atomic.LoadInt64(&c.metrics.bytesReceived),
+
+		Hedges:    atomic.LoadInt64(&c.metrics.hedges),
+		HedgeWins: atomic.LoadInt64(&c.metrics.hedgeWins),
+
+		ConnectionsReused:   c.metrics.connectionsReused,
+		ConnectionsNew:      c.metrics.connectionsNew,
+		ConnectionsRecycled: int(atomic.LoadInt64(&c.metrics.recycled)),
+	}
+
+	if len(c.metrics.labels) > 0 {
+		m.Labels = make(map[string]int, len(c.metrics.labels))
+		for k, v := range c.metrics.labels {
+			m.Labels[k] = v
+		}
 	}
 
 	if pool, ok := c.pool.(connectionable); ok {
@@ -129,6 +189,23 @@ func (c *Client) Metrics() (Metrics, error) {
 	return m, nil
 }
 
+// clientTrace returns an HTTP client trace recording whether the connections are reused.
+//
+func (m *metrics) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			m.Lock()
+			defer m.Unlock()
+
+			if info.Reused {
+				m.connectionsReused++
+			} else {
+				m.connectionsNew++
+			}
+		},
+	}
+}
+
 // String returns the metrics as a string.
 //
 func (m Metrics) String() string {
@@ -144,6 +221,30 @@ func (m Metrics) String() string {
 	b.WriteString(" Failures:")
 	b.WriteString(strconv.Itoa(m.Failures))
 
+	b.WriteString(" BytesSent:")
+	b.WriteString(strconv.FormatInt(m.BytesSent, 10))
+
+	b.WriteString(" BytesReceived:")
+	b.WriteString(strconv.FormatInt(m.BytesReceived, 10))
+
+	b.WriteString(" ConnectionsReused:")
+	b.WriteString(strconv.Itoa(m.ConnectionsReused))
+
+	b.WriteString(" ConnectionsNew:")
+	b.WriteString(strconv.Itoa(m.ConnectionsNew))
+
+	if m.ConnectionsRecycled > 0 {
+		b.WriteString(" ConnectionsRecycled:")
+		b.WriteString(strconv.Itoa(m.ConnectionsRecycled))
+	}
+
+	if m.Hedges > 0 {
+		b.WriteString(" Hedges:")
+		b.WriteString(strconv.FormatInt(m.Hedges, 10))
+		b.WriteString(" HedgeWins:")
+		b.WriteString(strconv.FormatInt(m.HedgeWins, 10))
+	}
+
 	if len(m.Responses) > 0 {
 		b.WriteString(" Responses: ")
 		b.WriteString("[")
@@ -160,6 +261,25 @@ func (m Metrics) String() string {
 		b.WriteString("]")
 	}
 
+	if len(m.Labels) > 0 {
+		labels := make([]string, 0, len(m.Labels))
+		for k := range m.Labels {
+			labels = append(labels, k)
+		}
+		sort.Strings(labels)
+
+		b.WriteString(" Labels: [")
+		for i, k := range labels {
+			b.WriteString(k)
+			b.WriteString(":")
+			b.WriteString(strconv.Itoa(m.Labels[k]))
+			if i+1 < len(labels) {
+				b.WriteString(", ")
+			}
+		}
+		b.WriteString("]")
+	}
+
 	b.WriteString(" Connections: [")
 	for i, c := range m.Connections {
 		b.WriteString(c.String())
@@ -192,3 +312,38 @@ func (cm ConnectionMetric) String() string {
 	b.WriteString("}")
 	return b.String()
 }
+
+// countingReadCloser counts the number of bytes read from the wrapped reader.
+//
+type countingReadCloser struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+	return n, err
+}
+
+// gzipReadCloser decompresses the wrapped body lazily, on the first read.
+//
+type gzipReadCloser struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (r *gzipReadCloser) Read(p []byte) (int, error) {
+	if r.zr == nil && r.err == nil {
+		r.zr, r.err = gzip.NewReader(r.body)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.zr.Read(p)
+}
+
+func (r *gzipReadCloser) Close() error {
+	return r.body.Close()
+}
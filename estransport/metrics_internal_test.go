@@ -20,10 +20,16 @@
 package estransport
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -71,6 +77,176 @@ func TestMetrics(t *testing.T) {
 		}
 	})
 
+	t.Run("Connection reuse", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		}))
+		defer server.Close()
+
+		u, _ := url.Parse(server.URL)
+		tp, _ := New(Config{
+			URLs:          []*url.URL{u},
+			Transport:     &http.Transport{},
+			EnableMetrics: true,
+		})
+
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest("GET", "/", nil)
+			res, err := tp.Perform(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		m, err := tp.Metrics()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if m.ConnectionsNew != 1 {
+			t.Errorf("Unexpected ConnectionsNew, want=1, got=%d", m.ConnectionsNew)
+		}
+		if m.ConnectionsReused != 2 {
+			t.Errorf("Unexpected ConnectionsReused, want=2, got=%d", m.ConnectionsReused)
+		}
+	})
+
+	t.Run("Request labels", func(t *testing.T) {
+		tp, _ := New(Config{
+			URLs: []*url.URL{{Scheme: "http", Host: "foo"}},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+				},
+			},
+			EnableMetrics: true,
+		})
+
+		for _, tenant := range []string{"a", "b", "a"} {
+			req, _ := http.NewRequest("GET", "/", nil)
+			req = req.WithContext(WithRequestLabels(req.Context(), map[string]string{"tenant": tenant}))
+			tp.Perform(req)
+		}
+
+		m, err := tp.Metrics()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if m.Labels["tenant=a"] != 2 || m.Labels["tenant=b"] != 1 {
+			t.Errorf("Unexpected labels: %v", m.Labels)
+		}
+		if !strings.Contains(m.String(), "Labels: [tenant=a:2, tenant=b:1]") {
+			t.Errorf("Unexpected output: %s", m)
+		}
+	})
+
+	t.Run("Bytes sent and received", func(t *testing.T) {
+		var (
+			sent     int64
+			received int64
+		)
+
+		body := strings.Repeat(`{"foo":"bar"}`, 100)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n, _ := io.Copy(ioutil.Discard, r.Body)
+			sent = n
+
+			if r.Header.Get("Accept-Encoding") != "gzip" {
+				t.Errorf("Unexpected Accept-Encoding: %q", r.Header.Get("Accept-Encoding"))
+			}
+
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write([]byte(body))
+			zw.Close()
+			received = int64(buf.Len())
+
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(buf.Bytes())
+		}))
+		defer server.Close()
+
+		u, _ := url.Parse(server.URL)
+		tp, _ := New(Config{
+			URLs:                []*url.URL{u},
+			Transport:           &http.Transport{},
+			CompressRequestBody: true,
+			EnableMetrics:       true,
+		})
+
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		if string(b) != body {
+			t.Errorf("Unexpected response body: %q", b)
+		}
+
+		m, err := tp.Metrics()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if sent == 0 || sent >= int64(len(body)) {
+			t.Errorf("Expected the request body to be compressed, got %d bytes", sent)
+		}
+		if m.BytesSent != sent {
+			t.Errorf("Unexpected BytesSent, want=%d, got=%d", sent, m.BytesSent)
+		}
+		if m.BytesReceived != received {
+			t.Errorf("Unexpected BytesReceived, want=%d, got=%d", received, m.BytesReceived)
+		}
+		if !strings.Contains(m.String(), fmt.Sprintf("BytesSent:%d BytesReceived:%d", sent, received)) {
+			t.Errorf("Unexpected output: %s", m)
+		}
+	})
+
+	t.Run("Sample rate", func(t *testing.T) {
+		tp, _ := New(Config{
+			URLs: []*url.URL{{Scheme: "http", Host: "foo"}},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+				},
+			},
+			EnableMetrics:     true,
+			MetricsSampleRate: 1e-9,
+		})
+
+		for i := 0; i < 100; i++ {
+			req, _ := http.NewRequest("GET", "/", nil)
+			req = req.WithContext(WithRequestLabels(req.Context(), map[string]string{"tenant": "a"}))
+			tp.Perform(req)
+		}
+
+		m, err := tp.Metrics()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if m.Requests != 100 {
+			t.Errorf("Unexpected requests, want=100, got=%d", m.Requests)
+		}
+		if m.Responses[200] > 1 || m.Labels["tenant=a"] > 1 {
+			t.Errorf("Expected the responses and labels to be sampled, got: %v, %v", m.Responses, m.Labels)
+		}
+		if m.SampleRate != 1e-9 {
+			t.Errorf("Unexpected sample rate: %v", m.SampleRate)
+		}
+
+		if _, err := New(Config{EnableMetrics: true, MetricsSampleRate: 1.5}); err == nil {
+			t.Errorf("Expected error for invalid sample rate")
+		}
+	})
+
 	t.Run("Metrics() when not enabled", func(t *testing.T) {
 		tp, _ := New(Config{})
 
@@ -0,0 +1,112 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package estransport
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// maxRedirects is the maximum number of redirects followed for a single request.
+//
+const maxRedirects = 10
+
+// handleRedirect follows the redirect responses to the request, when the FollowRedirects option is enabled,
+// and returns the final response.
+//
+// The request body is replayed only for the 307 and 308 responses, which preserve the method.
+// The 301, 302 and 303 responses are followed only for the GET and HEAD requests without a body,
+// since they change the method to GET. The "Authorization" header is removed
+// when the redirect points to another host.
+//
+// A redirect which cannot be followed, eg. with a body which cannot be replayed, or past
+// the maximum number of redirects, is returned as is, like when the option is disabled,
+// instead of sending a different request than the caller intended. It's not an error,
+// so the connection is not marked as dead.
+//
+func (c *Client) handleRedirect(req *http.Request, res *http.Response) (*http.Response, error) {
+	for i := 0; i < maxRedirects; i++ {
+		next := redirectRequest(req, res)
+		if next == nil {
+			return res, nil
+		}
+
+		// The response body is not needed anymore, since the redirect is followed
+		if res.Body != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		var err error
+		if res, err = c.transport.RoundTrip(next); err != nil {
+			return nil, err
+		}
+		req = next
+	}
+	return res, nil
+}
+
+// redirectRequest returns the request to send for the redirect response,
+// or nil when the response is not a redirect, or the redirect cannot be followed.
+//
+func redirectRequest(req *http.Request, res *http.Response) *http.Request {
+	switch res.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil
+	}
+
+	loc := res.Header.Get("Location")
+	if loc == "" {
+		return nil
+	}
+	u, err := req.URL.Parse(loc)
+	if err != nil {
+		return nil
+	}
+
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	next := req.Clone(req.Context())
+	next.URL = u
+	next.Host = ""
+
+	switch res.StatusCode {
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		if hasBody {
+			if req.GetBody == nil {
+				return nil
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil
+			}
+			next.Body = body
+		}
+	default:
+		if hasBody || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+			return nil
+		}
+	}
+
+	if u.Host != req.URL.Host {
+		next.Header.Del("Authorization")
+	}
+	return next
+}
@@ -0,0 +1,224 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package estransport
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFollowRedirects(t *testing.T) {
+	type request struct {
+		method string
+		url    string
+		body   string
+		auth   string
+	}
+
+	newClient := func(status int, location string, requests *[]request) *Client {
+		u, _ := url.Parse("http://foo:9200")
+		tp, _ := New(Config{
+			URLs:            []*url.URL{u},
+			Username:        "elastic",
+			Password:        "secret",
+			DisableRetry:    true,
+			FollowRedirects: true,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					var body []byte
+					if req.Body != nil {
+						body, _ = ioutil.ReadAll(req.Body)
+					}
+					*requests = append(*requests, request{req.Method, req.URL.String(), string(body), req.Header.Get("Authorization")})
+
+					if len(*requests) == 1 || status == http.StatusFound && strings.Contains(location, "loop") {
+						return &http.Response{
+							StatusCode: status,
+							Header:     http.Header{"Location": []string{location}},
+							Body:       ioutil.NopCloser(strings.NewReader("")),
+						}, nil
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+				},
+			},
+		})
+		return tp
+	}
+
+	for _, status := range []int{http.StatusTemporaryRedirect, http.StatusPermanentRedirect} {
+		t.Run(fmt.Sprintf("Replay body on %d", status), func(t *testing.T) {
+			var requests []request
+			tp := newClient(status, "/moved/_doc", &requests)
+
+			req, _ := http.NewRequest("POST", "/test/_doc", ioutil.NopCloser(strings.NewReader(`{"title":"foo"}`)))
+			res, err := tp.Perform(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("Unexpected response: %+v", res)
+			}
+
+			if len(requests) != 2 {
+				t.Fatalf("Unexpected number of requests: %d", len(requests))
+			}
+			r := requests[1]
+			if r.method != "POST" || r.url != "http://foo:9200/moved/_doc" || r.body != `{"title":"foo"}` || r.auth == "" {
+				t.Errorf("Unexpected redirected request: %+v", r)
+			}
+		})
+	}
+
+	t.Run("GET on 301", func(t *testing.T) {
+		var requests []request
+		tp := newClient(http.StatusMovedPermanently, "http://bar:9200/test/_doc/1", &requests)
+
+		req, _ := http.NewRequest("GET", "/test/_doc/1", nil)
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(requests) != 2 {
+			t.Fatalf("Unexpected number of requests: %d", len(requests))
+		}
+		if requests[0].auth == "" {
+			t.Errorf("Expected the authorization header for the original host")
+		}
+		if r := requests[1]; r.method != "GET" || r.url != "http://bar:9200/test/_doc/1" || r.auth != "" {
+			t.Errorf("Unexpected redirected request: %+v", r)
+		}
+	})
+
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther} {
+		t.Run(fmt.Sprintf("Body on %d", status), func(t *testing.T) {
+			var requests []request
+			tp := newClient(status, "/moved/_doc", &requests)
+
+			req, _ := http.NewRequest("POST", "/test/_doc", strings.NewReader(`{"title":"foo"}`))
+			res, err := tp.Perform(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if res.StatusCode != status || res.Header.Get("Location") != "/moved/_doc" {
+				t.Errorf("Expected the redirect response, got: %+v", res)
+			}
+			if len(requests) != 1 {
+				t.Errorf("Unexpected number of requests: %d", len(requests))
+			}
+		})
+	}
+
+	t.Run("Unbuffered body", func(t *testing.T) {
+		var requests []request
+		tp := newClient(http.StatusTemporaryRedirect, "/moved/_doc", &requests)
+
+		req, _ := http.NewRequest("POST", "/test/_doc", ioutil.NopCloser(strings.NewReader(`{"title":"foo"}`)))
+		req = req.WithContext(WithoutBuffering(req.Context()))
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.StatusCode != http.StatusTemporaryRedirect {
+			t.Errorf("Expected the redirect response, got: %+v", res)
+		}
+		if len(requests) != 1 {
+			t.Errorf("Unexpected number of requests: %d", len(requests))
+		}
+	})
+
+	t.Run("Too many redirects", func(t *testing.T) {
+		var requests []request
+		tp := newClient(http.StatusFound, "/loop", &requests)
+
+		req, _ := http.NewRequest("GET", "/loop", nil)
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.StatusCode != http.StatusFound {
+			t.Errorf("Expected the redirect response, got: %+v", res)
+		}
+		if len(requests) != 11 {
+			t.Errorf("Unexpected number of requests: %d", len(requests))
+		}
+	})
+
+	t.Run("Unfollowable redirect keeps the node live", func(t *testing.T) {
+		var requests int
+		u1, _ := url.Parse("http://foo1:9200")
+		u2, _ := url.Parse("http://foo2:9200")
+		tp, _ := New(Config{
+			URLs:                   []*url.URL{u1, u2},
+			FollowRedirects:        true,
+			DiscoverNodesOnFailure: true,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					requests++
+					return &http.Response{
+						StatusCode: http.StatusFound,
+						Header:     http.Header{"Location": []string{"/moved"}},
+						Body:       ioutil.NopCloser(strings.NewReader("")),
+					}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("POST", "/test/_doc", strings.NewReader(`{"title":"foo"}`))
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.StatusCode != http.StatusFound {
+			t.Errorf("Expected the redirect response, got: %+v", res)
+		}
+		if requests != 1 {
+			t.Errorf("Unexpected number of requests: %d", requests)
+		}
+
+		pool := tp.pool.(*statusConnectionPool)
+		if len(pool.live) != 2 || len(pool.dead) != 0 {
+			t.Errorf("Expected all nodes to be live, live=%s, dead=%s", pool.live, pool.dead)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		tp, _ := New(Config{
+			URLs: []*url.URL{{}},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusTemporaryRedirect, Header: http.Header{"Location": []string{"/moved"}}}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		res, err := tp.Perform(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.StatusCode != http.StatusTemporaryRedirect {
+			t.Errorf("Unexpected response: %+v", res)
+		}
+	})
+}
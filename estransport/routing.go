@@ -0,0 +1,91 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package estransport
+
+import (
+	"net/http"
+	"strings"
+)
+
+// readEndpoints lists the endpoints which only read data, though they can be called with the POST method.
+//
+var readEndpoints = map[string]bool{
+	"_search":          true,
+	"_msearch":         true,
+	"_search_template": true,
+	"_count":           true,
+	"_mget":            true,
+	"_field_caps":      true,
+	"_explain":         true,
+	"_termvectors":     true,
+	"_mtermvectors":    true,
+	"_async_search":    true,
+}
+
+// isWriteRequest returns true when the request should be routed to the write pool.
+//
+// The request type set with WithReadRequest or WithWriteRequest takes precedence;
+// otherwise, the request type is derived from the method and path, with isWriteOperation.
+//
+func isWriteRequest(req *http.Request) bool {
+	if v, ok := req.Context().Value(writeRequestKey{}).(bool); ok {
+		return v
+	}
+	return isWriteOperation(req)
+}
+
+// isWriteOperation returns true when the request writes data, according to its method and path only:
+// the GET, HEAD and OPTIONS requests, and the POST requests to the search and multi-get endpoints,
+// are read requests, and all other requests are write requests.
+//
+func isWriteOperation(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	case http.MethodPost:
+		for _, segment := range strings.Split(req.URL.Path, "/") {
+			if readEndpoints[segment] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nextConnection returns a connection for the request, and the pool it belongs to:
+// the read or write pool, according to the request type, when configured,
+// or the combined pool, when the role-specific pool is not configured or has no connection.
+//
+func (c *Client) nextConnection(req *http.Request) (*Connection, ConnectionPool, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	pool := c.readPool
+	if isWriteRequest(req) {
+		pool = c.writePool
+	}
+
+	if pool != nil {
+		if conn, err := pool.Next(); err == nil {
+			return conn, pool, nil
+		}
+	}
+
+	conn, err := c.pool.Next()
+	return conn, c.pool, err
+}
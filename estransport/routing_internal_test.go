@@ -0,0 +1,121 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package estransport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestReadWritePools(t *testing.T) {
+	var (
+		u, _      = url.Parse("http://combined:9200")
+		readU, _  = url.Parse("http://read:9200")
+		writeU, _ = url.Parse("http://write:9200")
+	)
+
+	newClient := func(cfg Config, host *string) *Client {
+		cfg.URLs = []*url.URL{u}
+		cfg.Transport = &mockTransp{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				*host = req.URL.Host
+				return &http.Response{Status: "MOCK", StatusCode: http.StatusOK}, nil
+			},
+		}
+		tp, _ := New(cfg)
+		return tp
+	}
+
+	t.Run("Routing", func(t *testing.T) {
+		var host string
+		tp := newClient(Config{ReadURLs: []*url.URL{readU}, WriteURLs: []*url.URL{writeU}}, &host)
+
+		for _, tt := range []struct {
+			method string
+			path   string
+			ctx    func(context.Context) context.Context
+			want   string
+		}{
+			{"GET", "/test/_doc/1", nil, "read:9200"},
+			{"HEAD", "/test", nil, "read:9200"},
+			{"POST", "/test/_search", nil, "read:9200"},
+			{"POST", "/_msearch", nil, "read:9200"},
+			{"POST", "/test/_mget", nil, "read:9200"},
+			{"POST", "/test/_doc", nil, "write:9200"},
+			{"PUT", "/test/_doc/1", nil, "write:9200"},
+			{"DELETE", "/test/_doc/1", nil, "write:9200"},
+			{"POST", "/_bulk", nil, "write:9200"},
+			{"POST", "/test/_doc", WithReadRequest, "read:9200"},
+			{"GET", "/test/_search", WithWriteRequest, "write:9200"},
+		} {
+			t.Run(fmt.Sprintf("%s %s", tt.method, tt.path), func(t *testing.T) {
+				req, _ := http.NewRequest(tt.method, tt.path, nil)
+				if tt.ctx != nil {
+					req = req.WithContext(tt.ctx(req.Context()))
+				}
+				if _, err := tp.Perform(req); err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				if host != tt.want {
+					t.Errorf("Unexpected host, want=%s, got=%s", tt.want, host)
+				}
+			})
+		}
+	})
+
+	t.Run("Fallback to the combined pool", func(t *testing.T) {
+		var host string
+		tp := newClient(Config{ReadURLs: []*url.URL{readU}}, &host)
+
+		req, _ := http.NewRequest("PUT", "/test/_doc/1", nil)
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if host != "combined:9200" {
+			t.Errorf("Unexpected host: %s", host)
+		}
+
+		req, _ = http.NewRequest("GET", "/test/_doc/1", nil)
+		if _, err := tp.Perform(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if host != "read:9200" {
+			t.Errorf("Unexpected host: %s", host)
+		}
+	})
+
+	t.Run("Without role-specific pools", func(t *testing.T) {
+		var host string
+		tp := newClient(Config{}, &host)
+
+		for _, method := range []string{"GET", "PUT"} {
+			req, _ := http.NewRequest(method, "/test/_doc/1", nil)
+			if _, err := tp.Perform(req); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if host != "combined:9200" {
+				t.Errorf("Unexpected host for %s: %s", method, host)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package estransport
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	headerTraceparent = "traceparent"
+	headerTracestate  = "tracestate"
+)
+
+// TraceContext represents the W3C trace context of a request.
+//
+// The client doesn't depend on any tracing library; use the WithTraceContext function
+// to store the identifiers of the active span in the request context,
+// and enable the PropagateTraceContext option to send them to Elasticsearch.
+//
+// See: https://www.w3.org/TR/trace-context/
+//
+type TraceContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string
+}
+
+type traceContextKey struct{}
+
+// WithTraceContext returns a copy of ctx carrying the trace context.
+//
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the trace context stored in ctx, if any.
+//
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// IsValid returns true when both the trace ID and the span ID are set.
+//
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceID != [16]byte{} && tc.SpanID != [8]byte{}
+}
+
+// Traceparent returns the value of the "traceparent" HTTP header.
+//
+func (tc TraceContext) Traceparent() string {
+	var b [55]byte
+
+	copy(b[0:], "00-")
+	hex.Encode(b[3:35], tc.TraceID[:])
+	b[35] = '-'
+	hex.Encode(b[36:52], tc.SpanID[:])
+	b[52] = '-'
+	if tc.Sampled {
+		copy(b[53:], "01")
+	} else {
+		copy(b[53:], "00")
+	}
+
+	return string(b[:])
+}
+
+// setReqTraceContext sets the W3C trace context headers from the request context,
+// unless the "traceparent" header is already present.
+//
+func (c *Client) setReqTraceContext(req *http.Request) *http.Request {
+	if !c.propagateTraceContext || req.Header.Get(headerTraceparent) != "" {
+		return req
+	}
+
+	tc, ok := TraceContextFromContext(req.Context())
+	if !ok || !tc.IsValid() {
+		return req
+	}
+
+	req.Header.Set(headerTraceparent, tc.Traceparent())
+	if tc.TraceState != "" {
+		req.Header.Set(headerTracestate, tc.TraceState)
+	}
+
+	return req
+}
@@ -0,0 +1,120 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package estransport
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestTraceContext(t *testing.T) {
+	tc := TraceContext{
+		TraceID:    [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		Sampled:    true,
+		TraceState: "congo=t61rcWkgMzE",
+	}
+
+	t.Run("Traceparent()", func(t *testing.T) {
+		if v := tc.Traceparent(); v != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+			t.Errorf("Unexpected traceparent: %s", v)
+		}
+
+		unsampled := tc
+		unsampled.Sampled = false
+		if v := unsampled.Traceparent(); v != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00" {
+			t.Errorf("Unexpected traceparent: %s", v)
+		}
+	})
+
+	t.Run("IsValid()", func(t *testing.T) {
+		if !tc.IsValid() {
+			t.Errorf("Expected trace context to be valid")
+		}
+		if (TraceContext{}).IsValid() {
+			t.Errorf("Expected empty trace context to be invalid")
+		}
+	})
+
+	t.Run("Propagates headers when enabled", func(t *testing.T) {
+		var hdr http.Header
+
+		u, _ := url.Parse("http://foo.bar")
+		tp, _ := New(Config{
+			URLs:                  []*url.URL{u},
+			PropagateTraceContext: true,
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					hdr = req.Header
+					return &http.Response{Status: "MOCK"}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req = req.WithContext(WithTraceContext(context.Background(), tc))
+		tp.Perform(req)
+
+		if v := hdr.Get("traceparent"); v != tc.Traceparent() {
+			t.Errorf("Unexpected traceparent header: %q", v)
+		}
+		if v := hdr.Get("tracestate"); v != "congo=t61rcWkgMzE" {
+			t.Errorf("Unexpected tracestate header: %q", v)
+		}
+	})
+
+	t.Run("Doesn't propagate headers when disabled", func(t *testing.T) {
+		var hdr http.Header
+
+		u, _ := url.Parse("http://foo.bar")
+		tp, _ := New(Config{
+			URLs: []*url.URL{u},
+			Transport: &mockTransp{
+				RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+					hdr = req.Header
+					return &http.Response{Status: "MOCK"}, nil
+				},
+			},
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req = req.WithContext(WithTraceContext(context.Background(), tc))
+		tp.Perform(req)
+
+		if v := hdr.Get("traceparent"); v != "" {
+			t.Errorf("Unexpected traceparent header: %q", v)
+		}
+	})
+
+	t.Run("Doesn't override existing header", func(t *testing.T) {
+		tp, _ := New(Config{PropagateTraceContext: true})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("traceparent", "00-custom")
+		req = req.WithContext(WithTraceContext(context.Background(), tc))
+		tp.setReqTraceContext(req)
+
+		if v := req.Header.Get("traceparent"); v != "00-custom" {
+			t.Errorf("Unexpected traceparent header: %q", v)
+		}
+	})
+}
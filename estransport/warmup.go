@@ -0,0 +1,94 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package estransport
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	defaultWarmupTimeout = time.Second
+	warmupRequestTimeout = 30 * time.Second
+)
+
+// warmupConnections opens n connections to every node by sending concurrent "HEAD /" requests.
+//
+// It blocks until all the requests complete, or until the timeout expires; the remaining
+// requests continue in the background. Errors are ignored, since the warm-up is only an optimization.
+//
+func (c *Client) warmupConnections(n int, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultWarmupTimeout
+	}
+
+	var wg sync.WaitGroup
+	for _, u := range c.urls {
+		for i := 0; i < n; i++ {
+			req, err := http.NewRequest("HEAD", "/", nil)
+			if err != nil {
+				continue
+			}
+
+			c.setReqURL(u, req)
+			c.setReqAuth(u, req)
+			c.setReqUserAgent(req)
+
+			if c.disableMetaHeader == false {
+				c.setMetaHeader(req)
+			}
+
+			wg.Add(1)
+			go func(req *http.Request) {
+				defer wg.Done()
+
+				ctx, cancel := context.WithTimeout(context.Background(), warmupRequestTimeout)
+				defer cancel()
+
+				res, err := c.transport.RoundTrip(req.WithContext(ctx))
+				if err != nil {
+					if debugLogger != nil {
+						debugLogger.Logf("Error warming up connection to %s: %s\n", req.URL.Host, err)
+					}
+					return
+				}
+				// Drain the body, so the connection is returned to the idle pool
+				io.Copy(ioutil.Discard, res.Body)
+				res.Body.Close()
+			}(req)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+}
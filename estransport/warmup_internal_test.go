@@ -0,0 +1,117 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package estransport
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWarmupConnections(t *testing.T) {
+	t.Run("Opens connections", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			numConns int
+			numHeads int
+		)
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "HEAD" {
+				mu.Lock()
+				numHeads++
+				mu.Unlock()
+				// Keep the requests in flight, so each one needs its own connection
+				time.Sleep(50 * time.Millisecond)
+			}
+		}))
+		server.Config.ConnState = func(c net.Conn, s http.ConnState) {
+			if s == http.StateNew {
+				mu.Lock()
+				numConns++
+				mu.Unlock()
+			}
+		}
+		server.Start()
+		defer server.Close()
+
+		u, _ := url.Parse(server.URL)
+		tp, err := New(Config{
+			URLs:              []*url.URL{u},
+			Transport:         &http.Transport{MaxIdleConnsPerHost: 5},
+			WarmupConnections: 3,
+			WarmupTimeout:     5 * time.Second,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		mu.Lock()
+		if numHeads != 3 || numConns != 3 {
+			t.Errorf("Unexpected number of requests and connections: heads=%d, conns=%d", numHeads, numConns)
+		}
+		mu.Unlock()
+
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest("GET", "/", nil)
+			res, err := tp.Perform(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			res.Body.Close()
+		}
+
+		mu.Lock()
+		if numConns != 3 {
+			t.Errorf("Expected the warm connections to be reused, got conns=%d", numConns)
+		}
+		mu.Unlock()
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		done := make(chan struct{})
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-done
+		}))
+		defer server.Close()
+		defer close(done)
+
+		u, _ := url.Parse(server.URL)
+		start := time.Now()
+		_, err := New(Config{
+			URLs:              []*url.URL{u},
+			Transport:         &http.Transport{},
+			WarmupConnections: 1,
+			WarmupTimeout:     10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("Expected New to return after the timeout, took %s", d)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+var (
+	defaultAsyncSearchPollInterval = time.Second
+
+	asyncSearchCleanupTimeout = 5 * time.Second
+)
+
+// AsyncSearchOptions configures the AsyncSearch helper.
+//
+type AsyncSearchOptions struct {
+	WaitForCompletionTimeout time.Duration // How long to wait for the results on submit. Default: server default (1s).
+	PollInterval             time.Duration // How long each poll waits for the completion on the server. Default: 1s.
+	KeepAlive                time.Duration // How long the async search is kept on the server. Default: server default (5d).
+}
+
+// AsyncSearchResult represents the Async Search API response.
+//
+type AsyncSearchResult struct {
+	ID        string         `json:"id"`
+	IsPartial bool           `json:"is_partial"`
+	IsRunning bool           `json:"is_running"`
+	Response  SearchResponse `json:"response"`
+}
+
+// AsyncSearch submits the query as an async search, and polls for the results
+// until the search completes or the context is done.
+//
+// The async search is deleted from the cluster once the helper returns.
+//
+// When the context is done before the search completes, the last received,
+// partial results are returned together with the context error.
+//
+func AsyncSearch(ctx context.Context, client *elasticsearch.Client, index string, query io.Reader, opts AsyncSearchOptions) (*AsyncSearchResult, error) {
+	var result AsyncSearchResult
+
+	if opts.PollInterval == 0 {
+		opts.PollInterval = defaultAsyncSearchPollInterval
+	}
+
+	submitOpts := []func(*esapi.AsyncSearchSubmitRequest){
+		client.AsyncSearch.Submit.WithContext(ctx),
+		client.AsyncSearch.Submit.WithBody(query),
+	}
+	if index != "" {
+		submitOpts = append(submitOpts, client.AsyncSearch.Submit.WithIndex(index))
+	}
+	if opts.WaitForCompletionTimeout > 0 {
+		submitOpts = append(submitOpts, client.AsyncSearch.Submit.WithWaitForCompletionTimeout(opts.WaitForCompletionTimeout))
+	}
+	if opts.KeepAlive > 0 {
+		submitOpts = append(submitOpts, client.AsyncSearch.Submit.WithKeepAlive(opts.KeepAlive))
+	}
+
+	res, err := client.AsyncSearch.Submit(submitOpts...)
+	if err := decodeResponse("async search: submit", res, err, &result); err != nil {
+		return nil, err
+	}
+	defer deleteAsyncSearch(client, &result)
+
+	for result.IsRunning {
+		if ctx.Err() != nil {
+			result.IsPartial = true
+			return &result, ctx.Err()
+		}
+
+		var next AsyncSearchResult
+		res, err := client.AsyncSearch.Get(
+			result.ID,
+			client.AsyncSearch.Get.WithContext(ctx),
+			client.AsyncSearch.Get.WithWaitForCompletionTimeout(opts.PollInterval),
+		)
+		if err := decodeResponse("async search: get", res, err, &next); err != nil {
+			if ctx.Err() != nil {
+				result.IsPartial = true
+				return &result, ctx.Err()
+			}
+			return nil, err
+		}
+
+		// The response to get doesn't always repeat the ID
+		if next.ID == "" {
+			next.ID = result.ID
+		}
+		result = next
+	}
+
+	return &result, nil
+}
+
+// deleteAsyncSearch removes the async search from the cluster, ignoring any errors.
+//
+// It uses a separate context bounded by asyncSearchCleanupTimeout, so the cleanup runs
+// even when the caller's context is done, without blocking on an unresponsive cluster.
+//
+func deleteAsyncSearch(client *elasticsearch.Client, result *AsyncSearchResult) {
+	if result.ID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), asyncSearchCleanupTimeout)
+	defer cancel()
+
+	res, err := client.AsyncSearch.Delete(result.ID, client.AsyncSearch.Delete.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestAsyncSearch(t *testing.T) {
+	newResponse := func(body string) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	t.Run("Poll until completion", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			reqs    []string
+			numGets int
+		)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				reqs = append(reqs, r.Method+" "+r.URL.Path)
+
+				switch r.Method {
+				case "POST":
+					return newResponse(`{"id":"abc","is_partial":true,"is_running":true,"response":{"hits":{"total":{"value":1,"relation":"gte"}}}}`), nil
+				case "GET":
+					numGets++
+					if numGets < 2 {
+						return newResponse(`{"id":"abc","is_partial":true,"is_running":true,"response":{"hits":{"total":{"value":5,"relation":"gte"}}}}`), nil
+					}
+					return newResponse(`{"id":"abc","is_partial":false,"is_running":false,"response":{"hits":{"total":{"value":10,"relation":"eq"},"hits":[{"_index":"test","_id":"1","_source":{"foo":"bar"}}]}}}`), nil
+				default:
+					return newResponse(`{"acknowledged":true}`), nil
+				}
+			},
+		}})
+
+		result, err := AsyncSearch(context.Background(), es, "test", strings.NewReader(`{"query":{"match_all":{}}}`), AsyncSearchOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if result.IsRunning || result.IsPartial {
+			t.Errorf("Unexpected result status: %+v", result)
+		}
+		if result.Response.Hits.Total.Value != 10 || result.Response.Hits.Total.Relation != "eq" {
+			t.Errorf("Unexpected total: %+v", result.Response.Hits.Total)
+		}
+		if len(result.Response.Hits.Hits) != 1 || string(result.Response.Hits.Hits[0].Source) != `{"foo":"bar"}` {
+			t.Errorf("Unexpected hits: %+v", result.Response.Hits.Hits)
+		}
+
+		expected := []string{
+			"POST /test/_async_search",
+			"GET /_async_search/abc",
+			"GET /_async_search/abc",
+			"DELETE /_async_search/abc",
+		}
+		if strings.Join(reqs, ",") != strings.Join(expected, ",") {
+			t.Errorf("Unexpected requests:\nwant: %s\ngot:  %s", expected, reqs)
+		}
+	})
+
+	t.Run("Partial results on deadline", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			deleted bool
+		)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				switch r.Method {
+				case "POST":
+					return newResponse(`{"id":"abc","is_partial":true,"is_running":true,"response":{"hits":{"total":{"value":3,"relation":"gte"}}}}`), nil
+				case "GET":
+					<-r.Context().Done()
+					return nil, r.Context().Err()
+				default:
+					mu.Lock()
+					deleted = true
+					mu.Unlock()
+					return newResponse(`{"acknowledged":true}`), nil
+				}
+			},
+		}})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		result, err := AsyncSearch(ctx, es, "test", strings.NewReader(`{}`), AsyncSearchOptions{})
+		if err != context.DeadlineExceeded {
+			t.Fatalf("Expected context.DeadlineExceeded, got: %v", err)
+		}
+		if result == nil || !result.IsPartial || result.Response.Hits.Total.Value != 3 {
+			t.Errorf("Expected partial results, got: %+v", result)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !deleted {
+			t.Errorf("Expected the async search to be deleted")
+		}
+	})
+
+	t.Run("Bounded cleanup on unresponsive cluster", func(t *testing.T) {
+		defer func(d time.Duration) { asyncSearchCleanupTimeout = d }(asyncSearchCleanupTimeout)
+		asyncSearchCleanupTimeout = 50 * time.Millisecond
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				switch r.Method {
+				case "POST":
+					return newResponse(`{"id":"abc","is_partial":true,"is_running":true,"response":{}}`), nil
+				default:
+					<-r.Context().Done()
+					return nil, r.Context().Err()
+				}
+			},
+		}})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := AsyncSearch(ctx, es, "test", strings.NewReader(`{}`), AsyncSearchOptions{})
+		if err != context.DeadlineExceeded {
+			t.Fatalf("Expected context.DeadlineExceeded, got: %v", err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("Expected the cleanup to be bounded, took: %s", d)
+		}
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/Tritura/go-elasticsearch/v8/estransport"
 	"io"
@@ -42,6 +43,13 @@ type BulkIndexer interface {
 	// Add adds an item to the indexer. It returns an error when the item cannot be added.
 	// Use the OnSuccess and OnFailure callbacks to get the operation result for the item.
 	//
+	// The item body must be compact JSON, since a newline separates the items in the request;
+	// an item with a body containing a newline, except for a trailing one, is rejected with
+	// an *InvalidBodyError. When the body is a *bytes.Buffer, *bytes.Reader or *strings.Reader,
+	// Add returns the error, and the item is not added. Other bodies are checked by the worker,
+	// since reading them here would consume them: the item is not written to the request,
+	// and its OnFailure callback receives the error.
+	//
 	// You must call the Close() method after you're done adding items.
 	//
 	// It is safe for concurrent use. When it's called from goroutines,
@@ -62,6 +70,16 @@ type BulkIndexerConfig struct {
 	FlushBytes    int           // The flush threshold in bytes. Defaults to 5MB.
 	FlushInterval time.Duration // The flush threshold as duration. Defaults to 30sec.
 
+	// The maximum number of bulk requests in flight at the same time, across all workers.
+	// A worker which flushes while the limit is reached waits for another request to complete,
+	// while the other workers keep adding items to their buffers. Defaults to 0, unlimited.
+	MaxConcurrentFlushes int
+
+	// The maximum size of a single item in bytes, including its metadata line, eg. below "http.max_content_length".
+	// A larger item is rejected with its OnFailure callback before it's added to the batch, so it doesn't fail
+	// the bulk request with the other items. Defaults to 0, unlimited.
+	MaxItemBytes int
+
 	Client      *elasticsearch.Client   // The Elasticsearch client.
 	Decoder     BulkResponseJSONDecoder // A custom JSON decoder.
 	DebugLogger BulkIndexerDebugLogger  // An optional logger for debugging.
@@ -70,6 +88,25 @@ type BulkIndexerConfig struct {
 	OnFlushStart func(context.Context) context.Context // Called when the flush starts.
 	OnFlushEnd   func(context.Context)                 // Called when the flush ends.
 
+	// Optional function called for every flush to provide additional HTTP headers for the bulk request,
+	// eg. "X-Opaque-Id" with a batch identifier. The values override the headers from Header.
+	FlushHeader func(context.Context) http.Header
+
+	// Optional function returning the document ID for the "index" and "create" items without one,
+	// eg. a hash of the body, so the retried items don't create duplicate documents.
+	// An empty ID leaves the generation to Elasticsearch. Default: nil, the IDs are generated by Elasticsearch.
+	IDFunc func(body []byte) string
+
+	// Create the index with the body, eg. the mappings and settings, before the first request,
+	// unless it already exists. Requires Index to be set. When the creation fails, the flush fails,
+	// and the creation is retried with the next flush. Default: nil, the index isn't created.
+	EnsureIndex []byte
+
+	// Build the bulk requests without sending them to Elasticsearch. The item callbacks are called
+	// with synthetic, successful responses, and the statistics are updated as for real requests.
+	DryRun       bool
+	DryRunWriter io.Writer // Optional writer receiving the bulk request bodies in the dry-run mode.
+
 	// Parameters of the Bulk API.
 	Index               string
 	ErrorTrace          bool
@@ -98,6 +135,18 @@ type BulkIndexerStats struct {
 	NumUpdated  uint64
 	NumDeleted  uint64
 	NumRequests uint64
+
+	// The total and the average server-side processing time of the bulk requests, reported in the "took"
+	// field of the responses; compare it to the client-side latency to find the time spent queuing.
+	TookTotal time.Duration
+	TookAvg   time.Duration
+
+	// NumQueued is the current number of items waiting in the queue, not picked up by a worker yet.
+	// Unlike the other fields, it's not a counter; use it to throttle the producer.
+	NumQueued uint64
+
+	// NumFlushesInFlight is the current number of bulk requests in flight; like NumQueued, it's not a counter.
+	NumFlushesInFlight uint64
 }
 
 // BulkIndexerItem represents an indexer item.
@@ -113,6 +162,21 @@ type BulkIndexerItem struct {
 	OnFailure func(context.Context, BulkIndexerItem, BulkIndexerResponseItem, error) // Per item
 }
 
+// InvalidBodyError is returned for an item with a body containing a newline,
+// which would corrupt the newline-delimited bulk request.
+//
+type InvalidBodyError struct {
+	Action     string
+	DocumentID string
+	Offset     int // The offset of the newline in the body
+}
+
+// Error returns the error as a string.
+//
+func (e *InvalidBodyError) Error() string {
+	return fmt.Sprintf("invalid body for item [%s:%s]: newline at offset %d; the body must be compact JSON", e.Action, e.DocumentID, e.Offset)
+}
+
 // BulkIndexerResponse represents the Elasticsearch response.
 //
 type BulkIndexerResponse struct {
@@ -168,6 +232,11 @@ type bulkIndexer struct {
 	done    chan bool
 	stats   *bulkIndexerStats
 
+	ensureIndexMu sync.Mutex
+	indexEnsured  bool
+
+	flushSem chan struct{} // Limits the number of concurrent flushes, when configured
+
 	config BulkIndexerConfig
 }
 
@@ -180,6 +249,9 @@ type bulkIndexerStats struct {
 	numUpdated  uint64
 	numDeleted  uint64
 	numRequests uint64
+	numTook     uint64
+	tookMillis  uint64
+	numInFlight uint64
 }
 
 // NewBulkIndexer creates a new bulk indexer.
@@ -193,10 +265,22 @@ func NewBulkIndexer(cfg BulkIndexerConfig) (BulkIndexer, error) {
 		cfg.Decoder = defaultJSONDecoder{}
 	}
 
+	if cfg.EnsureIndex != nil && cfg.Index == "" {
+		return nil, errors.New("cannot ensure index: index not set")
+	}
+
 	if cfg.NumWorkers == 0 {
 		cfg.NumWorkers = runtime.NumCPU()
 	}
 
+	if cfg.MaxConcurrentFlushes < 0 {
+		return nil, errors.New("invalid MaxConcurrentFlushes: must not be negative")
+	}
+
+	if cfg.MaxItemBytes < 0 {
+		return nil, errors.New("invalid MaxItemBytes: must not be negative")
+	}
+
 	if cfg.FlushBytes == 0 {
 		cfg.FlushBytes = 5e+6
 	}
@@ -211,6 +295,10 @@ func NewBulkIndexer(cfg BulkIndexerConfig) (BulkIndexer, error) {
 		stats:  &bulkIndexerStats{},
 	}
 
+	if cfg.MaxConcurrentFlushes > 0 {
+		bi.flushSem = make(chan struct{}, cfg.MaxConcurrentFlushes)
+	}
+
 	bi.init()
 
 	return &bi, nil
@@ -221,6 +309,12 @@ func NewBulkIndexer(cfg BulkIndexerConfig) (BulkIndexer, error) {
 // Adding an item after a call to Close() will panic.
 //
 func (bi *bulkIndexer) Add(ctx context.Context, item BulkIndexerItem) error {
+	if item.Body != nil {
+		if i, ok := bodyNewline(item.Body); ok && i >= 0 {
+			return &InvalidBodyError{Action: item.Action, DocumentID: item.DocumentID, Offset: i}
+		}
+	}
+
 	atomic.AddUint64(&bi.stats.numAdded, 1)
 
 	select {
@@ -272,7 +366,7 @@ func (bi *bulkIndexer) Close(ctx context.Context) error {
 // Stats returns indexer statistics.
 //
 func (bi *bulkIndexer) Stats() BulkIndexerStats {
-	return BulkIndexerStats{
+	stats := BulkIndexerStats{
 		NumAdded:    atomic.LoadUint64(&bi.stats.numAdded),
 		NumFlushed:  atomic.LoadUint64(&bi.stats.numFlushed),
 		NumFailed:   atomic.LoadUint64(&bi.stats.numFailed),
@@ -281,7 +375,18 @@ func (bi *bulkIndexer) Stats() BulkIndexerStats {
 		NumUpdated:  atomic.LoadUint64(&bi.stats.numUpdated),
 		NumDeleted:  atomic.LoadUint64(&bi.stats.numDeleted),
 		NumRequests: atomic.LoadUint64(&bi.stats.numRequests),
+		NumQueued:   uint64(len(bi.queue)),
+
+		NumFlushesInFlight: atomic.LoadUint64(&bi.stats.numInFlight),
+	}
+
+	// Take the average over the responses, since the failed requests don't report the time
+	if numTook := atomic.LoadUint64(&bi.stats.numTook); numTook > 0 {
+		stats.TookTotal = time.Duration(atomic.LoadUint64(&bi.stats.tookMillis)) * time.Millisecond
+		stats.TookAvg = stats.TookTotal / time.Duration(numTook)
 	}
+
+	return stats
 }
 
 // init initializes the bulk indexer.
@@ -295,6 +400,7 @@ func (bi *bulkIndexer) init() {
 			ch:  bi.queue,
 			bi:  bi,
 			buf: bytes.NewBuffer(make([]byte, 0, bi.config.FlushBytes)),
+			res: bytes.NewBuffer(make([]byte, 0, 512)),
 			aux: make([]byte, 0, 512)}
 		w.run()
 		bi.workers = append(bi.workers, &w)
@@ -338,8 +444,13 @@ type worker struct {
 	mu    sync.Mutex
 	bi    *bulkIndexer
 	buf   *bytes.Buffer
+	res   *bytes.Buffer
 	aux   []byte
 	items []BulkIndexerItem
+
+	metaActions   map[string][]byte // Cached encoded action prefixes, eg. `{"index":{`
+	metaIndex     string            // The index of the cached encoded index metadata
+	metaIndexJSON []byte            // Cached encoded index metadata, eg. `"_index":"test"`
 }
 
 // run launches the worker in a goroutine.
@@ -360,7 +471,20 @@ func (w *worker) run() {
 				w.bi.config.DebugLogger.Printf("[worker-%03d] Received item [%s:%s]\n", w.id, item.Action, item.DocumentID)
 			}
 
+			if err := w.setDocumentID(&item); err != nil {
+				if item.OnFailure != nil {
+					item.OnFailure(ctx, item, BulkIndexerResponseItem{}, err)
+				}
+				atomic.AddUint64(&w.bi.stats.numFailed, 1)
+				w.mu.Unlock()
+				continue
+			}
+
+			// Remove the partially written item from the buffer on failure
+			offset := w.buf.Len()
+
 			if err := w.writeMeta(item); err != nil {
+				w.buf.Truncate(offset)
 				if item.OnFailure != nil {
 					item.OnFailure(ctx, item, BulkIndexerResponseItem{}, err)
 				}
@@ -370,7 +494,20 @@ func (w *worker) run() {
 			}
 
 			if err := w.writeBody(&item); err != nil {
+				w.buf.Truncate(offset)
+				if item.OnFailure != nil {
+					item.OnFailure(ctx, item, BulkIndexerResponseItem{}, err)
+				}
+				atomic.AddUint64(&w.bi.stats.numFailed, 1)
+				w.mu.Unlock()
+				continue
+			}
+
+			if max := w.bi.config.MaxItemBytes; max > 0 && w.buf.Len()-offset > max {
+				size := w.buf.Len() - offset
+				w.buf.Truncate(offset)
 				if item.OnFailure != nil {
+					err := fmt.Errorf("item size %d bytes exceeds MaxItemBytes %d", size, max)
 					item.OnFailure(ctx, item, BulkIndexerResponseItem{}, err)
 				}
 				atomic.AddUint64(&w.bi.stats.numFailed, 1)
@@ -393,15 +530,31 @@ func (w *worker) run() {
 	}()
 }
 
+// setDocumentID sets the document ID returned by IDFunc for the item without one,
+// replacing the item body with the buffered copy; it must be called under a lock.
+//
+func (w *worker) setDocumentID(item *BulkIndexerItem) error {
+	if w.bi.config.IDFunc == nil || item.DocumentID != "" || item.Body == nil {
+		return nil
+	}
+	if item.Action != "index" && item.Action != "create" {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(item.Body)
+	if err != nil {
+		return fmt.Errorf("cannot read body: %s", err)
+	}
+	item.DocumentID = w.bi.config.IDFunc(body)
+	item.Body = bytes.NewReader(body)
+
+	return nil
+}
+
 // writeMeta formats and writes the item metadata to the buffer; it must be called under a lock.
 //
 func (w *worker) writeMeta(item BulkIndexerItem) error {
-	w.buf.WriteRune('{')
-	w.aux = strconv.AppendQuote(w.aux, item.Action)
-	w.buf.Write(w.aux)
-	w.aux = w.aux[:0]
-	w.buf.WriteRune(':')
-	w.buf.WriteRune('{')
+	w.buf.Write(w.encodedAction(item.Action))
 	if item.DocumentID != "" {
 		w.buf.WriteString(`"_id":`)
 		w.aux = strconv.AppendQuote(w.aux, item.DocumentID)
@@ -412,10 +565,7 @@ func (w *worker) writeMeta(item BulkIndexerItem) error {
 		if item.DocumentID != "" {
 			w.buf.WriteRune(',')
 		}
-		w.buf.WriteString(`"_index":`)
-		w.aux = strconv.AppendQuote(w.aux, item.Index)
-		w.buf.Write(w.aux)
-		w.aux = w.aux[:0]
+		w.buf.Write(w.encodedIndex(item.Index))
 	}
 	w.buf.WriteRune('}')
 	w.buf.WriteRune('}')
@@ -423,6 +573,39 @@ func (w *worker) writeMeta(item BulkIndexerItem) error {
 	return nil
 }
 
+// encodedAction returns the encoded action prefix of the metadata line, caching it for subsequent items;
+// it must be called under a lock.
+//
+func (w *worker) encodedAction(action string) []byte {
+	if b, ok := w.metaActions[action]; ok {
+		return b
+	}
+
+	if w.metaActions == nil {
+		w.metaActions = make(map[string][]byte)
+	}
+
+	b := append([]byte{'{'}, strconv.Quote(action)...)
+	b = append(b, ':', '{')
+	w.metaActions[action] = b
+
+	return b
+}
+
+// encodedIndex returns the encoded "_index" field of the metadata line, caching it until the index changes;
+// it must be called under a lock.
+//
+func (w *worker) encodedIndex(index string) []byte {
+	if w.metaIndexJSON != nil && w.metaIndex == index {
+		return w.metaIndexJSON
+	}
+
+	w.metaIndex = index
+	w.metaIndexJSON = append(append(w.metaIndexJSON[:0], `"_index":`...), strconv.Quote(index)...)
+
+	return w.metaIndexJSON
+}
+
 // writeBody writes the item body to the buffer; it must be called under a lock.
 //
 func (w *worker) writeBody(item *BulkIndexerItem) error {
@@ -440,12 +623,21 @@ func (w *worker) writeBody(item *BulkIndexerItem) error {
 			item.Body = getBody()
 		}
 
+		offset := w.buf.Len()
 		if _, err := w.buf.ReadFrom(item.Body); err != nil {
 			if w.bi.config.OnError != nil {
 				w.bi.config.OnError(context.Background(), err)
 			}
 			return err
 		}
+
+		// Remove the trailing newline, eg. from json.Encoder, and reject the bodies with embedded newlines
+		if b := w.buf.Bytes()[offset:]; len(b) > 0 && b[len(b)-1] == '\n' {
+			w.buf.Truncate(w.buf.Len() - 1)
+		}
+		if i := bytes.IndexByte(w.buf.Bytes()[offset:], '\n'); i >= 0 {
+			return &InvalidBodyError{Action: item.Action, DocumentID: item.DocumentID, Offset: i}
+		}
 		w.buf.WriteRune('\n')
 
 		if getBody != nil && (item.OnSuccess != nil || item.OnFailure != nil) {
@@ -455,6 +647,47 @@ func (w *worker) writeBody(item *BulkIndexerItem) error {
 	return nil
 }
 
+// bodyNewline returns the offset of the first newline in the body, except for a trailing one,
+// or -1 when there's none; ok is false when the body cannot be inspected without consuming it.
+//
+func bodyNewline(body io.Reader) (offset int, ok bool) {
+	switch b := body.(type) {
+	case *bytes.Buffer:
+		p := b.Bytes()
+		if i := bytes.IndexByte(p, '\n'); i >= 0 && i < len(p)-1 {
+			return i, true
+		}
+		return -1, true
+	case interface {
+		io.ReaderAt
+		Len() int
+		Size() int64
+	}:
+		// A *bytes.Reader or *strings.Reader: scan the unread part, without moving the read offset
+		var (
+			buf   [4096]byte
+			n     = b.Len()
+			start = b.Size() - int64(n)
+		)
+		for off := 0; off < n; {
+			p := buf[:]
+			if n-off < len(p) {
+				p = p[:n-off]
+			}
+			m, err := b.ReadAt(p, start+int64(off))
+			if i := bytes.IndexByte(p[:m], '\n'); i >= 0 && off+i < n-1 {
+				return off + i, true
+			}
+			if m == 0 || (err != nil && err != io.EOF) {
+				return -1, false
+			}
+			off += m
+		}
+		return -1, true
+	}
+	return -1, false
+}
+
 // flush writes out the worker buffer; it must be called under a lock.
 //
 func (w *worker) flush(ctx context.Context) error {
@@ -488,6 +721,30 @@ func (w *worker) flush(ctx context.Context) error {
 	}
 
 	atomic.AddUint64(&w.bi.stats.numRequests, 1)
+
+	if w.bi.config.DryRun {
+		if w.bi.config.DryRunWriter != nil {
+			if _, err := w.bi.config.DryRunWriter.Write(w.buf.Bytes()); err != nil {
+				atomic.AddUint64(&w.bi.stats.numFailed, uint64(len(w.items)))
+				if w.bi.config.OnError != nil {
+					w.bi.config.OnError(ctx, fmt.Errorf("flush: %s", err))
+				}
+				return fmt.Errorf("flush: %s", err)
+			}
+		}
+		blk = w.dryRunResponse()
+		w.handleResponse(ctx, &blk)
+		return nil
+	}
+
+	if err := w.bi.ensureIndex(ctx); err != nil {
+		atomic.AddUint64(&w.bi.stats.numFailed, uint64(len(w.items)))
+		if w.bi.config.OnError != nil {
+			w.bi.config.OnError(ctx, fmt.Errorf("flush: %s", err))
+		}
+		return fmt.Errorf("flush: %s", err)
+	}
+
 	req := esapi.BulkRequest{
 		Index: w.bi.config.Index,
 		Body:  w.buf,
@@ -505,15 +762,30 @@ func (w *worker) flush(ctx context.Context) error {
 		Human:      w.bi.config.Human,
 		ErrorTrace: w.bi.config.ErrorTrace,
 		FilterPath: w.bi.config.FilterPath,
-		Header:     w.bi.config.Header,
+		Header:     w.bi.config.Header.Clone(),
 	}
 
 	// Add Header and MetaHeader to config if not already set
 	if req.Header == nil {
 		req.Header = http.Header{}
 	}
+	if w.bi.config.FlushHeader != nil {
+		for k, v := range w.bi.config.FlushHeader(ctx) {
+			req.Header[http.CanonicalHeaderKey(k)] = v
+		}
+	}
 	req.Header.Set(estransport.HeaderClientMeta, "h=bp")
 
+	// Wait for a slot, when the number of concurrent flushes is limited
+	if err := w.bi.acquireFlush(ctx); err != nil {
+		atomic.AddUint64(&w.bi.stats.numFailed, uint64(len(w.items)))
+		if w.bi.config.OnError != nil {
+			w.bi.config.OnError(ctx, fmt.Errorf("flush: %s", err))
+		}
+		return fmt.Errorf("flush: %s", err)
+	}
+	defer w.bi.releaseFlush()
+
 	res, err := req.Do(ctx, w.bi.config.Client)
 	if err != nil {
 		atomic.AddUint64(&w.bi.stats.numFailed, uint64(len(w.items)))
@@ -534,7 +806,31 @@ func (w *worker) flush(ctx context.Context) error {
 		return fmt.Errorf("flush: %s", res.String())
 	}
 
-	if err := w.bi.config.Decoder.UnmarshalFromReader(res.Body, &blk); err != nil {
+	var body io.Reader = res.Body
+
+	// When no item needs the per-item information, check the "errors" flag first,
+	// and skip decoding the items when all of them succeeded.
+	//
+	if w.canSkipItems() {
+		w.res.Reset()
+		defer w.res.Reset()
+
+		if _, err := w.res.ReadFrom(res.Body); err != nil {
+			if w.bi.config.OnError != nil {
+				w.bi.config.OnError(ctx, fmt.Errorf("flush: %s", err))
+			}
+			return fmt.Errorf("flush: error reading response body: %s", err)
+		}
+
+		if took, hasErrors, ok := scanBulkErrors(w.res.Bytes()); ok && !hasErrors {
+			w.bi.recordTook(took)
+			w.handleSuccess()
+			return nil
+		}
+		body = bytes.NewReader(w.res.Bytes())
+	}
+
+	if err := w.bi.config.Decoder.UnmarshalFromReader(body, &blk); err != nil {
 		// TODO(karmi): Wrap error (include response struct)
 		if w.bi.config.OnError != nil {
 			w.bi.config.OnError(ctx, fmt.Errorf("flush: %s", err))
@@ -542,6 +838,68 @@ func (w *worker) flush(ctx context.Context) error {
 		return fmt.Errorf("flush: error parsing response body: %s", err)
 	}
 
+	w.bi.recordTook(blk.Took)
+	w.handleResponse(ctx, &blk)
+
+	return err
+}
+
+// recordTook adds the server-side processing time of a bulk request, in milliseconds, to the statistics.
+//
+func (bi *bulkIndexer) recordTook(took int) {
+	atomic.AddUint64(&bi.stats.tookMillis, uint64(took))
+	atomic.AddUint64(&bi.stats.numTook, 1)
+}
+
+// acquireFlush waits for a slot for the bulk request, when the number of concurrent flushes is limited,
+// and records the request as in flight.
+//
+func (bi *bulkIndexer) acquireFlush(ctx context.Context) error {
+	if bi.flushSem != nil {
+		select {
+		case bi.flushSem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	atomic.AddUint64(&bi.stats.numInFlight, 1)
+	return nil
+}
+
+// releaseFlush releases the slot acquired with acquireFlush.
+//
+func (bi *bulkIndexer) releaseFlush() {
+	atomic.AddUint64(&bi.stats.numInFlight, ^uint64(0))
+	if bi.flushSem != nil {
+		<-bi.flushSem
+	}
+}
+
+// ensureIndex creates the index with the EnsureIndex body, when set, on the first call;
+// the concurrent calls wait for the creation, and the failed creation is retried on the next call.
+//
+func (bi *bulkIndexer) ensureIndex(ctx context.Context) error {
+	if bi.config.EnsureIndex == nil {
+		return nil
+	}
+
+	bi.ensureIndexMu.Lock()
+	defer bi.ensureIndexMu.Unlock()
+
+	if bi.indexEnsured {
+		return nil
+	}
+	if err := EnsureIndex(ctx, bi.config.Client, bi.config.Index, bi.config.EnsureIndex); err != nil {
+		return err
+	}
+	bi.indexEnsured = true
+	return nil
+}
+
+// handleResponse updates the statistics and calls the item callbacks for the response items;
+// it must be called under a lock.
+//
+func (w *worker) handleResponse(ctx context.Context, blk *BulkIndexerResponse) {
 	for i, blkItem := range blk.Items {
 		var (
 			item BulkIndexerItem
@@ -581,8 +939,120 @@ func (w *worker) flush(ctx context.Context) error {
 			}
 		}
 	}
+}
 
-	return err
+// canSkipItems returns true when the items in the response can be skipped
+// for a response without errors; it must be called under a lock.
+//
+// The items are needed when the response is decoded with a custom decoder,
+// when any item has a success callback, and for actions other than index, create
+// and update: a delete reports a missing document as a "not_found" item
+// without setting the errors flag.
+//
+func (w *worker) canSkipItems() bool {
+	if _, ok := w.bi.config.Decoder.(defaultJSONDecoder); !ok {
+		return false
+	}
+	for _, item := range w.items {
+		if item.OnSuccess != nil {
+			return false
+		}
+		switch item.Action {
+		case "index", "create", "update":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// handleSuccess updates the statistics for a response without errors,
+// using the actions of the buffered items; it must be called under a lock.
+//
+func (w *worker) handleSuccess() {
+	atomic.AddUint64(&w.bi.stats.numFlushed, uint64(len(w.items)))
+
+	for _, item := range w.items {
+		switch item.Action {
+		case "index":
+			atomic.AddUint64(&w.bi.stats.numIndexed, 1)
+		case "create":
+			atomic.AddUint64(&w.bi.stats.numCreated, 1)
+		case "update":
+			atomic.AddUint64(&w.bi.stats.numUpdated, 1)
+		}
+	}
+}
+
+// scanBulkErrors returns the values of the "took" field and the "errors" flag in the bulk response body.
+//
+// It scans the top-level keys only until it finds the flag, which Elasticsearch
+// emits before the items; when the flag is missing, or the items come first,
+// ok is false and the body has to be decoded in full.
+//
+func scanBulkErrors(body []byte) (took int, hasErrors bool, ok bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return 0, false, false
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return 0, false, false
+		}
+
+		switch t {
+		case "took":
+			if err := dec.Decode(&took); err != nil {
+				return 0, false, false
+			}
+		case "errors":
+			if err := dec.Decode(&hasErrors); err != nil {
+				return 0, false, false
+			}
+			return took, hasErrors, true
+		case "items":
+			return 0, false, false
+		default:
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return 0, false, false
+			}
+		}
+	}
+
+	return 0, false, false
+}
+
+// dryRunResponse returns a synthetic, successful response for the buffered items;
+// it must be called under a lock.
+//
+func (w *worker) dryRunResponse() BulkIndexerResponse {
+	blk := BulkIndexerResponse{Items: make([]map[string]BulkIndexerResponseItem, 0, len(w.items))}
+
+	for _, item := range w.items {
+		info := BulkIndexerResponseItem{
+			Index:      item.Index,
+			DocumentID: item.DocumentID,
+			Version:    1,
+			Result:     "created",
+			Status:     201,
+		}
+		if info.Index == "" {
+			info.Index = w.bi.config.Index
+		}
+		switch item.Action {
+		case "update":
+			info.Result, info.Status = "updated", 200
+		case "delete":
+			info.Result, info.Status = "deleted", 200
+		}
+		blk.Items = append(blk.Items, map[string]BulkIndexerResponseItem{item.Action: info})
+	}
+
+	return blk
 }
 
 type defaultJSONDecoder struct{}
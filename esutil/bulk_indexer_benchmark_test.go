@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/Tritura/go-elasticsearch/v8"
@@ -57,6 +58,42 @@ func (t *mockTransp) RoundTrip(req *http.Request) (*http.Response, error) {
 	return &http.Response{Body: ioutil.NopCloser(strings.NewReader(mockResponseBody))}, nil // 1x alloc
 }
 
+// mockSuccessTransp returns an all-success response with an item for every action in the request.
+//
+type mockSuccessTransp struct {
+	mu        sync.Mutex
+	responses map[int][]byte
+}
+
+func (t *mockSuccessTransp) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := ioutil.ReadAll(req.Body)
+	n := bytes.Count(body, []byte("\n")) / 2
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.responses == nil {
+		t.responses = make(map[int][]byte)
+	}
+	res, ok := t.responses[n]
+	if !ok {
+		var buf bytes.Buffer
+		buf.WriteString(`{"took":30,"errors":false,"items":[`)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(`{"index":{"_index":"test","_id":"` + strconv.Itoa(i) + `","_version":1,"result":"created",`)
+			buf.WriteString(`"_shards":{"total":2,"successful":1,"failed":0},"status":201,"_seq_no":0,"_primary_term":1}}`)
+		}
+		buf.WriteString(`]}`)
+		res = buf.Bytes()
+		t.responses[n] = res
+	}
+
+	return &http.Response{Body: ioutil.NopCloser(bytes.NewReader(res))}, nil
+}
+
 func BenchmarkBulkIndexer(b *testing.B) {
 	b.ReportAllocs()
 
@@ -86,4 +123,63 @@ func BenchmarkBulkIndexer(b *testing.B) {
 			docIDBuf.Reset()
 		}
 	})
+
+	b.Run("Item index", func(b *testing.B) {
+		b.ResetTimer()
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransp{}})
+		bi, _ := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+			Client:     es,
+			FlushBytes: 1024,
+		})
+		defer bi.Close(context.Background())
+
+		docID := make([]byte, 0, 16)
+		var docIDBuf bytes.Buffer
+		docIDBuf.Grow(cap(docID))
+
+		for i := 0; i < b.N; i++ {
+			docID = strconv.AppendInt(docID, int64(i), 10)
+			docIDBuf.Write(docID)
+			bi.Add(context.Background(), esutil.BulkIndexerItem{
+				Action:     "index",
+				Index:      "test",
+				DocumentID: docIDBuf.String(),                  // 1x alloc
+				Body:       strings.NewReader(`{"foo":"bar"}`), // 1x alloc
+			})
+			docID = docID[:0]
+			docIDBuf.Reset()
+		}
+	})
+
+	b.Run("Large response", func(b *testing.B) {
+		for _, tt := range []struct {
+			name      string
+			onSuccess func(context.Context, esutil.BulkIndexerItem, esutil.BulkIndexerResponseItem)
+		}{
+			{"Items skipped", nil},
+			{"Items decoded", func(context.Context, esutil.BulkIndexerItem, esutil.BulkIndexerResponseItem) {}},
+		} {
+			b.Run(tt.name, func(b *testing.B) {
+				b.ReportAllocs()
+
+				es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockSuccessTransp{}})
+				bi, _ := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+					Client:     es,
+					NumWorkers: 1,
+					FlushBytes: 1024 * 1024,
+				})
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					bi.Add(context.Background(), esutil.BulkIndexerItem{
+						Action:    "index",
+						Body:      strings.NewReader(`{"foo":"bar"}`),
+						OnSuccess: tt.onSuccess,
+					})
+				}
+				bi.Close(context.Background())
+			})
+		}
+	})
 }
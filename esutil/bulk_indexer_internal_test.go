@@ -158,6 +158,11 @@ func TestBulkIndexer(t *testing.T) {
 		if stats.NumRequests != 3 {
 			t.Errorf("Unexpected NumRequests: want=%d, got=%d", 3, stats.NumRequests)
 		}
+
+		// took = 3 responses * 30ms
+		if stats.TookTotal != 90*time.Millisecond || stats.TookAvg != 30*time.Millisecond {
+			t.Errorf("Unexpected took: total=%s, avg=%s", stats.TookTotal, stats.TookAvg)
+		}
 	})
 
 	t.Run("Add() Timeout", func(t *testing.T) {
@@ -389,6 +394,48 @@ func TestBulkIndexer(t *testing.T) {
 		}
 	})
 
+	t.Run("Queue depth", func(t *testing.T) {
+		var (
+			started = make(chan struct{}, 1)
+			release = make(chan struct{})
+		)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				started <- struct{}{}
+				<-release
+				return &http.Response{
+					Body:   ioutil.NopCloser(strings.NewReader(`{"items":[{"index":{}}]}`)),
+					Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}, nil
+			},
+		}})
+
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{NumWorkers: 1, FlushBytes: 1, Client: es})
+
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", Body: strings.NewReader(`{}`)})
+		<-started
+
+		if n := bi.Stats().NumQueued; n != 0 {
+			t.Errorf("Unexpected NumQueued: want=0, got=%d", n)
+		}
+
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", Body: strings.NewReader(`{}`)})
+
+		if n := bi.Stats().NumQueued; n != 1 {
+			t.Errorf("Unexpected NumQueued: want=1, got=%d", n)
+		}
+
+		close(release)
+		if err := bi.Close(context.Background()); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+
+		if n := bi.Stats().NumQueued; n != 0 {
+			t.Errorf("Unexpected NumQueued: want=0, got=%d", n)
+		}
+	})
+
 	t.Run("OnFlush callbacks", func(t *testing.T) {
 		type contextKey string
 		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{}})
@@ -427,6 +474,112 @@ func TestBulkIndexer(t *testing.T) {
 		}
 	})
 
+	t.Run("Flush header", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			headers []http.Header
+			batch   int32
+		)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				headers = append(headers, req.Header)
+				mu.Unlock()
+				return &http.Response{
+					Body:   ioutil.NopCloser(strings.NewReader(`{"items":[{"index":{}}]}`)),
+					Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}, nil
+			},
+		}})
+
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{
+			NumWorkers: 1,
+			FlushBytes: 1,
+			Client:     es,
+			Header:     http.Header{"X-Foo": []string{"bar"}, "X-Opaque-Id": []string{"default"}},
+			FlushHeader: func(ctx context.Context) http.Header {
+				return http.Header{"X-Opaque-Id": []string{fmt.Sprintf("batch-%d", atomic.AddInt32(&batch, 1))}}
+			},
+		})
+
+		for i := 0; i < 2; i++ {
+			bi.Add(context.Background(), BulkIndexerItem{Action: "index", Body: strings.NewReader(`{}`)})
+		}
+		if err := bi.Close(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(headers) != 2 {
+			t.Fatalf("Unexpected number of requests: %d", len(headers))
+		}
+		for i, hdr := range headers {
+			if v := hdr.Get("X-Opaque-Id"); v != fmt.Sprintf("batch-%d", i+1) {
+				t.Errorf("Unexpected X-Opaque-Id: %s", v)
+			}
+			if v := hdr.Get("X-Foo"); v != "bar" {
+				t.Errorf("Unexpected X-Foo: %s", v)
+			}
+		}
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		var (
+			buf       bytes.Buffer
+			successes []BulkIndexerResponseItem
+		)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				t.Fatalf("Unexpected request")
+				return nil, nil
+			},
+		}})
+
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{
+			NumWorkers:   1,
+			Client:       es,
+			Index:        "test",
+			DryRun:       true,
+			DryRunWriter: &buf,
+		})
+
+		for _, action := range []string{"index", "create", "update", "delete"} {
+			bi.Add(context.Background(), BulkIndexerItem{
+				Action:     action,
+				DocumentID: action,
+				Body:       strings.NewReader(`{}`),
+				OnSuccess: func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem) {
+					successes = append(successes, res)
+				},
+			})
+		}
+		if err := bi.Close(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		stats := bi.Stats()
+		if stats.NumFlushed != 4 || stats.NumIndexed != 1 || stats.NumCreated != 1 || stats.NumUpdated != 1 || stats.NumDeleted != 1 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+
+		if len(successes) != 4 {
+			t.Fatalf("Unexpected number of OnSuccess calls: %d", len(successes))
+		}
+		for _, res := range successes {
+			if res.Index != "test" || res.Status > 201 {
+				t.Errorf("Unexpected response item: %+v", res)
+			}
+		}
+		if successes[2].Result != "updated" || successes[3].Result != "deleted" {
+			t.Errorf("Unexpected results: %s, %s", successes[2].Result, successes[3].Result)
+		}
+
+		if !strings.Contains(buf.String(), `{"delete":{"_id":"delete"}}`) {
+			t.Errorf("Unexpected dry-run output: %s", buf.String())
+		}
+	})
+
 	t.Run("Automatic flush", func(t *testing.T) {
 		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
 			RoundTripFunc: func(*http.Request) (*http.Response, error) {
@@ -654,6 +807,37 @@ func TestBulkIndexer(t *testing.T) {
 		}
 	})
 
+	t.Run("Worker.writeMeta() cache", func(t *testing.T) {
+		w := &worker{
+			buf: bytes.NewBuffer(make([]byte, 0, 5e+6)),
+			aux: make([]byte, 0, 512),
+		}
+
+		items := []BulkIndexerItem{
+			{Action: "index", DocumentID: "1", Index: "test"},
+			{Action: "index", DocumentID: "2", Index: "test"},
+			{Action: "create", DocumentID: "3", Index: "other"},
+			{Action: "index", DocumentID: "4"},
+			{Action: "delete", DocumentID: "5", Index: "test"},
+			{Action: "index", Index: `q"uote`},
+		}
+		for _, item := range items {
+			if err := w.writeMeta(item); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		want := `{"index":{"_id":"1","_index":"test"}}` + "\n" +
+			`{"index":{"_id":"2","_index":"test"}}` + "\n" +
+			`{"create":{"_id":"3","_index":"other"}}` + "\n" +
+			`{"index":{"_id":"4"}}` + "\n" +
+			`{"delete":{"_id":"5","_index":"test"}}` + "\n" +
+			`{"index":{"_index":"q\"uote"}}` + "\n"
+		if w.buf.String() != want {
+			t.Errorf("Unexpected output:\nwant: %s\ngot:  %s", want, w.buf.String())
+		}
+	})
+
 	t.Run("MetaHeader presence in Request header", func(t *testing.T) {
 		type args struct {
 			disableMetaHeader bool
@@ -760,8 +944,419 @@ func TestBulkIndexer(t *testing.T) {
 	})
 }
 
+func TestBulkIndexerEnsureIndex(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+		RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+
+			body := `{"took":1,"errors":false,"items":[]}`
+			if r.Method == "PUT" {
+				body = `{"acknowledged":true}`
+			}
+			return &http.Response{
+				Body:   ioutil.NopCloser(strings.NewReader(body)),
+				Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			}, nil
+		},
+	}})
+
+	if _, err := NewBulkIndexer(BulkIndexerConfig{Client: es, EnsureIndex: []byte(`{}`)}); err == nil {
+		t.Errorf("Expected error for missing index")
+	}
+
+	bi, _ := NewBulkIndexer(BulkIndexerConfig{
+		Client:      es,
+		Index:       "test",
+		EnsureIndex: []byte(`{"mappings":{"properties":{"title":{"type":"text"}}}}`),
+		NumWorkers:  4,
+		FlushBytes:  1,
+	})
+
+	for i := 0; i < 8; i++ {
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", Body: strings.NewReader(`{"title":"foo"}`)})
+	}
+	if err := bi.Close(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var numCreate int
+	for i, r := range requests {
+		if r == "PUT /test" {
+			numCreate++
+			if i != 0 {
+				t.Errorf("Expected the index to be created before the first write: %v", requests)
+			}
+		}
+	}
+	if numCreate != 1 {
+		t.Errorf("Unexpected number of index creations: %d", numCreate)
+	}
+}
+
+func TestBulkIndexerIDFunc(t *testing.T) {
+	var (
+		reqBody string
+		ids     []string
+	)
+
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+		RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+			b, _ := ioutil.ReadAll(r.Body)
+			reqBody = string(b)
+			return &http.Response{
+				Body:   ioutil.NopCloser(strings.NewReader(`{"took":1,"errors":false,"items":[{"index":{}},{"index":{}},{"delete":{}}]}`)),
+				Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			}, nil
+		},
+	}})
+
+	bi, _ := NewBulkIndexer(BulkIndexerConfig{
+		Client:     es,
+		NumWorkers: 1,
+		IDFunc: func(body []byte) string {
+			return fmt.Sprintf("len-%d", len(body))
+		},
+	})
+
+	onSuccess := func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem) {
+		ids = append(ids, item.DocumentID)
+	}
+	bi.Add(context.Background(), BulkIndexerItem{Action: "index", Body: strings.NewReader(`{"title":"foo"}`), OnSuccess: onSuccess})
+	bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: "1", Body: strings.NewReader(`{"title":"foo"}`), OnSuccess: onSuccess})
+	bi.Add(context.Background(), BulkIndexerItem{Action: "delete", DocumentID: "2", OnSuccess: onSuccess})
+
+	if err := bi.Close(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := `{"index":{"_id":"len-15"}}` + "\n" + `{"title":"foo"}` + "\n" +
+		`{"index":{"_id":"1"}}` + "\n" + `{"title":"foo"}` + "\n" +
+		`{"delete":{"_id":"2"}}` + "\n"
+	if reqBody != expected {
+		t.Errorf("Unexpected request body: %q", reqBody)
+	}
+
+	if strings.Join(ids, ",") != "len-15,1,2" {
+		t.Errorf("Unexpected IDs: %v", ids)
+	}
+}
+
+func TestBulkIndexerMaxConcurrentFlushes(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		inFlight    int
+		maxInFlight int
+		numRequests int
+
+		started = make(chan struct{}, 10)
+		release = make(chan struct{})
+	)
+
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+		RoundTripFunc: func(*http.Request) (*http.Response, error) {
+			mu.Lock()
+			inFlight++
+			numRequests++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+
+			started <- struct{}{}
+			<-release
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+
+			return &http.Response{
+				Body:   ioutil.NopCloser(strings.NewReader(`{"took":1,"errors":false,"items":[{"index":{}}]}`)),
+				Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			}, nil
+		},
+	}})
+
+	if _, err := NewBulkIndexer(BulkIndexerConfig{Client: es, MaxConcurrentFlushes: -1}); err == nil {
+		t.Errorf("Expected error for negative MaxConcurrentFlushes")
+	}
+
+	bi, _ := NewBulkIndexer(BulkIndexerConfig{
+		Client:               es,
+		NumWorkers:           4,
+		FlushBytes:           1,
+		MaxConcurrentFlushes: 2,
+	})
+
+	for i := 0; i < 4; i++ {
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", Body: strings.NewReader(`{}`)})
+	}
+
+	<-started
+	<-started
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	if numRequests != 2 {
+		t.Errorf("Unexpected number of requests in flight: want=2, got=%d", numRequests)
+	}
+	mu.Unlock()
+
+	if n := bi.Stats().NumFlushesInFlight; n != 2 {
+		t.Errorf("Unexpected NumFlushesInFlight: want=2, got=%d", n)
+	}
+
+	close(release)
+	if err := bi.Close(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	stats := bi.Stats()
+	if stats.NumFlushesInFlight != 0 {
+		t.Errorf("Unexpected NumFlushesInFlight: want=0, got=%d", stats.NumFlushesInFlight)
+	}
+	if stats.NumFlushed != 4 || stats.NumRequests != 4 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if maxInFlight != 2 {
+		t.Errorf("Unexpected maximum of requests in flight: want=2, got=%d", maxInFlight)
+	}
+}
+
+func TestBulkIndexerInvalidBody(t *testing.T) {
+	var (
+		reqBody   string
+		failedErr error
+	)
+
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+		RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+			b, _ := ioutil.ReadAll(r.Body)
+			reqBody = string(b)
+			return &http.Response{
+				Body:   ioutil.NopCloser(strings.NewReader(`{"took":1,"errors":false,"items":[{"index":{"_id":"2","status":201}}]}`)),
+				Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			}, nil
+		},
+	}})
+
+	bi, _ := NewBulkIndexer(BulkIndexerConfig{Client: es, NumWorkers: 1})
+
+	// A streamed body is checked by the worker
+	bi.Add(context.Background(), BulkIndexerItem{
+		Action:     "index",
+		DocumentID: "1",
+		Body:       ioutil.NopCloser(strings.NewReader("{\n  \"title\": \"foo\"\n}")),
+		OnFailure: func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem, err error) {
+			failedErr = err
+		},
+	})
+	bi.Add(context.Background(), BulkIndexerItem{
+		Action:     "index",
+		DocumentID: "2",
+		Body:       strings.NewReader("{\"title\":\"bar\"}\n"),
+	})
+
+	if err := bi.Close(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	bodyErr, ok := failedErr.(*InvalidBodyError)
+	if !ok {
+		t.Fatalf("Unexpected error: %#v", failedErr)
+	}
+	if bodyErr.DocumentID != "1" || bodyErr.Offset != 1 || !strings.Contains(bodyErr.Error(), "must be compact JSON") {
+		t.Errorf("Unexpected error: %s", bodyErr)
+	}
+
+	if expected := "{\"index\":{\"_id\":\"2\"}}\n{\"title\":\"bar\"}\n"; reqBody != expected {
+		t.Errorf("Unexpected request body: %q", reqBody)
+	}
+
+	stats := bi.Stats()
+	if stats.NumFailed != 1 || stats.NumFlushed != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	t.Run("Add", func(t *testing.T) {
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{Client: es, NumWorkers: 1})
+
+		long := strings.Repeat("a", 5000)
+		for _, tc := range []struct {
+			name   string
+			body   io.Reader
+			offset int
+		}{
+			{"strings.Reader", strings.NewReader("{\n}"), 1},
+			{"bytes.Reader", bytes.NewReader([]byte(`{"title":"` + long + "\n" + `"}`)), 5010},
+			{"bytes.Buffer", bytes.NewBufferString("{}\n\n"), 2},
+		} {
+			err := bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: tc.name, Body: tc.body})
+			bodyErr, ok := err.(*InvalidBodyError)
+			if !ok {
+				t.Errorf("%s: Unexpected error: %#v", tc.name, err)
+				continue
+			}
+			if bodyErr.DocumentID != tc.name || bodyErr.Offset != tc.offset {
+				t.Errorf("%s: Unexpected error: %s", tc.name, bodyErr)
+			}
+		}
+
+		// A trailing newline is allowed, and the body is left unread
+		r := strings.NewReader(`{"title":"` + long + `"}` + "\n")
+		if err := bi.Add(context.Background(), BulkIndexerItem{Action: "index", Body: r}); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+		if err := bi.Close(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if stats := bi.Stats(); stats.NumAdded != 1 || stats.NumFlushed != 1 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+	})
+}
+
+func TestScanBulkErrors(t *testing.T) {
+	tt := []struct {
+		name      string
+		body      string
+		took      int
+		hasErrors bool
+		ok        bool
+	}{
+		{"No errors", `{"took":30,"errors":false,"items":[{"index":{"status":201}}]}`, 30, false, true},
+		{"Errors", `{"took":30,"errors":true,"items":[{"index":{"status":409}}]}`, 30, true, true},
+		{"Items first", `{"items":[{"index":{"status":201}}],"errors":false}`, 0, false, false},
+		{"Missing flag", `{"took":30}`, 0, false, false},
+		{"Invalid JSON", `[]`, 0, false, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			took, hasErrors, ok := scanBulkErrors([]byte(tc.body))
+			if took != tc.took || hasErrors != tc.hasErrors || ok != tc.ok {
+				t.Errorf("Unexpected result: took=%d, hasErrors=%v, ok=%v", took, hasErrors, ok)
+			}
+		})
+	}
+
+	t.Run("Stats without errors", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					Body:   ioutil.NopCloser(strings.NewReader(`{"took":30,"errors":false,"items":[]}`)),
+					Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}, nil
+			},
+		}})
+
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{Client: es, NumWorkers: 1})
+		for _, action := range []string{"index", "create", "update"} {
+			bi.Add(context.Background(), BulkIndexerItem{Action: action, Body: strings.NewReader(`{}`)})
+		}
+		if err := bi.Close(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		stats := bi.Stats()
+		if stats.NumFlushed != 3 || stats.NumFailed != 0 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+		if stats.NumIndexed != 1 || stats.NumCreated != 1 || stats.NumUpdated != 1 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+		if stats.TookTotal != 30*time.Millisecond || stats.TookAvg != 30*time.Millisecond {
+			t.Errorf("Unexpected took: total=%s, avg=%s", stats.TookTotal, stats.TookAvg)
+		}
+	})
+}
+
 type customJSONDecoder struct{}
 
 func (d customJSONDecoder) UnmarshalFromReader(r io.Reader, blk *BulkIndexerResponse) error {
 	return json.NewDecoder(r).Decode(blk)
 }
+
+func TestBulkIndexerMaxItemBytes(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+		failed []string
+		errs   []error
+	)
+
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+		RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+			b, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			bodies = append(bodies, string(b))
+			mu.Unlock()
+			return &http.Response{
+				Body:   ioutil.NopCloser(strings.NewReader(`{"took":1,"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`)),
+				Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			}, nil
+		},
+	}})
+
+	if _, err := NewBulkIndexer(BulkIndexerConfig{Client: es, MaxItemBytes: -1}); err == nil {
+		t.Errorf("Expected error for negative MaxItemBytes")
+	}
+
+	bi, _ := NewBulkIndexer(BulkIndexerConfig{Client: es, NumWorkers: 1, MaxItemBytes: 100})
+
+	for _, id := range []string{"1", "2", "3"} {
+		body := `{"title":"foo"}`
+		if id == "2" {
+			body = `{"title":"` + strings.Repeat("x", 200) + `"}`
+		}
+		bi.Add(context.Background(), BulkIndexerItem{
+			Action:     "index",
+			DocumentID: id,
+			Body:       strings.NewReader(body),
+			OnFailure: func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				failed = append(failed, item.DocumentID)
+				errs = append(errs, err)
+			},
+		})
+	}
+
+	if err := bi.Close(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(failed) != 1 || failed[0] != "2" {
+		t.Fatalf("Unexpected failed items: %v", failed)
+	}
+	if !strings.Contains(errs[0].Error(), "exceeds MaxItemBytes 100") {
+		t.Errorf("Unexpected error: %s", errs[0])
+	}
+
+	if len(bodies) != 1 {
+		t.Fatalf("Unexpected number of requests: %d", len(bodies))
+	}
+	expected := `{"index":{"_id":"1"}}` + "\n" + `{"title":"foo"}` + "\n" +
+		`{"index":{"_id":"3"}}` + "\n" + `{"title":"foo"}` + "\n"
+	if bodies[0] != expected {
+		t.Errorf("Unexpected body:\n%s", bodies[0])
+	}
+
+	stats := bi.Stats()
+	if stats.NumFailed != 1 || stats.NumIndexed != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
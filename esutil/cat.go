@@ -0,0 +1,187 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+var (
+	catNodesColumns = []string{
+		"id", "name", "ip", "node.role", "master",
+		"heap.percent", "ram.percent", "cpu",
+		"load_1m", "load_5m", "load_15m", "disk.used_percent",
+	}
+	catShardsColumns = []string{
+		"index", "shard", "prirep", "state", "docs", "store", "ip", "node", "unassigned.reason",
+	}
+)
+
+// CatIndex represents a single entry of the Cat Indices API response.
+//
+// The sizes are expressed in bytes.
+//
+type CatIndex struct {
+	Health       string `json:"health"`
+	Status       string `json:"status"`
+	Index        string `json:"index"`
+	UUID         string `json:"uuid"`
+	Primaries    int    `json:"pri,string"`
+	Replicas     int    `json:"rep,string"`
+	DocsCount    int64  `json:"docs.count,string"`
+	DocsDeleted  int64  `json:"docs.deleted,string"`
+	StoreSize    int64  `json:"store.size,string"`
+	PriStoreSize int64  `json:"pri.store.size,string"`
+}
+
+// CatNode represents a single entry of the Cat Nodes API response.
+//
+type CatNode struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	IP              string  `json:"ip"`
+	Roles           string  `json:"node.role"`
+	Master          string  `json:"master"`
+	HeapPercent     int     `json:"heap.percent,string"`
+	RAMPercent      int     `json:"ram.percent,string"`
+	CPU             int     `json:"cpu,string"`
+	Load1m          float64 `json:"load_1m,string"`
+	Load5m          float64 `json:"load_5m,string"`
+	Load15m         float64 `json:"load_15m,string"`
+	DiskUsedPercent float64 `json:"disk.used_percent,string"`
+}
+
+// CatShard represents a single entry of the Cat Shards API response.
+//
+// The store size is expressed in bytes.
+//
+type CatShard struct {
+	Index            string `json:"index"`
+	Shard            int    `json:"shard,string"`
+	PriRep           string `json:"prirep"`
+	State            string `json:"state"`
+	Docs             int64  `json:"docs,string"`
+	Store            int64  `json:"store,string"`
+	IP               string `json:"ip"`
+	Node             string `json:"node"`
+	UnassignedReason string `json:"unassigned.reason"`
+}
+
+// CatAlias represents a single entry of the Cat Aliases API response.
+//
+type CatAlias struct {
+	Alias         string `json:"alias"`
+	Index         string `json:"index"`
+	Filter        string `json:"filter"`
+	RoutingIndex  string `json:"routing.index"`
+	RoutingSearch string `json:"routing.search"`
+	IsWriteIndex  string `json:"is_write_index"`
+}
+
+// ListIndices returns the indices matching pattern, as reported by the Cat Indices API.
+//
+// The pattern supports wildcards, eg. "logs-*"; when empty, all indices are returned.
+//
+func ListIndices(ctx context.Context, client *elasticsearch.Client, pattern string) ([]CatIndex, error) {
+	var indices []CatIndex
+
+	opts := []func(*esapi.CatIndicesRequest){
+		client.Cat.Indices.WithContext(ctx),
+		client.Cat.Indices.WithFormat("json"),
+		client.Cat.Indices.WithBytes("b"),
+	}
+	if pattern != "" {
+		opts = append(opts, client.Cat.Indices.WithIndex(pattern))
+	}
+
+	res, err := client.Cat.Indices(opts...)
+	if err := decodeResponse("list indices", res, err, &indices); err != nil {
+		return nil, err
+	}
+
+	return indices, nil
+}
+
+// ListNodes returns the nodes in the cluster, as reported by the Cat Nodes API.
+//
+func ListNodes(ctx context.Context, client *elasticsearch.Client) ([]CatNode, error) {
+	var nodes []CatNode
+
+	res, err := client.Cat.Nodes(
+		client.Cat.Nodes.WithContext(ctx),
+		client.Cat.Nodes.WithFormat("json"),
+		client.Cat.Nodes.WithFullID(true),
+		client.Cat.Nodes.WithH(catNodesColumns...),
+	)
+	if err := decodeResponse("list nodes", res, err, &nodes); err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// ListShards returns the shards of the indices matching pattern, as reported by the Cat Shards API.
+//
+// The pattern supports wildcards; when empty, shards for all indices are returned.
+//
+func ListShards(ctx context.Context, client *elasticsearch.Client, pattern string) ([]CatShard, error) {
+	var shards []CatShard
+
+	opts := []func(*esapi.CatShardsRequest){
+		client.Cat.Shards.WithContext(ctx),
+		client.Cat.Shards.WithFormat("json"),
+		client.Cat.Shards.WithBytes("b"),
+		client.Cat.Shards.WithH(catShardsColumns...),
+	}
+	if pattern != "" {
+		opts = append(opts, client.Cat.Shards.WithIndex(pattern))
+	}
+
+	res, err := client.Cat.Shards(opts...)
+	if err := decodeResponse("list shards", res, err, &shards); err != nil {
+		return nil, err
+	}
+
+	return shards, nil
+}
+
+// ListAliases returns the aliases matching pattern, as reported by the Cat Aliases API.
+//
+// The pattern supports wildcards; when empty, all aliases are returned.
+//
+func ListAliases(ctx context.Context, client *elasticsearch.Client, pattern string) ([]CatAlias, error) {
+	var aliases []CatAlias
+
+	opts := []func(*esapi.CatAliasesRequest){
+		client.Cat.Aliases.WithContext(ctx),
+		client.Cat.Aliases.WithFormat("json"),
+	}
+	if pattern != "" {
+		opts = append(opts, client.Cat.Aliases.WithName(pattern))
+	}
+
+	res, err := client.Cat.Aliases(opts...)
+	if err := decodeResponse("list aliases", res, err, &aliases); err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestListIndices(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var req *http.Request
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				req = r
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body: ioutil.NopCloser(strings.NewReader(`[
+						{"health":"green","status":"open","index":"logs-1","uuid":"abc","pri":"1","rep":"1","docs.count":"10","docs.deleted":"0","store.size":"2048","pri.store.size":"1024"},
+						{"health":null,"status":"close","index":"logs-2","uuid":"def","pri":"1","rep":"1","docs.count":null,"docs.deleted":null,"store.size":null,"pri.store.size":null}
+					]`)),
+				}, nil
+			},
+		}})
+
+		indices, err := ListIndices(context.Background(), es, "logs-*")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if req.URL.Path != "/_cat/indices/logs-*" {
+			t.Errorf("Unexpected path: %s", req.URL.Path)
+		}
+		if req.URL.Query().Get("format") != "json" || req.URL.Query().Get("bytes") != "b" {
+			t.Errorf("Unexpected query: %s", req.URL.RawQuery)
+		}
+
+		if len(indices) != 2 {
+			t.Fatalf("Unexpected number of indices: %d", len(indices))
+		}
+		if indices[0].Index != "logs-1" || indices[0].Health != "green" || indices[0].DocsCount != 10 || indices[0].StoreSize != 2048 {
+			t.Errorf("Unexpected index: %+v", indices[0])
+		}
+		if indices[1].Status != "close" || indices[1].DocsCount != 0 {
+			t.Errorf("Unexpected index: %+v", indices[1])
+		}
+	})
+
+	t.Run("Error response", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusNotFound,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"error":{"type":"index_not_found_exception"},"status":404}`)),
+				}, nil
+			},
+		}})
+
+		if _, err := ListIndices(context.Background(), es, "foo"); err == nil {
+			t.Fatal("Expected error, got nil")
+		}
+	})
+}
+
+func TestListCat(t *testing.T) {
+	newClient := func(req **http.Request, body string) *elasticsearch.Client {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				*req = r
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}})
+		return es
+	}
+
+	t.Run("Nodes", func(t *testing.T) {
+		var req *http.Request
+		es := newClient(&req, `[{"id":"abc123","name":"es-1","ip":"10.0.0.1","node.role":"dimr","master":"*","heap.percent":"42","ram.percent":"90","cpu":"3","load_1m":"0.50","load_5m":null,"load_15m":"1.25","disk.used_percent":"12.5"}]`)
+
+		nodes, err := ListNodes(context.Background(), es)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if req.URL.Path != "/_cat/nodes" || req.URL.Query().Get("format") != "json" {
+			t.Errorf("Unexpected request: %s", req.URL)
+		}
+		if len(nodes) != 1 {
+			t.Fatalf("Unexpected number of nodes: %d", len(nodes))
+		}
+		n := nodes[0]
+		if n.ID != "abc123" || n.Master != "*" || n.HeapPercent != 42 || n.Load1m != 0.5 || n.Load15m != 1.25 || n.DiskUsedPercent != 12.5 {
+			t.Errorf("Unexpected node: %+v", n)
+		}
+	})
+
+	t.Run("Shards", func(t *testing.T) {
+		var req *http.Request
+		es := newClient(&req, `[
+			{"index":"test","shard":"0","prirep":"p","state":"STARTED","docs":"100","store":"4096","ip":"10.0.0.1","node":"es-1","unassigned.reason":null},
+			{"index":"test","shard":"0","prirep":"r","state":"UNASSIGNED","docs":null,"store":null,"ip":null,"node":null,"unassigned.reason":"INDEX_CREATED"}
+		]`)
+
+		shards, err := ListShards(context.Background(), es, "test")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if req.URL.Path != "/_cat/shards/test" || req.URL.Query().Get("bytes") != "b" {
+			t.Errorf("Unexpected request: %s", req.URL)
+		}
+		if len(shards) != 2 {
+			t.Fatalf("Unexpected number of shards: %d", len(shards))
+		}
+		if shards[0].PriRep != "p" || shards[0].Docs != 100 || shards[0].Store != 4096 {
+			t.Errorf("Unexpected shard: %+v", shards[0])
+		}
+		if shards[1].State != "UNASSIGNED" || shards[1].UnassignedReason != "INDEX_CREATED" {
+			t.Errorf("Unexpected shard: %+v", shards[1])
+		}
+	})
+
+	t.Run("Aliases", func(t *testing.T) {
+		var req *http.Request
+		es := newClient(&req, `[{"alias":"logs","index":"logs-1","filter":"-","routing.index":"-","routing.search":"-","is_write_index":"true"}]`)
+
+		aliases, err := ListAliases(context.Background(), es, "logs")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if req.URL.Path != "/_cat/aliases/logs" {
+			t.Errorf("Unexpected request: %s", req.URL)
+		}
+		if len(aliases) != 1 || aliases[0].Index != "logs-1" || aliases[0].IsWriteIndex != "true" {
+			t.Errorf("Unexpected aliases: %+v", aliases)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+// ClusterStatsResponse represents the commonly used subset of the Cluster Stats API response.
+//
+// The sizes are expressed in bytes. The complete response is available in Raw,
+// to decode the other sections, eg. "nodes.os" or "indices.segments".
+//
+type ClusterStatsResponse struct {
+	ClusterName string `json:"cluster_name"`
+	ClusterUUID string `json:"cluster_uuid"`
+	Timestamp   int64  `json:"timestamp"` // Milliseconds since the epoch.
+	Status      string `json:"status"`    // One of "green", "yellow", "red".
+
+	Indices struct {
+		Count int `json:"count"`
+
+		Shards struct {
+			Total       int     `json:"total"`
+			Primaries   int     `json:"primaries"`
+			Replication float64 `json:"replication"`
+		} `json:"shards"`
+
+		Docs struct {
+			Count   int64 `json:"count"`
+			Deleted int64 `json:"deleted"`
+		} `json:"docs"`
+
+		Store struct {
+			SizeInBytes int64 `json:"size_in_bytes"`
+		} `json:"store"`
+	} `json:"indices"`
+
+	Nodes struct {
+		Count    map[string]int `json:"count"` // Keyed by "total", and by the role, eg. "data" or "master".
+		Versions []string       `json:"versions"`
+
+		JVM struct {
+			MaxUptimeInMillis int64 `json:"max_uptime_in_millis"`
+			Threads           int   `json:"threads"`
+
+			Mem struct {
+				HeapUsedInBytes int64 `json:"heap_used_in_bytes"`
+				HeapMaxInBytes  int64 `json:"heap_max_in_bytes"`
+			} `json:"mem"`
+		} `json:"jvm"`
+
+		FS struct {
+			TotalInBytes     int64 `json:"total_in_bytes"`
+			FreeInBytes      int64 `json:"free_in_bytes"`
+			AvailableInBytes int64 `json:"available_in_bytes"`
+		} `json:"fs"`
+	} `json:"nodes"`
+
+	Raw json.RawMessage `json:"-"` // The raw response body.
+}
+
+// HeapUsedPercent returns the percentage of the used JVM heap across all nodes, or 0 when unknown.
+//
+func (s *ClusterStatsResponse) HeapUsedPercent() float64 {
+	if s.Nodes.JVM.Mem.HeapMaxInBytes == 0 {
+		return 0
+	}
+	return float64(s.Nodes.JVM.Mem.HeapUsedInBytes) / float64(s.Nodes.JVM.Mem.HeapMaxInBytes) * 100
+}
+
+// ClusterStats returns the statistics of the cluster, as reported by the Cluster Stats API.
+//
+func ClusterStats(ctx context.Context, client *elasticsearch.Client) (*ClusterStatsResponse, error) {
+	var raw json.RawMessage
+
+	res, err := client.Cluster.Stats(client.Cluster.Stats.WithContext(ctx))
+	if err := decodeResponse("cluster stats", res, err, &raw); err != nil {
+		return nil, err
+	}
+
+	var stats ClusterStatsResponse
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		return nil, fmt.Errorf("cluster stats: error parsing response body: %s", err)
+	}
+	stats.Raw = raw
+
+	return &stats, nil
+}
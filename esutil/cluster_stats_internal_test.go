@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestClusterStats(t *testing.T) {
+	body := `{
+  "_nodes": {"total": 3, "successful": 3, "failed": 0},
+  "cluster_name": "production",
+  "cluster_uuid": "abc123",
+  "timestamp": 1620000000000,
+  "status": "yellow",
+  "indices": {
+    "count": 12,
+    "shards": {"total": 40, "primaries": 20, "replication": 1.0},
+    "docs": {"count": 1000000, "deleted": 12},
+    "store": {"size_in_bytes": 5368709120},
+    "segments": {"count": 140}
+  },
+  "nodes": {
+    "count": {"total": 3, "data": 2, "master": 3, "ingest": 1},
+    "versions": ["8.0.0"],
+    "jvm": {"max_uptime_in_millis": 3600000, "threads": 300, "mem": {"heap_used_in_bytes": 1073741824, "heap_max_in_bytes": 4294967296}},
+    "fs": {"total_in_bytes": 107374182400, "free_in_bytes": 53687091200, "available_in_bytes": 53687091200},
+    "os": {"available_processors": 8}
+  }
+}`
+
+	t.Run("Success", func(t *testing.T) {
+		var path string
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				path = r.URL.Path
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}})
+
+		stats, err := ClusterStats(context.Background(), es)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if path != "/_cluster/stats" {
+			t.Errorf("Unexpected path: %s", path)
+		}
+		if stats.ClusterName != "production" || stats.Status != "yellow" || stats.Timestamp != 1620000000000 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+		if stats.Indices.Count != 12 || stats.Indices.Shards.Total != 40 || stats.Indices.Shards.Primaries != 20 {
+			t.Errorf("Unexpected indices: %+v", stats.Indices)
+		}
+		if stats.Indices.Docs.Count != 1000000 || stats.Indices.Store.SizeInBytes != 5368709120 {
+			t.Errorf("Unexpected indices: %+v", stats.Indices)
+		}
+		if stats.Nodes.Count["total"] != 3 || stats.Nodes.Count["data"] != 2 {
+			t.Errorf("Unexpected nodes count: %v", stats.Nodes.Count)
+		}
+		if stats.Nodes.JVM.Mem.HeapMaxInBytes != 4294967296 || stats.HeapUsedPercent() != 25 {
+			t.Errorf("Unexpected JVM: %+v", stats.Nodes.JVM)
+		}
+		if stats.Nodes.FS.AvailableInBytes != 53687091200 {
+			t.Errorf("Unexpected FS: %+v", stats.Nodes.FS)
+		}
+
+		var os struct {
+			Nodes struct {
+				OS struct {
+					AvailableProcessors int `json:"available_processors"`
+				} `json:"os"`
+			} `json:"nodes"`
+		}
+		if err := json.Unmarshal(stats.Raw, &os); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if os.Nodes.OS.AvailableProcessors != 8 {
+			t.Errorf("Unexpected raw response: %s", stats.Raw)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"error":{"type":"master_not_discovered_exception","reason":"foo"},"status":503}`)),
+				}, nil
+			},
+		}})
+
+		if _, err := ClusterStats(context.Background(), es); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
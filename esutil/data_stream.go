@@ -0,0 +1,123 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+// RolloverConditions configures the conditions of the RolloverDataStream helper;
+// the data stream is rolled over when any of the set conditions is met.
+//
+// When no condition is set, the data stream is rolled over unconditionally.
+//
+type RolloverConditions struct {
+	MaxAge              time.Duration // The maximum age of the write index, since its creation.
+	MaxDocs             int64         // The maximum number of documents in the write index.
+	MaxSize             string        // The maximum size of the primary shards of the write index, eg. "50gb".
+	MaxPrimaryShardSize string        // The maximum size of the largest primary shard of the write index, eg. "50gb".
+	MaxPrimaryShardDocs int64         // The maximum number of documents in the largest primary shard of the write index.
+
+	DryRun bool // Only check the conditions, without rolling over the data stream.
+}
+
+// RolloverResult represents the response of the Rollover API.
+//
+type RolloverResult struct {
+	Acknowledged       bool `json:"acknowledged"`
+	ShardsAcknowledged bool `json:"shards_acknowledged"`
+
+	OldIndex   string `json:"old_index"`
+	NewIndex   string `json:"new_index"`
+	RolledOver bool   `json:"rolled_over"`
+	DryRun     bool   `json:"dry_run"`
+
+	Conditions map[string]bool `json:"conditions"` // Keyed by the condition, eg. "[max_docs: 1000]", with whether it was met.
+}
+
+// CreateDataStream creates the data stream, unless it already exists.
+//
+// The data stream requires a matching index template with the "data_stream" section.
+// It's safe to call concurrently: the "resource_already_exists_exception" error is ignored.
+//
+func CreateDataStream(ctx context.Context, client *elasticsearch.Client, name string) error {
+	res, err := client.Indices.CreateDataStream(name, client.Indices.CreateDataStream.WithContext(ctx))
+	if err := checkResponse("create data stream", res, err); err != nil {
+		if esErr, ok := err.(*ESError); ok && esErr.Type == "resource_already_exists_exception" {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// RolloverDataStream creates a new write index for the data stream, when any of the conditions is met.
+//
+// Check RolloverResult.RolledOver to find out whether the data stream has been rolled over.
+//
+func RolloverDataStream(ctx context.Context, client *elasticsearch.Client, name string, conditions RolloverConditions) (*RolloverResult, error) {
+	opts := []func(*esapi.IndicesRolloverRequest){client.Indices.Rollover.WithContext(ctx)}
+	if conditions.DryRun {
+		opts = append(opts, client.Indices.Rollover.WithDryRun(true))
+	}
+
+	cond := make(map[string]interface{})
+	if conditions.MaxAge > 0 {
+		cond["max_age"] = strconv.FormatInt(int64(conditions.MaxAge/time.Millisecond), 10) + "ms"
+	}
+	if conditions.MaxDocs > 0 {
+		cond["max_docs"] = conditions.MaxDocs
+	}
+	if conditions.MaxSize != "" {
+		cond["max_size"] = conditions.MaxSize
+	}
+	if conditions.MaxPrimaryShardSize != "" {
+		cond["max_primary_shard_size"] = conditions.MaxPrimaryShardSize
+	}
+	if conditions.MaxPrimaryShardDocs > 0 {
+		cond["max_primary_shard_docs"] = conditions.MaxPrimaryShardDocs
+	}
+	if len(cond) > 0 {
+		var body bytes.Buffer
+		if err := json.NewEncoder(&body).Encode(map[string]interface{}{"conditions": cond}); err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.Indices.Rollover.WithBody(&body))
+	}
+
+	var out RolloverResult
+	res, err := client.Indices.Rollover(name, opts...)
+	if err := decodeResponse("rollover data stream", res, err, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteDataStream deletes the data stream, with all of its backing indices.
+//
+func DeleteDataStream(ctx context.Context, client *elasticsearch.Client, name string) error {
+	res, err := client.Indices.DeleteDataStream([]string{name}, client.Indices.DeleteDataStream.WithContext(ctx))
+	return checkResponse("delete data stream", res, err)
+}
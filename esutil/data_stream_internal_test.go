@@ -0,0 +1,149 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestDataStream(t *testing.T) {
+	type request struct {
+		method, path, query, body string
+	}
+
+	newClient := func(status int, body string, req *request) *elasticsearch.Client {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				*req = request{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery}
+				if r.Body != nil {
+					b, _ := ioutil.ReadAll(r.Body)
+					req.body = strings.TrimSpace(string(b))
+				}
+				return &http.Response{
+					StatusCode: status,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}})
+		return es
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		var req request
+		es := newClient(200, `{"acknowledged":true}`, &req)
+
+		if err := CreateDataStream(context.Background(), es, "logs-app-default"); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if req.method != "PUT" || req.path != "/_data_stream/logs-app-default" {
+			t.Errorf("Unexpected request: %s %s", req.method, req.path)
+		}
+	})
+
+	t.Run("Create existing", func(t *testing.T) {
+		var req request
+		es := newClient(400, `{"error":{"type":"resource_already_exists_exception","reason":"data_stream [logs-app-default] already exists"},"status":400}`, &req)
+
+		if err := CreateDataStream(context.Background(), es, "logs-app-default"); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Create error", func(t *testing.T) {
+		var req request
+		es := newClient(400, `{"error":{"type":"illegal_argument_exception","reason":"no matching index template found for data stream [foo]"},"status":400}`, &req)
+
+		if err := CreateDataStream(context.Background(), es, "foo"); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("Rollover", func(t *testing.T) {
+		var req request
+		es := newClient(200, `{
+  "acknowledged": true,
+  "shards_acknowledged": true,
+  "old_index": ".ds-logs-app-default-2021.05.03-000001",
+  "new_index": ".ds-logs-app-default-2021.05.03-000002",
+  "rolled_over": true,
+  "dry_run": false,
+  "conditions": {"[max_age: 86400000ms]": false, "[max_docs: 1000]": true}
+}`, &req)
+
+		result, err := RolloverDataStream(context.Background(), es, "logs-app-default", RolloverConditions{
+			MaxAge:  24 * time.Hour,
+			MaxDocs: 1000,
+			MaxSize: "50gb",
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if req.method != "POST" || req.path != "/logs-app-default/_rollover" {
+			t.Errorf("Unexpected request: %s %s", req.method, req.path)
+		}
+		if req.body != `{"conditions":{"max_age":"86400000ms","max_docs":1000,"max_size":"50gb"}}` {
+			t.Errorf("Unexpected request body: %s", req.body)
+		}
+
+		if !result.RolledOver || result.NewIndex != ".ds-logs-app-default-2021.05.03-000002" {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+		if !result.Conditions["[max_docs: 1000]"] || result.Conditions["[max_age: 86400000ms]"] {
+			t.Errorf("Unexpected conditions: %v", result.Conditions)
+		}
+	})
+
+	t.Run("Rollover unconditional dry run", func(t *testing.T) {
+		var req request
+		es := newClient(200, `{"rolled_over":false,"dry_run":true}`, &req)
+
+		result, err := RolloverDataStream(context.Background(), es, "logs-app-default", RolloverConditions{DryRun: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if req.body != "" || req.query != "dry_run=true" {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+		if result.RolledOver || !result.DryRun {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		var req request
+		es := newClient(200, `{"acknowledged":true}`, &req)
+
+		if err := DeleteDataStream(context.Background(), es, "logs-app-default"); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if req.method != "DELETE" || req.path != "/_data_stream/logs-app-default" {
+			t.Errorf("Unexpected request: %s %s", req.method, req.path)
+		}
+	})
+}
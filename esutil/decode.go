@@ -0,0 +1,381 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+// ESError represents an error response returned by Elasticsearch.
+//
+type ESError struct {
+	StatusCode int
+	Type       string
+	Reason     string
+
+	CausedBy   *ESError   // The underlying cause, from the "caused_by" field, when reported
+	RootCause  []*ESError // The root causes, from the "root_cause" field, when reported
+	Suppressed []*ESError // The suppressed errors, from the "suppressed" field, when reported
+
+	Body []byte // The raw response body
+}
+
+// Error returns the error as a string.
+//
+func (e *ESError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("[%d] %s", e.StatusCode, e.Reason)
+	}
+	return fmt.Sprintf("[%d] %s: %s", e.StatusCode, e.Type, e.Reason)
+}
+
+// Unwrap returns the underlying cause of the error, or nil, so the chain of the "caused_by"
+// errors can be traversed, eg. with errors.As.
+//
+func (e *ESError) Unwrap() error {
+	if e.CausedBy == nil {
+		return nil
+	}
+	return e.CausedBy
+}
+
+// VersionConflictError is returned for the version conflicts, eg. when the document has been
+// modified since it was read, with the optimistic concurrency control, or when it already
+// exists, with the "create" operation type.
+//
+type VersionConflictError struct{ *ESError }
+
+// MappingError is returned when the document doesn't match the index mapping,
+// eg. when a field value cannot be parsed, or the mapping forbids new fields.
+//
+type MappingError struct{ *ESError }
+
+// MappingLimitError is returned when a document would exceed a limit of the index mapping,
+// eg. the total number of fields, configured with "index.mapping.total_fields.limit".
+// The caller can react eg. by mapping the dynamic fields as "flattened".
+//
+type MappingLimitError struct {
+	*ESError
+
+	Setting string // The exceeded limit setting, eg. "index.mapping.total_fields.limit"
+	Limit   int    // The value of the limit
+	Fields  int    // The number of the new fields, when reported; 0 otherwise
+}
+
+// ClusterBlockError is returned when the request is rejected by a cluster or index block,
+// eg. the read-only block applied to the indices when the disk usage exceeds the flood-stage watermark.
+// The caller can react eg. by pausing the ingestion until the block is removed, instead of retrying.
+//
+type ClusterBlockError struct {
+	*ESError
+
+	Index  string         // The name of the blocked index, when reported
+	Blocks []ClusterBlock // The blocks reported in the error reason
+}
+
+// ClusterBlock represents a single block reported in the cluster block error.
+//
+type ClusterBlock struct {
+	Status      string // The status of the block, eg. "FORBIDDEN" or "TOO_MANY_REQUESTS"
+	ID          int    // The ID of the block, eg. 12 for "read_only_allow_delete"
+	Description string // The description of the block, eg. "index read-only / allow delete (api)"
+}
+
+// IsRetryable returns true when all the blocks are expected to be removed without an intervention,
+// eg. the flood-stage watermark block, which is released automatically once the disk usage drops.
+//
+func (e *ClusterBlockError) IsRetryable() bool {
+	if len(e.Blocks) == 0 {
+		return false
+	}
+	for _, b := range e.Blocks {
+		switch b.Status {
+		case "TOO_MANY_REQUESTS", "SERVICE_UNAVAILABLE":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Decode decodes the response body into out, and closes the body.
+//
+// When the response status indicates failure, it returns an *ESError
+// with the information from the error envelope, and out is left intact.
+// The errors with a known cause are returned as a more specific type,
+// which embeds the *ESError: *VersionConflictError, *MappingError, *MappingLimitError
+// or *ClusterBlockError. The nested causes of the error are available in the CausedBy,
+// RootCause and Suppressed fields, and the chain of causes is returned by Unwrap.
+//
+// When out is nil, the response body is discarded.
+//
+func Decode(res *esapi.Response, out interface{}) error {
+	if res == nil || res.Body == nil {
+		return fmt.Errorf("decode: empty response")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return classifyError(newESError(res))
+	}
+
+	if out == nil {
+		_, err := io.Copy(ioutil.Discard, res.Body)
+		return err
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode: error parsing response body: %s", err)
+	}
+
+	return nil
+}
+
+// DecodeWithBuffer decodes the response body into out, like Decode, reading the body into buf first.
+//
+// It avoids allocating a read buffer for every call in hot paths, since buf can be reused for
+// multiple calls; the buffer is reset before reading. When buf is nil, it behaves like Decode.
+//
+// The buffer holds the raw response body only until the next call: the decoded values
+// don't reference its memory, but the caller must not retain buf.Bytes() after the call,
+// nor use the same buffer for concurrent calls.
+//
+func DecodeWithBuffer(res *esapi.Response, out interface{}, buf *bytes.Buffer) error {
+	if buf == nil {
+		return Decode(res, out)
+	}
+	if res == nil || res.Body == nil {
+		return fmt.Errorf("decode: empty response")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return classifyError(newESError(res))
+	}
+
+	if out == nil {
+		_, err := io.Copy(ioutil.Discard, res.Body)
+		return err
+	}
+
+	buf.Reset()
+	if _, err := buf.ReadFrom(res.Body); err != nil {
+		return fmt.Errorf("decode: cannot read response body: %s", err)
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), out); err != nil {
+		return fmt.Errorf("decode: error parsing response body: %s", err)
+	}
+
+	return nil
+}
+
+// newESError creates an error from the response body; the calling code is responsible for closing the body.
+//
+func newESError(res *esapi.Response) *ESError {
+	var (
+		buf bytes.Buffer
+		env struct {
+			Error json.RawMessage `json:"error"`
+		}
+	)
+
+	e := ESError{StatusCode: res.StatusCode, Reason: http.StatusText(res.StatusCode)}
+
+	if res.Body == nil {
+		return &e
+	}
+
+	if _, err := buf.ReadFrom(res.Body); err != nil {
+		return &e
+	}
+	e.Body = buf.Bytes()
+
+	if err := json.Unmarshal(e.Body, &env); err != nil || len(env.Error) == 0 {
+		return &e
+	}
+
+	// The error can be either an object or a plain string
+	var obj errorCause
+	if err := json.Unmarshal(env.Error, &obj); err == nil {
+		e.Type = obj.Type
+		if obj.Reason != "" {
+			e.Reason = obj.Reason
+		}
+		e.CausedBy = obj.CausedBy.toESError(e.StatusCode)
+		e.RootCause = causesToESErrors(obj.RootCause, e.StatusCode)
+		e.Suppressed = causesToESErrors(obj.Suppressed, e.StatusCode)
+		return &e
+	}
+
+	var str string
+	if err := json.Unmarshal(env.Error, &str); err == nil && str != "" {
+		e.Reason = str
+	}
+
+	return &e
+}
+
+// errorCause represents an error object in the error envelope, with its nested causes.
+//
+type errorCause struct {
+	Type       string        `json:"type"`
+	Reason     string        `json:"reason"`
+	CausedBy   *errorCause   `json:"caused_by"`
+	RootCause  []*errorCause `json:"root_cause"`
+	Suppressed []*errorCause `json:"suppressed"`
+}
+
+// toESError converts the cause, recursively, to an *ESError with the response status code, or returns nil.
+//
+func (c *errorCause) toESError(statusCode int) *ESError {
+	if c == nil {
+		return nil
+	}
+	return &ESError{
+		StatusCode: statusCode,
+		Type:       c.Type,
+		Reason:     c.Reason,
+		CausedBy:   c.CausedBy.toESError(statusCode),
+		RootCause:  causesToESErrors(c.RootCause, statusCode),
+		Suppressed: causesToESErrors(c.Suppressed, statusCode),
+	}
+}
+
+// causesToESErrors converts the list of causes to a list of *ESError, or returns nil when empty.
+//
+func causesToESErrors(causes []*errorCause, statusCode int) []*ESError {
+	var out []*ESError
+	for _, c := range causes {
+		if e := c.toESError(statusCode); e != nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// classifyError returns the error as a more specific type, when the cause is known.
+//
+func classifyError(e *ESError) error {
+	if err := parseMappingLimitError(e); err != nil {
+		return err
+	}
+
+	switch e.Type {
+	case "version_conflict_engine_exception":
+		return &VersionConflictError{e}
+	case "mapper_parsing_exception", "document_parsing_exception", "strict_dynamic_mapping_exception":
+		return &MappingError{e}
+	case "cluster_block_exception":
+		return parseClusterBlockError(e)
+	}
+	return e
+}
+
+var (
+	clusterBlockIndexRe = regexp.MustCompile(`^index \[([^\]]*)\] blocked by:`)
+	clusterBlockRe      = regexp.MustCompile(`\[([A-Z_]+)/(\d+)/([^\]]*)\]`)
+)
+
+// parseClusterBlockError returns a *ClusterBlockError with the blocks parsed from the error reason,
+// eg. "index [test] blocked by: [FORBIDDEN/12/index read-only / allow delete (api)];".
+//
+func parseClusterBlockError(e *ESError) error {
+	err := ClusterBlockError{ESError: e}
+
+	if m := clusterBlockIndexRe.FindStringSubmatch(e.Reason); m != nil {
+		err.Index = m[1]
+	}
+
+	for _, m := range clusterBlockRe.FindAllStringSubmatch(e.Reason, -1) {
+		id, _ := strconv.Atoi(m[2])
+		err.Blocks = append(err.Blocks, ClusterBlock{Status: m[1], ID: id, Description: m[3]})
+	}
+
+	return &err
+}
+
+// mappingLimitReasons maps the reasons of the mapping limit errors to the limit settings.
+//
+var mappingLimitReasons = []struct {
+	re      *regexp.Regexp
+	setting string
+}{
+	{regexp.MustCompile(`Limit of total fields \[(\d+)\] (?:in index \[[^\]]*\] )?has been exceeded(?: while adding new fields \[(\d+)\])?`), "index.mapping.total_fields.limit"},
+	{regexp.MustCompile(`Limit of mapping depth \[(\d+)\] (?:in index \[[^\]]*\] )?has been exceeded`), "index.mapping.depth.limit"},
+	{regexp.MustCompile(`Limit of nested fields \[(\d+)\] (?:in index \[[^\]]*\] )?has been exceeded`), "index.mapping.nested_fields.limit"},
+	{regexp.MustCompile(`The number of nested documents has exceeded the allowed limit of \[(\d+)\]`), "index.mapping.nested_objects.limit"},
+	{regexp.MustCompile(`Field \[[^\]]*\] is defined more than \[(\d+)\] characters`), "index.mapping.field_name_length.limit"},
+}
+
+// parseMappingLimitError returns a *MappingLimitError when the error reason reports
+// an exceeded mapping limit, or nil.
+//
+func parseMappingLimitError(e *ESError) error {
+	switch e.Type {
+	case "illegal_argument_exception", "mapper_parsing_exception", "document_parsing_exception":
+	default:
+		return nil
+	}
+
+	for _, r := range mappingLimitReasons {
+		m := r.re.FindStringSubmatch(e.Reason)
+		if m == nil {
+			continue
+		}
+		err := MappingLimitError{ESError: e, Setting: r.setting}
+		err.Limit, _ = strconv.Atoi(m[1])
+		if len(m) > 2 && m[2] != "" {
+			err.Fields, _ = strconv.Atoi(m[2])
+		}
+		return &err
+	}
+
+	return nil
+}
+
+// decodeResponse checks the API response for errors and decodes its JSON body into v.
+//
+func decodeResponse(op string, res *esapi.Response, err error, v interface{}) error {
+	return decodeResponseWithBuffer(op, res, err, v, nil)
+}
+
+// decodeResponseWithBuffer checks the API response for errors and decodes its JSON body into v,
+// reading the body into buf, when not nil.
+//
+func decodeResponseWithBuffer(op string, res *esapi.Response, err error, v interface{}, buf *bytes.Buffer) error {
+	if err != nil {
+		return fmt.Errorf("%s: %s", op, err)
+	}
+	return DecodeWithBuffer(res, v, buf)
+}
+
+// checkResponse checks the API response for errors and closes its body.
+//
+func checkResponse(op string, res *esapi.Response, err error) error {
+	return decodeResponse(op, res, err, nil)
+}
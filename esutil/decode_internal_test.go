@@ -0,0 +1,337 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error { r.closed = true; return nil }
+
+func TestDecode(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(`{"foo":"bar"}`)}
+		res := &esapi.Response{StatusCode: 200, Body: body}
+
+		var out struct {
+			Foo string `json:"foo"`
+		}
+		if err := Decode(res, &out); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if out.Foo != "bar" {
+			t.Errorf("Unexpected output: %+v", out)
+		}
+		if !body.closed {
+			t.Errorf("Expected the body to be closed")
+		}
+	})
+
+	t.Run("Nil output", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(`{"acknowledged":true}`)}
+		if err := Decode(&esapi.Response{StatusCode: 200, Body: body}, nil); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !body.closed || body.Len() != 0 {
+			t.Errorf("Expected the body to be drained and closed")
+		}
+	})
+
+	t.Run("Error object", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(`{"error":{"root_cause":[],"type":"index_not_found_exception","reason":"no such index [foo]"},"status":404}`)}
+		res := &esapi.Response{StatusCode: 404, Body: body}
+
+		var out map[string]interface{}
+		err := Decode(res, &out)
+
+		esErr, ok := err.(*ESError)
+		if !ok {
+			t.Fatalf("Expected *ESError, got: %T", err)
+		}
+		if esErr.StatusCode != 404 || esErr.Type != "index_not_found_exception" || esErr.Reason != "no such index [foo]" {
+			t.Errorf("Unexpected error: %+v", esErr)
+		}
+		if esErr.Error() != "[404] index_not_found_exception: no such index [foo]" {
+			t.Errorf("Unexpected error message: %s", esErr)
+		}
+		if out != nil {
+			t.Errorf("Unexpected output: %v", out)
+		}
+		if !body.closed {
+			t.Errorf("Expected the body to be closed")
+		}
+	})
+
+	t.Run("Error string", func(t *testing.T) {
+		res := &esapi.Response{StatusCode: 404, Body: ioutil.NopCloser(strings.NewReader(`{"error":"alias [foo] missing","status":404}`))}
+
+		err := Decode(res, nil)
+		if esErr, ok := err.(*ESError); !ok || esErr.Reason != "alias [foo] missing" || esErr.Type != "" {
+			t.Errorf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Error without body", func(t *testing.T) {
+		res := &esapi.Response{StatusCode: 503, Body: ioutil.NopCloser(strings.NewReader(``))}
+
+		err := Decode(res, nil)
+		if esErr, ok := err.(*ESError); !ok || esErr.Reason != "Service Unavailable" {
+			t.Errorf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Error with nested causes", func(t *testing.T) {
+		body := `{
+  "error": {
+    "root_cause": [
+      {"type": "query_shard_exception", "reason": "failed to create query: For input string: \"abc\"", "index": "test"}
+    ],
+    "type": "search_phase_execution_exception",
+    "reason": "all shards failed",
+    "phase": "query",
+    "failed_shards": [
+      {"shard": 0, "index": "test", "reason": {"type": "query_shard_exception", "reason": "failed to create query"}}
+    ],
+    "caused_by": {
+      "type": "query_shard_exception",
+      "reason": "failed to create query: For input string: \"abc\"",
+      "caused_by": {
+        "type": "number_format_exception",
+        "reason": "For input string: \"abc\"",
+        "suppressed": [
+          {"type": "illegal_state_exception", "reason": "suppressed failure"}
+        ]
+      }
+    }
+  },
+  "status": 400
+}`
+		res := &esapi.Response{StatusCode: 400, Body: ioutil.NopCloser(strings.NewReader(body))}
+
+		err := Decode(res, nil)
+		esErr, ok := err.(*ESError)
+		if !ok {
+			t.Fatalf("Expected *ESError, got: %T", err)
+		}
+		if esErr.Type != "search_phase_execution_exception" || esErr.Reason != "all shards failed" {
+			t.Errorf("Unexpected error: %s", esErr)
+		}
+
+		if len(esErr.RootCause) != 1 || esErr.RootCause[0].Type != "query_shard_exception" {
+			t.Errorf("Unexpected root cause: %+v", esErr.RootCause)
+		}
+
+		var types []string
+		for e := error(esErr); e != nil; e = e.(interface{ Unwrap() error }).Unwrap() {
+			types = append(types, e.(*ESError).Type)
+		}
+		expected := []string{"search_phase_execution_exception", "query_shard_exception", "number_format_exception"}
+		if !reflect.DeepEqual(types, expected) {
+			t.Errorf("Unexpected causes, want=%v, got=%v", expected, types)
+		}
+
+		cause := esErr.CausedBy.CausedBy
+		if cause.StatusCode != 400 || cause.Reason != `For input string: "abc"` {
+			t.Errorf("Unexpected cause: %s", cause)
+		}
+		if len(cause.Suppressed) != 1 || cause.Suppressed[0].Type != "illegal_state_exception" {
+			t.Errorf("Unexpected suppressed errors: %+v", cause.Suppressed)
+		}
+	})
+
+	t.Run("Error without causes", func(t *testing.T) {
+		res := &esapi.Response{StatusCode: 404, Body: ioutil.NopCloser(strings.NewReader(`{"error":{"type":"index_not_found_exception","reason":"no such index [foo]"}}`))}
+
+		esErr, ok := Decode(res, nil).(*ESError)
+		if !ok {
+			t.Fatalf("Expected *ESError")
+		}
+		if esErr.Unwrap() != nil || esErr.RootCause != nil || esErr.Suppressed != nil {
+			t.Errorf("Unexpected causes: %+v", esErr)
+		}
+	})
+
+	t.Run("With buffer", func(t *testing.T) {
+		buf := bytes.NewBufferString("GARBAGE")
+
+		for _, s := range []string{`{"foo":"bar"}`, `{"foo":"baz"}`} {
+			body := &closeRecorder{Reader: strings.NewReader(s)}
+
+			var out struct {
+				Foo string `json:"foo"`
+			}
+			if err := DecodeWithBuffer(&esapi.Response{StatusCode: 200, Body: body}, &out, buf); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if out.Foo != s[8:11] {
+				t.Errorf("Unexpected output: %+v", out)
+			}
+			if buf.String() != s {
+				t.Errorf("Unexpected buffer contents: %s", buf)
+			}
+			if !body.closed {
+				t.Errorf("Expected the body to be closed")
+			}
+		}
+	})
+
+	t.Run("With buffer error", func(t *testing.T) {
+		res := &esapi.Response{StatusCode: 404, Body: ioutil.NopCloser(strings.NewReader(`{"error":"alias [foo] missing","status":404}`))}
+
+		var buf bytes.Buffer
+		err := DecodeWithBuffer(res, &struct{}{}, &buf)
+		if esErr, ok := err.(*ESError); !ok || esErr.Reason != "alias [foo] missing" {
+			t.Errorf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Classified errors", func(t *testing.T) {
+		for _, tt := range []struct {
+			body     string
+			expected string
+		}{
+			{`{"error":{"type":"version_conflict_engine_exception","reason":"conflict"}}`, "*esutil.VersionConflictError"},
+			{`{"error":{"type":"strict_dynamic_mapping_exception","reason":"mapping"}}`, "*esutil.MappingError"},
+			{`{"error":{"type":"index_not_found_exception","reason":"missing"}}`, "*esutil.ESError"},
+		} {
+			err := Decode(&esapi.Response{StatusCode: 400, Body: ioutil.NopCloser(strings.NewReader(tt.body))}, nil)
+			if fmt.Sprintf("%T", err) != tt.expected {
+				t.Errorf("Unexpected error type, want=%s, got=%T", tt.expected, err)
+			}
+		}
+	})
+
+	t.Run("Mapping limit errors", func(t *testing.T) {
+		for _, tt := range []struct {
+			body    string
+			setting string
+			limit   int
+			fields  int
+		}{
+			{
+				`{"error":{"type":"illegal_argument_exception","reason":"Limit of total fields [1000] has been exceeded"}}`,
+				"index.mapping.total_fields.limit", 1000, 0,
+			},
+			{
+				`{"error":{"type":"illegal_argument_exception","reason":"Limit of total fields [1000] has been exceeded while adding new fields [3]"}}`,
+				"index.mapping.total_fields.limit", 1000, 3,
+			},
+			{
+				`{"error":{"type":"document_parsing_exception","reason":"Limit of total fields [50] in index [test] has been exceeded while adding new fields [2]"}}`,
+				"index.mapping.total_fields.limit", 50, 2,
+			},
+			{
+				`{"error":{"type":"illegal_argument_exception","reason":"Limit of mapping depth [20] has been exceeded due to object field [a.b]"}}`,
+				"index.mapping.depth.limit", 20, 0,
+			},
+			{
+				`{"error":{"type":"illegal_argument_exception","reason":"Limit of nested fields [50] has been exceeded"}}`,
+				"index.mapping.nested_fields.limit", 50, 0,
+			},
+		} {
+			err := Decode(&esapi.Response{StatusCode: 400, Body: ioutil.NopCloser(strings.NewReader(tt.body))}, nil)
+			limitErr, ok := err.(*MappingLimitError)
+			if !ok {
+				t.Errorf("Unexpected error type: %T", err)
+				continue
+			}
+			if limitErr.Setting != tt.setting || limitErr.Limit != tt.limit || limitErr.Fields != tt.fields {
+				t.Errorf("Unexpected error: %+v", limitErr)
+			}
+			if !strings.Contains(limitErr.Error(), "Limit of") {
+				t.Errorf("Unexpected error message: %s", limitErr)
+			}
+		}
+
+		err := Decode(&esapi.Response{StatusCode: 400, Body: ioutil.NopCloser(strings.NewReader(`{"error":{"type":"illegal_argument_exception","reason":"foo"}}`))}, nil)
+		if _, ok := err.(*ESError); !ok {
+			t.Errorf("Unexpected error type: %T", err)
+		}
+	})
+
+	t.Run("Cluster block errors", func(t *testing.T) {
+		for _, tt := range []struct {
+			body      string
+			index     string
+			blocks    []ClusterBlock
+			retryable bool
+		}{
+			{
+				body:      `{"error":{"type":"cluster_block_exception","reason":"index [test] blocked by: [TOO_MANY_REQUESTS/12/disk usage exceeded flood-stage watermark, index has read-only-allow-delete block];"},"status":429}`,
+				index:     "test",
+				blocks:    []ClusterBlock{{Status: "TOO_MANY_REQUESTS", ID: 12, Description: "disk usage exceeded flood-stage watermark, index has read-only-allow-delete block"}},
+				retryable: true,
+			},
+			{
+				body:   `{"error":{"type":"cluster_block_exception","reason":"index [test] blocked by: [FORBIDDEN/5/index read-only (api)];[FORBIDDEN/8/index write (api)];"},"status":403}`,
+				index:  "test",
+				blocks: []ClusterBlock{{Status: "FORBIDDEN", ID: 5, Description: "index read-only (api)"}, {Status: "FORBIDDEN", ID: 8, Description: "index write (api)"}},
+			},
+			{
+				body:      `{"error":{"type":"cluster_block_exception","reason":"blocked by: [SERVICE_UNAVAILABLE/1/state not recovered / initialized];"},"status":503}`,
+				blocks:    []ClusterBlock{{Status: "SERVICE_UNAVAILABLE", ID: 1, Description: "state not recovered / initialized"}},
+				retryable: true,
+			},
+			{
+				body: `{"error":{"type":"cluster_block_exception","reason":"foo"},"status":403}`,
+			},
+		} {
+			err := Decode(&esapi.Response{StatusCode: 403, Body: ioutil.NopCloser(strings.NewReader(tt.body))}, nil)
+			blockErr, ok := err.(*ClusterBlockError)
+			if !ok {
+				t.Errorf("Unexpected error type: %T", err)
+				continue
+			}
+			if blockErr.Index != tt.index {
+				t.Errorf("Unexpected index: %q", blockErr.Index)
+			}
+			if !reflect.DeepEqual(blockErr.Blocks, tt.blocks) {
+				t.Errorf("Unexpected blocks: %+v", blockErr.Blocks)
+			}
+			if blockErr.IsRetryable() != tt.retryable {
+				t.Errorf("Unexpected retryable: %v", blockErr.IsRetryable())
+			}
+			if blockErr.Type != "cluster_block_exception" {
+				t.Errorf("Unexpected error type: %s", blockErr.Type)
+			}
+		}
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		res := &esapi.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(`{`))}
+
+		var out map[string]interface{}
+		if err := Decode(res, &out); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
@@ -0,0 +1,73 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+// DeleteByQueryOptions configures the DeleteByQuery helper.
+//
+type DeleteByQueryOptions struct {
+	Conflicts         string        // Set to "proceed" to continue deleting on version conflicts. Default: "abort".
+	RequestsPerSecond int           // Throttle for the operation, in sub-requests per second. Default: unthrottled.
+	Slices            interface{}   // The number of slices, or "auto". Default: 1.
+	Refresh           bool          // Refresh the affected indices when the operation completes.
+	PollInterval      time.Duration // How often the task status is polled. Default: 1s.
+}
+
+// DeleteByQuery submits a delete by query operation for the documents in index matching the query,
+// and waits for its completion by polling the corresponding task.
+//
+// The returned status contains the number of deleted documents, the number of version conflicts,
+// and the throttling information. When the context is done before the operation completes,
+// the task is cancelled on the cluster.
+//
+func DeleteByQuery(ctx context.Context, client *elasticsearch.Client, index string, query io.Reader, opts DeleteByQueryOptions) (TaskStatus, error) {
+	reqOpts := []func(*esapi.DeleteByQueryRequest){
+		client.DeleteByQuery.WithContext(ctx),
+		client.DeleteByQuery.WithWaitForCompletion(false),
+	}
+	if opts.Conflicts != "" {
+		reqOpts = append(reqOpts, client.DeleteByQuery.WithConflicts(opts.Conflicts))
+	}
+	if opts.RequestsPerSecond > 0 {
+		reqOpts = append(reqOpts, client.DeleteByQuery.WithRequestsPerSecond(opts.RequestsPerSecond))
+	}
+	if opts.Slices != nil {
+		reqOpts = append(reqOpts, client.DeleteByQuery.WithSlices(opts.Slices))
+	}
+	if opts.Refresh {
+		reqOpts = append(reqOpts, client.DeleteByQuery.WithRefresh(true))
+	}
+
+	var r struct {
+		Task string `json:"task"`
+	}
+	res, err := client.DeleteByQuery([]string{index}, query, reqOpts...)
+	if err := decodeResponse("delete by query", res, err, &r); err != nil {
+		return TaskStatus{}, err
+	}
+
+	return newTask(client, r.Task, opts.PollInterval).Wait(ctx)
+}
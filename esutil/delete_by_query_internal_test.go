@@ -0,0 +1,119 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestDeleteByQuery(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var (
+			query   string
+			numGets int
+		)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				res := &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}
+
+				switch r.URL.Path {
+				case "/test/_delete_by_query":
+					query = r.URL.RawQuery
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"task":"node1:7"}`))
+				case "/_tasks/node1:7":
+					numGets++
+					if numGets < 2 {
+						res.Body = ioutil.NopCloser(strings.NewReader(`{"completed":false,"task":{"status":{"total":10,"deleted":4}}}`))
+					} else {
+						res.Body = ioutil.NopCloser(strings.NewReader(`{"completed":true,"response":{"total":10,"deleted":8,"version_conflicts":2,"throttled_millis":15,"requests_per_second":100.0}}`))
+					}
+				default:
+					t.Fatalf("Unexpected request: %s %s", r.Method, r.URL)
+				}
+				return res, nil
+			},
+		}})
+
+		status, err := DeleteByQuery(
+			context.Background(), es, "test",
+			strings.NewReader(`{"query":{"match_all":{}}}`),
+			DeleteByQueryOptions{Conflicts: "proceed", PollInterval: time.Millisecond},
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if !strings.Contains(query, "conflicts=proceed") || !strings.Contains(query, "wait_for_completion=false") {
+			t.Errorf("Unexpected query: %s", query)
+		}
+		if !status.Completed || status.Deleted != 8 || status.VersionConflicts != 2 {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+		if status.ThrottledMillis != 15 || status.RequestsPerSecond != 100 {
+			t.Errorf("Unexpected throttling info: %+v", status)
+		}
+	})
+
+	t.Run("Task not found after completion", func(t *testing.T) {
+		var numGets int
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				res := &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}
+
+				if r.URL.Path == "/test/_delete_by_query" {
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"task":"node1:7"}`))
+					return res, nil
+				}
+
+				numGets++
+				if numGets < 2 {
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"completed":false,"task":{"status":{"total":10,"deleted":10}}}`))
+				} else {
+					res.StatusCode = http.StatusNotFound
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"error":{"type":"resource_not_found_exception"},"status":404}`))
+				}
+				return res, nil
+			},
+		}})
+
+		status, err := DeleteByQuery(context.Background(), es, "test", strings.NewReader(`{}`), DeleteByQueryOptions{PollInterval: time.Millisecond})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !status.Completed || status.Deleted != 10 {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+	})
+}
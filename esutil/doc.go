@@ -18,5 +18,8 @@
 /*
 Package esutil provides helper utilities to the Go client for Elasticsearch.
 
+The helpers which decode or encode documents of the caller's type, such as GetDocument or Mget,
+accept interface{} instead of a type parameter, since the module supports Go versions without generics.
+
 */
 package esutil
@@ -0,0 +1,104 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+// Document represents a document returned by the GetDocument helper, with its metadata.
+//
+// Use SeqNo and PrimaryTerm for optimistic concurrency control, with the "if_seq_no"
+// and "if_primary_term" parameters of the Index, Update and Delete APIs.
+//
+type Document struct {
+	Index       string          `json:"_index"`
+	ID          string          `json:"_id"`
+	Version     int64           `json:"_version"`
+	SeqNo       int64           `json:"_seq_no"`
+	PrimaryTerm int64           `json:"_primary_term"`
+	Found       bool            `json:"found"`
+	Source      json.RawMessage `json:"_source,omitempty"`
+}
+
+// GetDocumentOptions configures the GetDocument helper.
+//
+type GetDocumentOptions struct {
+	// Return only the fields matching the filter_path expressions, eg. "_source.title", to reduce
+	// the size of the response; use FilterPathFor to derive the paths from a struct. The metadata fields
+	// and the error envelope are always included. Default: nil, the full response is returned.
+	FilterPath []string
+}
+
+// GetDocument retrieves the document by ID, and decodes its source into the value pointed to by source,
+// unless it's nil.
+//
+// When the document doesn't exist, it returns a document with Found set to false, and no error;
+// when the index doesn't exist, it returns an *ESError.
+//
+func GetDocument(ctx context.Context, client *elasticsearch.Client, index, id string, source interface{}, opts GetDocumentOptions) (*Document, error) {
+	var (
+		doc Document
+		env struct {
+			Error json.RawMessage `json:"error"`
+		}
+	)
+
+	getOpts := []func(*esapi.GetRequest){client.Get.WithContext(ctx)}
+	if paths := withRequiredPaths(opts.FilterPath, requiredDocumentPaths, requiredErrorPaths); paths != nil {
+		getOpts = append(getOpts, client.Get.WithFilterPath(paths...))
+	}
+
+	res, err := client.Get(index, id, getOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("get document: %s", err)
+	}
+
+	// A missing document is reported with 404, like a missing index; tell them apart by the error envelope
+	if res.StatusCode == http.StatusNotFound {
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("get document: cannot read response body: %s", err)
+		}
+		if err := json.Unmarshal(body, &env); err == nil && len(env.Error) == 0 {
+			return &Document{Index: index, ID: id}, nil
+		}
+		res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if err := Decode(res, &doc); err != nil {
+		return nil, err
+	}
+
+	if source != nil && doc.Found && len(doc.Source) > 0 {
+		if err := json.Unmarshal(doc.Source, source); err != nil {
+			return nil, fmt.Errorf("get document: cannot decode source: %s", err)
+		}
+	}
+
+	return &doc, nil
+}
@@ -0,0 +1,127 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestGetDocument(t *testing.T) {
+	newClient := func(status int, body string, path *string) *elasticsearch.Client {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				if path != nil {
+					*path = r.URL.EscapedPath()
+				}
+				return &http.Response{
+					StatusCode: status,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}})
+		return es
+	}
+
+	t.Run("Found", func(t *testing.T) {
+		var (
+			path   string
+			source struct {
+				Title string `json:"title"`
+			}
+		)
+
+		es := newClient(200, `{"_index":"test","_id":"a/1","_version":3,"_seq_no":10,"_primary_term":2,"found":true,"_source":{"title":"foo"}}`, &path)
+
+		doc, err := GetDocument(context.Background(), es, "test", "a/1", &source, GetDocumentOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if path != "/test/_doc/a%2F1" {
+			t.Errorf("Unexpected path: %s", path)
+		}
+		if !doc.Found || doc.ID != "a/1" || doc.Version != 3 || doc.SeqNo != 10 || doc.PrimaryTerm != 2 {
+			t.Errorf("Unexpected document: %+v", doc)
+		}
+		if source.Title != "foo" || string(doc.Source) != `{"title":"foo"}` {
+			t.Errorf("Unexpected source: %+v", source)
+		}
+	})
+
+	t.Run("Filter path", func(t *testing.T) {
+		var query string
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				query = r.URL.RawQuery
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"_index":"test","_id":"1","_seq_no":1,"found":true,"_source":{"title":"foo"}}`)),
+				}, nil
+			},
+		}})
+
+		var source struct {
+			Title string `json:"title"`
+		}
+		doc, err := GetDocument(context.Background(), es, "test", "1", &source, GetDocumentOptions{
+			FilterPath: FilterPathFor("_source", source),
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !doc.Found || source.Title != "foo" {
+			t.Errorf("Unexpected document: %+v, source: %+v", doc, source)
+		}
+		if !strings.Contains(query, "filter_path=_source.title%2C_index%2C_id") || !strings.Contains(query, "found") {
+			t.Errorf("Unexpected query: %s", query)
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		es := newClient(404, `{"_index":"test","_id":"1","found":false}`, nil)
+
+		var source map[string]interface{}
+		doc, err := GetDocument(context.Background(), es, "test", "1", &source, GetDocumentOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if doc.Found || doc.Index != "test" || doc.ID != "1" || source != nil {
+			t.Errorf("Unexpected document: %+v", doc)
+		}
+	})
+
+	t.Run("Index not found", func(t *testing.T) {
+		es := newClient(404, `{"error":{"type":"index_not_found_exception","reason":"no such index [test]"},"status":404}`, nil)
+
+		_, err := GetDocument(context.Background(), es, "test", "1", nil, GetDocumentOptions{})
+		if e, ok := err.(*ESError); !ok || e.Type != "index_not_found_exception" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
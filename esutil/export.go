@@ -0,0 +1,211 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+const (
+	exportPageSize   = 1000
+	exportFlushEvery = 1000
+)
+
+// ExportFormat configures the output format of the Export helper.
+//
+type ExportFormat struct {
+	csv    bool
+	fields []string
+}
+
+// ExportNDJSON writes every document source as a single line of JSON.
+//
+var ExportNDJSON = ExportFormat{}
+
+// ExportCSV writes the fields of every document as a CSV record, with a header record.
+//
+// The nested fields are selected by their dotted paths, eg. "user.name"; the "_id" and "_index"
+// fields select the document metadata. A missing field is written as an empty value,
+// and an object or an array is written as JSON.
+//
+func ExportCSV(fields ...string) ExportFormat {
+	return ExportFormat{csv: true, fields: fields}
+}
+
+// Export runs the query as a scroll, and writes all the matching documents to w in the format,
+// flushing the output periodically. It returns the number of the exported documents.
+//
+// The scroll is cleared on the cluster when the export completes or fails.
+//
+func Export(ctx context.Context, client *elasticsearch.Client, index string, query io.Reader, format ExportFormat, w io.Writer) (int, error) {
+	if format.csv && len(format.fields) == 0 {
+		return 0, errors.New("export: no CSV fields")
+	}
+
+	// Cancel the scroll when writing fails, so it's cleared on the cluster
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		n   int
+		bw  = bufio.NewWriter(w)
+		cw  *csv.Writer
+		buf bytes.Buffer
+	)
+
+	if format.csv {
+		cw = csv.NewWriter(bw)
+		if err := cw.Write(format.fields); err != nil {
+			return 0, fmt.Errorf("export: %s", err)
+		}
+	}
+
+	hitc, errc := ScrollHits(ctx, client, index, query, ScrollOptions{Size: exportPageSize})
+	for hit := range hitc {
+		var err error
+		if format.csv {
+			err = writeCSVRecord(cw, hit, format.fields)
+		} else {
+			buf.Reset()
+			if err = json.Compact(&buf, hit.Source); err == nil {
+				buf.WriteByte('\n')
+				_, err = bw.Write(buf.Bytes())
+			}
+		}
+		if err != nil {
+			cancel()
+			for range hitc {
+			}
+			return n, fmt.Errorf("export: document %q: %s", hit.ID, err)
+		}
+		n++
+
+		if n%exportFlushEvery == 0 {
+			if err := flushExport(bw, cw); err != nil {
+				cancel()
+				for range hitc {
+				}
+				return n, err
+			}
+		}
+	}
+	if err := <-errc; err != nil {
+		return n, fmt.Errorf("export: %s", err)
+	}
+
+	return n, flushExport(bw, cw)
+}
+
+// flushExport flushes the CSV writer, when set, and the buffered writer.
+//
+func flushExport(bw *bufio.Writer, cw *csv.Writer) error {
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("export: %s", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("export: %s", err)
+	}
+	return nil
+}
+
+// writeCSVRecord writes the fields of the hit as a CSV record.
+//
+func writeCSVRecord(cw *csv.Writer, hit SearchHit, fields []string) error {
+	var source map[string]interface{}
+	if len(hit.Source) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(hit.Source))
+		dec.UseNumber()
+		if err := dec.Decode(&source); err != nil {
+			return fmt.Errorf("cannot decode source: %s", err)
+		}
+	}
+
+	record := make([]string, len(fields))
+	for i, field := range fields {
+		switch field {
+		case "_id":
+			record[i] = hit.ID
+		case "_index":
+			record[i] = hit.Index
+		default:
+			v, ok := lookupField(source, field)
+			if !ok {
+				continue
+			}
+			s, err := formatCSVValue(v)
+			if err != nil {
+				return fmt.Errorf("cannot format field %q: %s", field, err)
+			}
+			record[i] = s
+		}
+	}
+	return cw.Write(record)
+}
+
+// lookupField returns the value of the field at the dotted path; the keys containing dots,
+// eg. "user.name" stored as a single key, are matched as well.
+//
+func lookupField(source map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := source[path]; ok {
+		return v, true
+	}
+	for i := strings.IndexByte(path, '.'); i >= 0; {
+		if obj, ok := source[path[:i]].(map[string]interface{}); ok {
+			if v, ok := lookupField(obj, path[i+1:]); ok {
+				return v, true
+			}
+		}
+		next := strings.IndexByte(path[i+1:], '.')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return nil, false
+}
+
+// formatCSVValue returns the value as a string for a CSV record.
+//
+func formatCSVValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
@@ -0,0 +1,150 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) { return 0, errors.New("MOCK ERROR") }
+
+func TestExport(t *testing.T) {
+	newResponse := func(body string) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	newClient := func(mu *sync.Mutex, cleared *bool) *elasticsearch.Client {
+		var numScrolls int
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				switch {
+				case r.Method == "DELETE":
+					*cleared = true
+					return newResponse(`{"succeeded":true}`), nil
+				case strings.HasSuffix(r.URL.Path, "/_search"):
+					return newResponse(`{"_scroll_id":"abc","hits":{"hits":[` +
+						`{"_index":"test","_id":"1","_source":{"title":"Foo, bar","user":{"name":"John","age":42},"tags":["a","b"]}},` +
+						`{"_index":"test","_id":"2","_source":{"title":"Baz","user.name":"Jane","active":true}}]}}`), nil
+				default:
+					numScrolls++
+					if numScrolls < 2 {
+						return newResponse(`{"_scroll_id":"abc","hits":{"hits":[{"_index":"test","_id":"3","_source":{"title":"Qux"}}]}}`), nil
+					}
+					return newResponse(`{"_scroll_id":"abc","hits":{"hits":[]}}`), nil
+				}
+			},
+		}})
+		return es
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			cleared bool
+			buf     bytes.Buffer
+		)
+
+		n, err := Export(context.Background(), newClient(&mu, &cleared), "test", strings.NewReader(`{}`),
+			ExportCSV("_id", "title", "user.name", "user.age", "tags", "active"), &buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if n != 3 {
+			t.Errorf("Unexpected number of documents: %d", n)
+		}
+		expected := "_id,title,user.name,user.age,tags,active\n" +
+			"1,\"Foo, bar\",John,42,\"[\"\"a\"\",\"\"b\"\"]\",\n" +
+			"2,Baz,Jane,,,true\n" +
+			"3,Qux,,,,\n"
+		if buf.String() != expected {
+			t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), expected)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !cleared {
+			t.Errorf("Expected the scroll to be cleared")
+		}
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			cleared bool
+			buf     bytes.Buffer
+		)
+
+		n, err := Export(context.Background(), newClient(&mu, &cleared), "test", strings.NewReader(`{}`), ExportNDJSON, &buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if n != 3 {
+			t.Errorf("Unexpected number of documents: %d", n)
+		}
+		expected := `{"title":"Foo, bar","user":{"name":"John","age":42},"tags":["a","b"]}` + "\n" +
+			`{"title":"Baz","user.name":"Jane","active":true}` + "\n" +
+			`{"title":"Qux"}` + "\n"
+		if buf.String() != expected {
+			t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), expected)
+		}
+	})
+
+	t.Run("Write error", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			cleared bool
+		)
+
+		_, err := Export(context.Background(), newClient(&mu, &cleared), "test", strings.NewReader(`{}`), ExportNDJSON, errWriter{})
+		if err == nil || !strings.Contains(err.Error(), "MOCK ERROR") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !cleared {
+			t.Errorf("Expected the scroll to be cleared")
+		}
+	})
+
+	t.Run("CSV without fields", func(t *testing.T) {
+		if _, err := Export(context.Background(), nil, "test", nil, ExportCSV(), ioutil.Discard); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+}
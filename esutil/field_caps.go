@@ -0,0 +1,190 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+// FieldCapability represents the capabilities of a field for a single field type.
+//
+// The lists of indices are present only when the field has different types
+// or capabilities across the indices.
+//
+type FieldCapability struct {
+	Type         string `json:"type"`
+	Searchable   bool   `json:"searchable"`
+	Aggregatable bool   `json:"aggregatable"`
+
+	Indices                []string `json:"indices,omitempty"`
+	NonSearchableIndices   []string `json:"non_searchable_indices,omitempty"`
+	NonAggregatableIndices []string `json:"non_aggregatable_indices,omitempty"`
+
+	Meta map[string][]string `json:"meta,omitempty"`
+}
+
+// FieldCapsResponse represents the response of the Field Capabilities API.
+//
+type FieldCapsResponse struct {
+	Indices []string                              `json:"indices"`
+	Fields  map[string]map[string]FieldCapability `json:"fields"` // Keyed by the field name, then by the field type.
+}
+
+// FieldCapsOptions configures the FieldCaps helper.
+//
+type FieldCapsOptions struct {
+	Cache *FieldCapsCache // The cache of the responses. Default: nil, no caching.
+}
+
+// FieldCapsCache caches the responses of the FieldCaps helper for a fixed time.
+//
+// The responses are keyed by the indices and the fields, regardless of their order.
+// The cache is safe for concurrent use, and can be shared by multiple clients
+// only when they're connected to the same cluster.
+//
+type FieldCapsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]fieldCapsEntry
+}
+
+// fieldCapsEntry represents a cached response.
+//
+type fieldCapsEntry struct {
+	indices []string
+	res     *FieldCapsResponse
+	expires time.Time
+}
+
+// NewFieldCapsCache returns a cache which keeps the responses for the ttl duration.
+//
+func NewFieldCapsCache(ttl time.Duration) *FieldCapsCache {
+	return &FieldCapsCache{ttl: ttl, entries: make(map[string]fieldCapsEntry)}
+}
+
+// Invalidate removes the cached responses which include any of the indices,
+// eg. after their mapping has been updated, or all responses when no index is passed.
+//
+// The indices are compared to the indices passed to FieldCaps verbatim, so a response
+// for a wildcard pattern or an alias is removed only by the same pattern or alias.
+//
+func (c *FieldCapsCache) Invalidate(indices ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(indices) == 0 {
+		c.entries = make(map[string]fieldCapsEntry)
+		return
+	}
+
+	for key, entry := range c.entries {
+	loop:
+		for _, a := range entry.indices {
+			for _, b := range indices {
+				if a == b {
+					delete(c.entries, key)
+					break loop
+				}
+			}
+		}
+	}
+}
+
+// get returns the cached response for the key, when it hasn't expired.
+//
+func (c *FieldCapsCache) get(key string) (*FieldCapsResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.res, true
+}
+
+// set stores the response for the key.
+//
+func (c *FieldCapsCache) set(key string, indices []string, res *FieldCapsResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = fieldCapsEntry{indices: indices, res: res, expires: time.Now().Add(c.ttl)}
+}
+
+// FieldCaps returns the capabilities of the fields in the indices, eg. to build dynamic queries
+// based on the field types. The fields can be wildcard patterns, eg. "user.*"; no fields means all of them.
+//
+// When the options include a cache, the response is returned from the cache, when present,
+// and it's shared by all callers: the caller must not modify it.
+//
+func FieldCaps(ctx context.Context, client *elasticsearch.Client, indices []string, fields []string, opts FieldCapsOptions) (*FieldCapsResponse, error) {
+	if len(fields) == 0 {
+		fields = []string{"*"}
+	}
+
+	var key string
+	if opts.Cache != nil {
+		key = fieldCapsKey(indices, fields)
+		if res, ok := opts.Cache.get(key); ok {
+			return res, nil
+		}
+	}
+
+	reqOpts := []func(*esapi.FieldCapsRequest){
+		client.FieldCaps.WithContext(ctx),
+		client.FieldCaps.WithFields(fields...),
+	}
+	if len(indices) > 0 {
+		reqOpts = append(reqOpts, client.FieldCaps.WithIndex(indices...))
+	}
+
+	var out FieldCapsResponse
+	res, err := client.FieldCaps(reqOpts...)
+	if err := decodeResponse("field caps", res, err, &out); err != nil {
+		return nil, err
+	}
+
+	if opts.Cache != nil {
+		opts.Cache.set(key, append([]string(nil), indices...), &out)
+	}
+
+	return &out, nil
+}
+
+// fieldCapsKey returns the cache key for the indices and fields, regardless of their order.
+//
+func fieldCapsKey(indices []string, fields []string) string {
+	ii := append([]string(nil), indices...)
+	ff := append([]string(nil), fields...)
+	sort.Strings(ii)
+	sort.Strings(ff)
+	return strings.Join(ii, ",") + "/" + strings.Join(ff, ",")
+}
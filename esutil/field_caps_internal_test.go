@@ -0,0 +1,170 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestFieldCaps(t *testing.T) {
+	body := `{
+  "indices": ["logs-1", "logs-2"],
+  "fields": {
+    "status": {
+      "keyword": {"type": "keyword", "searchable": true, "aggregatable": true, "indices": ["logs-1"]},
+      "long": {"type": "long", "searchable": true, "aggregatable": true, "indices": ["logs-2"]}
+    },
+    "message": {
+      "text": {"type": "text", "searchable": true, "aggregatable": false}
+    }
+  }
+}`
+
+	newClient := func(numRequests *int32, paths *[]string) *elasticsearch.Client {
+		var mu sync.Mutex
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				atomic.AddInt32(numRequests, 1)
+				mu.Lock()
+				*paths = append(*paths, r.URL.Path+"?"+r.URL.RawQuery)
+				mu.Unlock()
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}})
+		return es
+	}
+
+	t.Run("Without cache", func(t *testing.T) {
+		var (
+			n     int32
+			paths []string
+		)
+		es := newClient(&n, &paths)
+
+		for i := 0; i < 2; i++ {
+			res, err := FieldCaps(context.Background(), es, []string{"logs-*"}, nil, FieldCapsOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if len(res.Indices) != 2 {
+				t.Errorf("Unexpected indices: %v", res.Indices)
+			}
+			if c := res.Fields["status"]["long"]; c.Type != "long" || !c.Aggregatable || len(c.Indices) != 1 {
+				t.Errorf("Unexpected capability: %+v", c)
+			}
+			if c := res.Fields["message"]["text"]; c.Aggregatable {
+				t.Errorf("Unexpected capability: %+v", c)
+			}
+		}
+
+		if n != 2 {
+			t.Errorf("Unexpected number of requests: %d", n)
+		}
+		if paths[0] != "/logs-*/_field_caps?fields=%2A" {
+			t.Errorf("Unexpected request: %s", paths[0])
+		}
+	})
+
+	t.Run("With cache", func(t *testing.T) {
+		var (
+			n     int32
+			paths []string
+		)
+		es := newClient(&n, &paths)
+		cache := NewFieldCapsCache(time.Minute)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				FieldCaps(context.Background(), es, []string{"logs-1", "logs-2"}, []string{"status", "message"}, FieldCapsOptions{Cache: cache})
+			}()
+		}
+		wg.Wait()
+
+		n = 0
+		res, err := FieldCaps(context.Background(), es, []string{"logs-2", "logs-1"}, []string{"message", "status"}, FieldCapsOptions{Cache: cache})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if n != 0 {
+			t.Errorf("Expected the response from the cache, got %d requests", n)
+		}
+		if len(res.Fields) != 2 {
+			t.Errorf("Unexpected fields: %v", res.Fields)
+		}
+
+		FieldCaps(context.Background(), es, []string{"logs-1"}, []string{"status"}, FieldCapsOptions{Cache: cache})
+		if n != 1 {
+			t.Errorf("Expected a request for different indices, got %d requests", n)
+		}
+
+		cache.Invalidate("logs-2")
+		FieldCaps(context.Background(), es, []string{"logs-1"}, []string{"status"}, FieldCapsOptions{Cache: cache})
+		if n != 1 {
+			t.Errorf("Expected the response from the cache, got %d requests", n)
+		}
+		FieldCaps(context.Background(), es, []string{"logs-1", "logs-2"}, []string{"status", "message"}, FieldCapsOptions{Cache: cache})
+		if n != 2 {
+			t.Errorf("Expected a request after invalidation, got %d requests", n)
+		}
+
+		cache.Invalidate()
+		FieldCaps(context.Background(), es, []string{"logs-1"}, []string{"status"}, FieldCapsOptions{Cache: cache})
+		if n != 3 {
+			t.Errorf("Expected a request after invalidation, got %d requests", n)
+		}
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		var (
+			n     int32
+			paths []string
+		)
+		es := newClient(&n, &paths)
+		cache := NewFieldCapsCache(10 * time.Millisecond)
+
+		FieldCaps(context.Background(), es, []string{"logs-1"}, nil, FieldCapsOptions{Cache: cache})
+		FieldCaps(context.Background(), es, []string{"logs-1"}, nil, FieldCapsOptions{Cache: cache})
+		if n != 1 {
+			t.Errorf("Expected the response from the cache, got %d requests", n)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		FieldCaps(context.Background(), es, []string{"logs-1"}, nil, FieldCapsOptions{Cache: cache})
+		if n != 2 {
+			t.Errorf("Expected a request after expiration, got %d requests", n)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"reflect"
+	"strings"
+)
+
+var (
+	// Paths always included in the filter_path of the helpers, so the filtering doesn't remove
+	// the error envelope, or the fields used by the decoded responses.
+	requiredErrorPaths  = []string{"error", "status"}
+	requiredSearchPaths = []string{
+		"took", "timed_out", "_shards", "_clusters", "hits.total", "hits.max_score",
+		"hits.hits._index", "hits.hits._id", "hits.hits._score", "hits.hits.sort",
+	}
+	requiredDocumentPaths = []string{"_index", "_id", "_version", "_seq_no", "_primary_term", "found"}
+)
+
+// FilterPathFor returns the filter_path expressions for the fields of the type of v, prefixed with prefix,
+// eg. FilterPathFor("hits.hits._source", Article{}) returns ["hits.hits._source.title", ...].
+//
+// The field names are taken from the "json" struct tags, like in encoding/json; nested structs,
+// and slices of structs, are expanded into their fields. When v is not a struct, or a pointer
+// to a struct, it returns the prefix itself.
+//
+func FilterPathFor(prefix string, v interface{}) []string {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || t == timeType {
+		return []string{prefix}
+	}
+	return filterPaths(prefix, t, map[reflect.Type]bool{})
+}
+
+// filterPaths returns the paths for the fields of t; the seen map guards against recursive types.
+//
+func filterPaths(prefix string, t reflect.Type, seen map[reflect.Type]bool) []string {
+	var out []string
+
+	if seen[t] {
+		return []string{prefix}
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			} else if f.Anonymous {
+				name = ""
+			}
+		} else if f.Anonymous {
+			name = ""
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array {
+			ft = ft.Elem()
+		}
+		isStruct := ft.Kind() == reflect.Struct && ft != timeType
+
+		// Embedded structs without a name are flattened, like in encoding/json
+		if name == "" {
+			if isStruct {
+				out = append(out, filterPaths(prefix, ft, seen)...)
+				continue
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+			name = f.Name
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		if isStruct {
+			out = append(out, filterPaths(path, ft, seen)...)
+		} else {
+			out = append(out, path)
+		}
+	}
+
+	return out
+}
+
+// withRequiredPaths returns the paths with the required ones appended, or nil when paths is empty,
+// so the filter_path parameter is not set.
+//
+func withRequiredPaths(paths []string, required ...[]string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	out := append([]string{}, paths...)
+	for _, r := range required {
+		out = append(out, r...)
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterPath(t *testing.T) {
+	t.Run("FilterPathFor", func(t *testing.T) {
+		type author struct {
+			Name string `json:"name"`
+		}
+		type meta struct {
+			Source string `json:"source"`
+		}
+		type article struct {
+			meta
+
+			Title     string    `json:"title"`
+			Published time.Time `json:"published"`
+			Authors   []author  `json:"authors"`
+			Editor    *author   `json:"editor,omitempty"`
+			Internal  string    `json:"-"`
+			Untagged  string
+			private   string
+		}
+
+		paths := FilterPathFor("hits.hits._source", &article{})
+		expected := []string{
+			"hits.hits._source.source",
+			"hits.hits._source.title",
+			"hits.hits._source.published",
+			"hits.hits._source.authors.name",
+			"hits.hits._source.editor.name",
+			"hits.hits._source.Untagged",
+		}
+		if !reflect.DeepEqual(paths, expected) {
+			t.Errorf("Unexpected paths:\nwant: %q\ngot:  %q", expected, paths)
+		}
+
+		if paths := FilterPathFor("hits.hits._id", ""); !reflect.DeepEqual(paths, []string{"hits.hits._id"}) {
+			t.Errorf("Unexpected paths: %q", paths)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		var query string
+		es := newSearchClient(`{"took":1,"hits":{"total":{"value":1,"relation":"eq"},"hits":[{"_id":"1","_source":{"title":"foo"},"sort":[1]}]}}`, &query)
+
+		res, err := Search(context.Background(), es, "test", strings.NewReader(`{}`), SearchOptions{
+			FilterPath: []string{"hits.hits._source.title"},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.Hits.Total.Value != 1 || len(res.Hits.Hits) != 1 {
+			t.Fatalf("Unexpected response: %+v", res)
+		}
+		if hit := res.Hits.Hits[0]; hit.ID != "1" || len(hit.Sort) != 1 {
+			t.Errorf("Unexpected hit: %+v", hit)
+		}
+
+		values, _ := url.ParseQuery(query)
+		paths := strings.Split(values.Get("filter_path"), ",")
+		for _, p := range []string{
+			"hits.hits._source.title", "hits.total", "_shards", "error",
+			"hits.hits._id", "hits.hits._index", "hits.hits._score", "hits.hits.sort",
+		} {
+			var found bool
+			for _, pp := range paths {
+				if pp == p {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected %q in filter_path, got: %q", p, paths)
+			}
+		}
+	})
+
+	t.Run("Search without filter path", func(t *testing.T) {
+		var query string
+		es := newSearchClient(`{"hits":{"hits":[]}}`, &query)
+
+		if _, err := Search(context.Background(), es, "test", strings.NewReader(`{}`), SearchOptions{}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if strings.Contains(query, "filter_path") {
+			t.Errorf("Unexpected query: %s", query)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"net/url"
+)
+
+// EncodeID escapes the document ID, so it can be safely placed inside a URL path,
+// eg. "a/b#c" is encoded as "a%2Fb%23c".
+//
+// The esapi package escapes the document IDs passed to the API methods,
+// use the function only when building the request path manually.
+//
+func EncodeID(id string) string {
+	return url.PathEscape(id)
+}
@@ -0,0 +1,79 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestEncodeID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"foo", "foo"},
+		{"a/b#c", "a%2Fb%23c"},
+		{"a?b=c&d", "a%3Fb=c&d"},
+		{"100%", "100%25"},
+		{"foo bar", "foo%20bar"},
+		{"žluťoučký", "%C5%BElu%C5%A5ou%C4%8Dk%C3%BD"},
+		{"日本", "%E6%97%A5%E6%9C%AC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := EncodeID(tt.id); got != tt.want {
+				t.Errorf("Unexpected encoded ID: want=%q, got=%q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("API request path", func(t *testing.T) {
+		var requestURI string
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{
+			Addresses: []string{"http://localhost:9200/prefix"},
+			Transport: &mockTransport{
+				RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+					requestURI = r.URL.RequestURI()
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+						Body:       http.NoBody,
+					}, nil
+				},
+			},
+		})
+
+		res, err := es.Get("test", "a/b#c?ž", es.Get.WithContext(context.Background()))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		res.Body.Close()
+
+		if requestURI != "/prefix/test/_doc/a%2Fb%23c%3F%C5%BE" {
+			t.Errorf("Unexpected request URI: %s", requestURI)
+		}
+	})
+}
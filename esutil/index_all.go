@@ -0,0 +1,118 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+// IndexAllResult represents the result of the IndexAll helper.
+//
+type IndexAllResult struct {
+	NumIndexed uint64 // The number of successfully indexed documents.
+	NumFailed  uint64 // The number of documents which haven't been indexed.
+}
+
+// IndexAll indexes the documents into index with a bulk indexer, and waits for its completion.
+//
+// The docs argument must be a slice or an array; every element is encoded to JSON.
+// When idFunc is not nil, it's called with every element to return the document ID;
+// an empty ID, or a nil idFunc, lets Elasticsearch generate the ID.
+//
+// It returns the number of indexed and failed documents, and the first error encountered,
+// eg. a failure to encode a document, an item failure, or a failure of a bulk request.
+//
+func IndexAll(ctx context.Context, client *elasticsearch.Client, index string, docs interface{}, idFunc func(doc interface{}) string) (IndexAllResult, error) {
+	var (
+		result IndexAllResult
+
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	setErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	v := reflect.ValueOf(docs)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return result, fmt.Errorf("index all: docs must be a slice or an array, got %T", docs)
+	}
+
+	bi, err := NewBulkIndexer(BulkIndexerConfig{
+		Client:  client,
+		Index:   index,
+		OnError: func(_ context.Context, err error) { setErr(fmt.Errorf("index all: %s", err)) },
+	})
+	if err != nil {
+		return result, fmt.Errorf("index all: %s", err)
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		doc := v.Index(i).Interface()
+
+		body, err := json.Marshal(doc)
+		if err != nil {
+			setErr(fmt.Errorf("index all: cannot encode document %d: %s", i, err))
+			continue
+		}
+
+		item := BulkIndexerItem{
+			Action: "index",
+			Body:   bytes.NewReader(body),
+			OnSuccess: func(context.Context, BulkIndexerItem, BulkIndexerResponseItem) {
+				atomic.AddUint64(&result.NumIndexed, 1)
+			},
+			OnFailure: func(_ context.Context, item BulkIndexerItem, res BulkIndexerResponseItem, err error) {
+				if err != nil {
+					setErr(fmt.Errorf("index all: %s", err))
+					return
+				}
+				setErr(fmt.Errorf("index all: document [%s]: [%d] %s: %s", item.DocumentID, res.Status, res.Error.Type, res.Error.Reason))
+			},
+		}
+		if idFunc != nil {
+			item.DocumentID = idFunc(doc)
+		}
+
+		if err := bi.Add(ctx, item); err != nil {
+			setErr(fmt.Errorf("index all: %s", err))
+			break
+		}
+	}
+
+	if err := bi.Close(ctx); err != nil {
+		setErr(fmt.Errorf("index all: %s", err))
+	}
+
+	result.NumFailed = uint64(v.Len()) - atomic.LoadUint64(&result.NumIndexed)
+
+	return result, firstErr
+}
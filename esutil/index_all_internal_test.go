@@ -0,0 +1,142 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestIndexAll(t *testing.T) {
+	type doc struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+
+	newClient := func(ids *[]string) *elasticsearch.Client {
+		var mu sync.Mutex
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				var items []string
+
+				scanner := bufio.NewScanner(r.Body)
+				for i := 0; scanner.Scan(); i++ {
+					if i%2 == 1 {
+						continue // Skip the document source
+					}
+					var meta map[string]struct {
+						ID string `json:"_id"`
+					}
+					json.Unmarshal(scanner.Bytes(), &meta)
+
+					id := meta["index"].ID
+					mu.Lock()
+					*ids = append(*ids, id)
+					mu.Unlock()
+
+					if id == "bad" {
+						items = append(items, `{"index":{"_id":"bad","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}`)
+					} else {
+						items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":201}}`, id))
+					}
+				}
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"errors":false,"items":[` + strings.Join(items, ",") + `]}`)),
+				}, nil
+			},
+		}})
+		return es
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		var ids []string
+		es := newClient(&ids)
+
+		docs := []doc{{ID: "1", Title: "foo"}, {ID: "2", Title: "bar"}, {ID: "3", Title: "baz"}}
+		res, err := IndexAll(context.Background(), es, "test", docs, func(d interface{}) string { return d.(doc).ID })
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if res.NumIndexed != 3 || res.NumFailed != 0 {
+			t.Errorf("Unexpected result: %+v", res)
+		}
+		if len(ids) != 3 || ids[0] == "" {
+			t.Errorf("Unexpected document IDs: %q", ids)
+		}
+	})
+
+	t.Run("Item failure", func(t *testing.T) {
+		var ids []string
+		es := newClient(&ids)
+
+		docs := []doc{{ID: "1"}, {ID: "bad"}, {ID: "3"}}
+		res, err := IndexAll(context.Background(), es, "test", docs, func(d interface{}) string { return d.(doc).ID })
+		if err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if res.NumIndexed != 2 || res.NumFailed != 1 {
+			t.Errorf("Unexpected result: %+v", res)
+		}
+	})
+
+	t.Run("Without ID function", func(t *testing.T) {
+		var ids []string
+		es := newClient(&ids)
+
+		res, err := IndexAll(context.Background(), es, "test", []map[string]string{{"foo": "bar"}}, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.NumIndexed != 1 || len(ids) != 1 || ids[0] != "" {
+			t.Errorf("Unexpected result: %+v, IDs: %q", res, ids)
+		}
+	})
+
+	t.Run("Invalid documents", func(t *testing.T) {
+		var ids []string
+		es := newClient(&ids)
+
+		if _, err := IndexAll(context.Background(), es, "test", doc{}, nil); err == nil {
+			t.Errorf("Expected error for non-slice documents")
+		}
+
+		res, err := IndexAll(context.Background(), es, "test", []interface{}{map[string]string{}, func() {}}, nil)
+		if err == nil || !strings.Contains(err.Error(), "cannot encode document 1") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if res.NumIndexed != 1 || res.NumFailed != 1 {
+			t.Errorf("Unexpected result: %+v", res)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+// IndexOneOptions configures the IndexOne helper.
+//
+type IndexOneOptions struct {
+	Refresh string // Set to "true" or "wait_for" to make the document visible to search. Default: "false".
+	Create  bool   // Fail when the document already exists, with the "create" operation type.
+
+	// Index the document only if its last modification has the sequence number and primary term,
+	// for optimistic concurrency control; see Document. Both must be set.
+	IfSeqNo       *int64
+	IfPrimaryTerm *int64
+}
+
+// IndexResult represents the result of the IndexOne helper.
+//
+type IndexResult struct {
+	Index       string `json:"_index"`
+	ID          string `json:"_id"`
+	Version     int64  `json:"_version"`
+	SeqNo       int64  `json:"_seq_no"`
+	PrimaryTerm int64  `json:"_primary_term"`
+	Result      string `json:"result"` // "created" or "updated".
+}
+
+// IndexOne encodes the document to JSON, and indexes it with the Index API.
+//
+// When id is empty, Elasticsearch generates the document ID. It returns the new sequence number
+// and primary term of the document, for use in the subsequent conditional operations.
+//
+// When the document exists, with Create, or has been modified, with IfSeqNo and IfPrimaryTerm,
+// the error is a *VersionConflictError; when the document doesn't match the mapping,
+// it's a *MappingError; the other failures are returned as *ESError.
+//
+func IndexOne(ctx context.Context, client *elasticsearch.Client, index, id string, doc interface{}, opts IndexOneOptions) (*IndexResult, error) {
+	var result IndexResult
+
+	if (opts.IfSeqNo == nil) != (opts.IfPrimaryTerm == nil) {
+		return nil, fmt.Errorf("index: both IfSeqNo and IfPrimaryTerm must be set")
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("index: cannot encode document: %s", err)
+	}
+
+	reqOpts := []func(*esapi.IndexRequest){
+		client.Index.WithContext(ctx),
+	}
+	if id != "" {
+		reqOpts = append(reqOpts, client.Index.WithDocumentID(id))
+	}
+	if opts.Refresh != "" {
+		reqOpts = append(reqOpts, client.Index.WithRefresh(opts.Refresh))
+	}
+	if opts.Create {
+		reqOpts = append(reqOpts, client.Index.WithOpType("create"))
+	}
+	if opts.IfSeqNo != nil {
+		reqOpts = append(reqOpts,
+			client.Index.WithIfSeqNo(int(*opts.IfSeqNo)),
+			client.Index.WithIfPrimaryTerm(int(*opts.IfPrimaryTerm)),
+		)
+	}
+
+	res, err := client.Index(index, bytes.NewReader(body), reqOpts...)
+	if err := decodeResponse("index", res, err, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
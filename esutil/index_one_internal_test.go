@@ -0,0 +1,117 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestIndexOne(t *testing.T) {
+	newClient := func(status int, body string, req **http.Request) *elasticsearch.Client {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				if req != nil {
+					*req = r
+				}
+				return &http.Response{
+					StatusCode: status,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}})
+		return es
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		var req *http.Request
+		es := newClient(201, `{"_index":"test","_id":"1","_version":2,"_seq_no":5,"_primary_term":1,"result":"updated"}`, &req)
+
+		seqNo, primaryTerm := int64(4), int64(1)
+		res, err := IndexOne(context.Background(), es, "test", "1", map[string]string{"title": "foo"}, IndexOneOptions{
+			Refresh:       "wait_for",
+			IfSeqNo:       &seqNo,
+			IfPrimaryTerm: &primaryTerm,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if req.Method != "PUT" || req.URL.Path != "/test/_doc/1" {
+			t.Errorf("Unexpected request: %s %s", req.Method, req.URL)
+		}
+		q := req.URL.Query()
+		if q.Get("refresh") != "wait_for" || q.Get("if_seq_no") != "4" || q.Get("if_primary_term") != "1" {
+			t.Errorf("Unexpected query: %s", req.URL.RawQuery)
+		}
+		if res.SeqNo != 5 || res.PrimaryTerm != 1 || res.Version != 2 || res.Result != "updated" {
+			t.Errorf("Unexpected result: %+v", res)
+		}
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		var req *http.Request
+		es := newClient(201, `{"_index":"test","_id":"abc","result":"created"}`, &req)
+
+		res, err := IndexOne(context.Background(), es, "test", "", struct{}{}, IndexOneOptions{Create: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if req.Method != "POST" || req.URL.Query().Get("op_type") != "create" {
+			t.Errorf("Unexpected request: %s %s", req.Method, req.URL)
+		}
+		if res.ID != "abc" {
+			t.Errorf("Unexpected result: %+v", res)
+		}
+	})
+
+	t.Run("Version conflict", func(t *testing.T) {
+		es := newClient(409, `{"error":{"type":"version_conflict_engine_exception","reason":"[1]: version conflict, document already exists"},"status":409}`, nil)
+
+		_, err := IndexOne(context.Background(), es, "test", "1", struct{}{}, IndexOneOptions{Create: true})
+		if e, ok := err.(*VersionConflictError); !ok || e.StatusCode != 409 {
+			t.Errorf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Mapping error", func(t *testing.T) {
+		es := newClient(400, `{"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [count]"},"status":400}`, nil)
+
+		_, err := IndexOne(context.Background(), es, "test", "1", map[string]string{"count": "foo"}, IndexOneOptions{})
+		if e, ok := err.(*MappingError); !ok || e.Reason != "failed to parse field [count]" {
+			t.Errorf("Unexpected error: %#v", err)
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		es := newClient(200, `{}`, nil)
+
+		seqNo := int64(1)
+		if _, err := IndexOne(context.Background(), es, "test", "1", struct{}{}, IndexOneOptions{IfSeqNo: &seqNo}); err == nil {
+			t.Errorf("Expected error for missing IfPrimaryTerm")
+		}
+	})
+}
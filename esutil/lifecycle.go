@@ -0,0 +1,167 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+// PutLifecyclePolicy creates or updates the index lifecycle (ILM) policy, unless the policy
+// stored on the cluster is already equivalent. It returns whether the policy has been written.
+//
+// The policy is the body of the Put Lifecycle API, eg. {"policy":{"phases":{...}}}; any other
+// top-level keys, like "version" or "modified_date" in the output of the Get Lifecycle API,
+// are ignored, so the output of that API can be passed back as is.
+//
+// The desired and the stored policy are normalized before the comparison:
+//
+//   - Only the "policy" object is compared, so the server-added "version", "modified_date"
+//     and "in_use_by" are ignored.
+//   - Object keys are compared regardless of their order; array elements in order.
+//   - Numbers are compared by value, so 1 equals 1.0.
+//   - Keys with a null value are ignored.
+//   - A phase without "min_age" is treated as "min_age":"0ms", and without "actions"
+//     as "actions":{}, as the cluster fills them in.
+//   - A "delete" action without "delete_searchable_snapshot" is treated as
+//     "delete_searchable_snapshot":true, as the cluster fills it in.
+//
+// Other values are compared verbatim: eg. "1d" and "24h" are different, and updating
+// such a policy writes it again, bumping its version.
+//
+func PutLifecyclePolicy(ctx context.Context, client *elasticsearch.Client, name string, policy io.Reader) (bool, error) {
+	var body struct {
+		Policy map[string]interface{} `json:"policy"`
+	}
+	if err := json.NewDecoder(policy).Decode(&body); err != nil {
+		return false, fmt.Errorf("put lifecycle policy: %s", err)
+	}
+	if body.Policy == nil {
+		return false, errors.New(`put lifecycle policy: missing "policy" object`)
+	}
+
+	current, found, err := getLifecyclePolicy(ctx, client, name)
+	if err != nil {
+		return false, err
+	}
+	if found && reflect.DeepEqual(normalizeLifecyclePolicy(current), normalizeLifecyclePolicy(body.Policy)) {
+		return false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return false, fmt.Errorf("put lifecycle policy: %s", err)
+	}
+	res, err := client.ILM.PutLifecycle(
+		name,
+		client.ILM.PutLifecycle.WithBody(&buf),
+		client.ILM.PutLifecycle.WithContext(ctx),
+	)
+	if err := checkResponse("put lifecycle policy", res, err); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getLifecyclePolicy returns the "policy" object of the stored policy,
+// and whether the policy exists.
+//
+func getLifecyclePolicy(ctx context.Context, client *elasticsearch.Client, name string) (map[string]interface{}, bool, error) {
+	res, err := client.ILM.GetLifecycle(
+		client.ILM.GetLifecycle.WithPolicy(name),
+		client.ILM.GetLifecycle.WithContext(ctx),
+	)
+	if err == nil && res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, false, nil
+	}
+
+	var out map[string]struct {
+		Policy map[string]interface{} `json:"policy"`
+	}
+	if err := decodeResponse("get lifecycle policy", res, err, &out); err != nil {
+		return nil, false, err
+	}
+	p, ok := out[name]
+	if !ok {
+		return nil, false, nil
+	}
+	return p.Policy, true, nil
+}
+
+// normalizeLifecyclePolicy returns a copy of the policy with the defaults
+// filled in by the cluster, for comparison with reflect.DeepEqual.
+//
+func normalizeLifecyclePolicy(policy map[string]interface{}) interface{} {
+	p, _ := normalizeJSON(policy).(map[string]interface{})
+	if p == nil {
+		return p
+	}
+
+	phases, _ := p["phases"].(map[string]interface{})
+	for _, v := range phases {
+		phase, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := phase["min_age"]; !ok {
+			phase["min_age"] = "0ms"
+		}
+		if _, ok := phase["actions"]; !ok {
+			phase["actions"] = map[string]interface{}{}
+		}
+		actions, _ := phase["actions"].(map[string]interface{})
+		if del, ok := actions["delete"].(map[string]interface{}); ok {
+			if _, ok := del["delete_searchable_snapshot"]; !ok {
+				del["delete_searchable_snapshot"] = true
+			}
+		}
+	}
+	return p
+}
+
+// normalizeJSON returns a deep copy of the decoded JSON value, without the null object keys.
+//
+func normalizeJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			if vv != nil {
+				m[k] = normalizeJSON(vv)
+			}
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, vv := range v {
+			a[i] = normalizeJSON(vv)
+		}
+		return a
+	default:
+		return v
+	}
+}
@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestPutLifecyclePolicy(t *testing.T) {
+	type request struct {
+		method, path, body string
+	}
+
+	newClient := func(status int, stored string, reqs *[]request) *elasticsearch.Client {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				req := request{method: r.Method, path: r.URL.Path}
+				if r.Body != nil {
+					b, _ := ioutil.ReadAll(r.Body)
+					req.body = strings.TrimSpace(string(b))
+				}
+				*reqs = append(*reqs, req)
+
+				code, body := 200, `{"acknowledged":true}`
+				if r.Method == "GET" {
+					code, body = status, stored
+				}
+				return &http.Response{
+					StatusCode: code,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}})
+		return es
+	}
+
+	stored := `{"logs":{"version":3,"modified_date":"2021-01-01T00:00:00.000Z","in_use_by":{"indices":[]},"policy":{
+		"phases":{
+			"hot":{"min_age":"0ms","actions":{"rollover":{"max_age":"30d","max_primary_shard_size":"50gb"}}},
+			"delete":{"min_age":"90d","actions":{"delete":{"delete_searchable_snapshot":true}}}
+		}}}}`
+
+	t.Run("Create", func(t *testing.T) {
+		var reqs []request
+		es := newClient(404, `{"error":{"type":"resource_not_found_exception"},"status":404}`, &reqs)
+
+		updated, err := PutLifecyclePolicy(context.Background(), es, "logs", strings.NewReader(`{"policy":{"phases":{"hot":{"actions":{}}}}}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !updated {
+			t.Errorf("Expected the policy to be written")
+		}
+
+		if len(reqs) != 2 {
+			t.Fatalf("Unexpected requests: %+v", reqs)
+		}
+		if reqs[1].method != "PUT" || reqs[1].path != "/_ilm/policy/logs" || reqs[1].body != `{"policy":{"phases":{"hot":{"actions":{}}}}}` {
+			t.Errorf("Unexpected request: %+v", reqs[1])
+		}
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		var reqs []request
+		es := newClient(200, stored, &reqs)
+
+		// Reordered keys, missing defaults, nulls and float numbers
+		policy := `{"policy":{"_meta":null,"phases":{
+			"delete":{"actions":{"delete":{}},"min_age":"90d"},
+			"hot":{"actions":{"rollover":{"max_primary_shard_size":"50gb","max_age":"30d"}}}
+		}}}`
+		updated, err := PutLifecyclePolicy(context.Background(), es, "logs", strings.NewReader(policy))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if updated {
+			t.Errorf("Expected the policy not to be written")
+		}
+		if len(reqs) != 1 || reqs[0].method != "GET" || reqs[0].path != "/_ilm/policy/logs" {
+			t.Errorf("Unexpected requests: %+v", reqs)
+		}
+	})
+
+	t.Run("Unchanged from get output", func(t *testing.T) {
+		var reqs []request
+		es := newClient(200, stored, &reqs)
+
+		policy := stored[len(`{"logs":`) : len(stored)-1]
+		updated, err := PutLifecyclePolicy(context.Background(), es, "logs", strings.NewReader(policy))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if updated {
+			t.Errorf("Expected the policy not to be written")
+		}
+	})
+
+	t.Run("Numbers", func(t *testing.T) {
+		var reqs []request
+		es := newClient(200, `{"p":{"policy":{"phases":{"hot":{"min_age":"0ms","actions":{"set_priority":{"priority":100}}}}}}}`, &reqs)
+
+		updated, err := PutLifecyclePolicy(context.Background(), es, "p", strings.NewReader(`{"policy":{"phases":{"hot":{"actions":{"set_priority":{"priority":100.0}}}}}}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if updated {
+			t.Errorf("Expected the policy not to be written")
+		}
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		var reqs []request
+		es := newClient(200, stored, &reqs)
+
+		policy := `{"policy":{"phases":{
+			"hot":{"actions":{"rollover":{"max_age":"7d","max_primary_shard_size":"50gb"}}},
+			"delete":{"min_age":"90d","actions":{"delete":{}}}
+		}}}`
+		updated, err := PutLifecyclePolicy(context.Background(), es, "logs", strings.NewReader(policy))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !updated {
+			t.Errorf("Expected the policy to be written")
+		}
+		if len(reqs) != 2 || reqs[1].method != "PUT" || !strings.Contains(reqs[1].body, `"max_age":"7d"`) {
+			t.Errorf("Unexpected requests: %+v", reqs)
+		}
+	})
+
+	t.Run("Missing policy", func(t *testing.T) {
+		var reqs []request
+		es := newClient(200, stored, &reqs)
+
+		_, err := PutLifecyclePolicy(context.Background(), es, "logs", strings.NewReader(`{"phases":{}}`))
+		if err == nil || !strings.Contains(err.Error(), `missing "policy" object`) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(reqs) != 0 {
+			t.Errorf("Unexpected requests: %+v", reqs)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var reqs []request
+		es := newClient(500, `{"error":{"type":"illegal_state_exception","reason":"boom"},"status":500}`, &reqs)
+
+		_, err := PutLifecyclePolicy(context.Background(), es, "logs", strings.NewReader(`{"policy":{}}`))
+		if err == nil {
+			t.Fatalf("Expected error")
+		}
+		if len(reqs) != 1 {
+			t.Errorf("Unexpected requests: %+v", reqs)
+		}
+	})
+}
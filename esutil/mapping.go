@@ -0,0 +1,296 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+// CreateIndexOptions configures the CreateIndexFromStruct helper.
+//
+type CreateIndexOptions struct {
+	Settings map[string]interface{} // The index settings, eg. number of shards. Default: none.
+}
+
+// CreateIndexFromStruct creates the index with a mapping generated from the type of v,
+// which must be a struct or a pointer to a struct.
+//
+// The field names are taken from the "json" struct tags, and fields ignored by encoding/json
+// are skipped. The mapping of a field can be customized with the "es" struct tag,
+// which holds a comma-separated list of mapping parameters:
+//
+//	type Article struct {
+//	    ID        string    `json:"id"         es:"type=keyword,index=false"`
+//	    Title     string    `json:"title"      es:"analyzer=english"`
+//	    Published time.Time `json:"published"`
+//	    Authors   []Author  `json:"authors"    es:"type=nested"`
+//	    Internal  string    `json:"internal"   es:"-"`
+//	}
+//
+// The "type" parameter overrides the inferred field type, the remaining parameters are copied
+// to the field mapping verbatim, except for "true" and "false", which are converted to booleans.
+// Use `es:"-"` to leave a field out of the mapping.
+//
+// The field types are inferred as follows: string is mapped to "text", bool to "boolean",
+// integers to "byte", "short", "integer" or "long", floats to "float" or "double",
+// time.Time to "date", []byte to "binary", and structs to "object" with their own properties.
+// Set `es:"type=nested"` on a struct, or a slice of structs, to map it as "nested".
+// Slices and arrays are mapped as their element type; pointers as the type they point to.
+//
+func CreateIndexFromStruct(ctx context.Context, client *elasticsearch.Client, index string, v interface{}, opts CreateIndexOptions) error {
+	var body bytes.Buffer
+
+	mapping, err := structMapping(v)
+	if err != nil {
+		return fmt.Errorf("create index: %s", err)
+	}
+
+	req := map[string]interface{}{"mappings": mapping}
+	if opts.Settings != nil {
+		req["settings"] = opts.Settings
+	}
+	if err := json.NewEncoder(&body).Encode(req); err != nil {
+		return fmt.Errorf("create index: %s", err)
+	}
+
+	res, err := client.Indices.Create(
+		index,
+		client.Indices.Create.WithContext(ctx),
+		client.Indices.Create.WithBody(&body),
+	)
+	return checkResponse("create index", res, err)
+}
+
+// EnsureIndex creates the index with the body, eg. the mappings and settings, unless it already exists.
+//
+// It's safe to call concurrently: the "resource_already_exists_exception" error is ignored,
+// and the existing index is left intact, even when its mapping is different.
+//
+func EnsureIndex(ctx context.Context, client *elasticsearch.Client, index string, body []byte) error {
+	opts := []func(*esapi.IndicesCreateRequest){client.Indices.Create.WithContext(ctx)}
+	if len(body) > 0 {
+		opts = append(opts, client.Indices.Create.WithBody(bytes.NewReader(body)))
+	}
+
+	res, err := client.Indices.Create(index, opts...)
+	if err := checkResponse("ensure index", res, err); err != nil {
+		if esErr, ok := err.(*ESError); ok && esErr.Type == "resource_already_exists_exception" {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// structMapping returns the mapping for the type of v, in the format of the "mappings" section.
+//
+func structMapping(v interface{}) (map[string]interface{}, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || t == timeType {
+		return nil, fmt.Errorf("cannot generate mapping for %T: not a struct", v)
+	}
+
+	props, err := structProperties(t, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"properties": props}, nil
+}
+
+// structProperties returns the mapping of the fields of t; the seen map guards against recursive types.
+//
+func structProperties(t reflect.Type, seen map[reflect.Type]bool) (map[string]interface{}, error) {
+	if seen[t] {
+		return nil, fmt.Errorf("cannot generate mapping for recursive type %s", t)
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	props := make(map[string]interface{})
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.PkgPath != "" && !f.Anonymous { // Unexported field
+			continue
+		}
+
+		tag := f.Tag.Get("es")
+		if tag == "-" {
+			continue
+		}
+
+		name := f.Name
+		if jsonTag, ok := f.Tag.Lookup("json"); ok {
+			if jsonTag == "-" {
+				continue
+			}
+			if n := strings.Split(jsonTag, ",")[0]; n != "" {
+				name = n
+			} else if f.Anonymous {
+				name = ""
+			}
+		} else if f.Anonymous {
+			name = ""
+		}
+
+		// Embedded structs without a name are flattened, like in encoding/json
+		if name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				embedded, err := structProperties(ft, seen)
+				if err != nil {
+					return nil, err
+				}
+				for k, v := range embedded {
+					if _, ok := props[k]; !ok {
+						props[k] = v
+					}
+				}
+				continue
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+			name = f.Name
+		}
+
+		m, err := fieldMapping(f, tag, seen)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			props[name] = m
+		}
+	}
+
+	return props, nil
+}
+
+// fieldMapping returns the mapping of the struct field f, or nil when the field type cannot be inferred.
+//
+func fieldMapping(f reflect.StructField, tag string, seen map[reflect.Type]bool) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+
+	for _, p := range strings.Split(tag, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid tag for field %s: %q", f.Name, p)
+		}
+		switch kv[1] {
+		case "true":
+			m[kv[0]] = true
+		case "false":
+			m[kv[0]] = false
+		default:
+			m[kv[0]] = kv[1]
+		}
+	}
+
+	t := f.Type
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Array || (t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8) {
+		t = t.Elem()
+	}
+
+	typ, _ := m["type"].(string)
+	if typ == "" || typ == "object" || typ == "nested" {
+		if t.Kind() == reflect.Struct && t != timeType {
+			props, err := structProperties(t, seen)
+			if err != nil {
+				return nil, err
+			}
+			m["properties"] = props
+			if typ == "nested" {
+				return m, nil
+			}
+			// The "object" type is the default for fields with properties
+			delete(m, "type")
+			return m, nil
+		}
+	}
+
+	if typ != "" {
+		return m, nil
+	}
+
+	typ = inferFieldType(t)
+	if typ == "" {
+		if len(m) > 0 {
+			return nil, fmt.Errorf("cannot infer type for field %s of type %s", f.Name, f.Type)
+		}
+		return nil, nil
+	}
+	m["type"] = typ
+
+	return m, nil
+}
+
+// inferFieldType returns the Elasticsearch field type for t, or an empty string.
+//
+func inferFieldType(t reflect.Type) string {
+	if t == timeType {
+		return "date"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "text"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int8:
+		return "byte"
+	case reflect.Int16, reflect.Uint8:
+		return "short"
+	case reflect.Int32, reflect.Uint16:
+		return "integer"
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return "long"
+	case reflect.Uint, reflect.Uint64:
+		return "unsigned_long"
+	case reflect.Float32:
+		return "float"
+	case reflect.Float64:
+		return "double"
+	case reflect.Slice:
+		return "binary" // []byte is encoded as a base64 string
+	case reflect.Map:
+		return "object"
+	}
+
+	return ""
+}
@@ -0,0 +1,224 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+type mappingAuthor struct {
+	Name  string `json:"name" es:"type=keyword"`
+	Email string `json:"email,omitempty" es:"index=false"`
+}
+
+type mappingMeta struct {
+	Source string `json:"source"`
+}
+
+type mappingArticle struct {
+	mappingMeta
+
+	ID        string          `json:"id" es:"type=keyword,index=false"`
+	Title     string          `json:"title" es:"analyzer=english"`
+	Views     int             `json:"views"`
+	Score     *float32        `json:"score"`
+	Draft     bool            `json:"draft"`
+	Published time.Time       `json:"published"`
+	Tags      []string        `json:"tags" es:"type=keyword"`
+	Thumbnail []byte          `json:"thumbnail"`
+	Author    mappingAuthor   `json:"author"`
+	Authors   []mappingAuthor `json:"authors" es:"type=nested"`
+	Internal  string          `json:"internal" es:"-"`
+	Ignored   string          `json:"-"`
+	Untagged  string
+	Extra     interface{} `json:"extra"`
+
+	private string
+}
+
+func TestCreateIndexFromStruct(t *testing.T) {
+	t.Run("Mapping", func(t *testing.T) {
+		m, err := structMapping(&mappingArticle{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		author := map[string]interface{}{
+			"properties": map[string]interface{}{
+				"name":  map[string]interface{}{"type": "keyword"},
+				"email": map[string]interface{}{"type": "text", "index": false},
+			},
+		}
+		nested := map[string]interface{}{"type": "nested", "properties": author["properties"]}
+
+		expected := map[string]interface{}{
+			"properties": map[string]interface{}{
+				"source":    map[string]interface{}{"type": "text"},
+				"id":        map[string]interface{}{"type": "keyword", "index": false},
+				"title":     map[string]interface{}{"type": "text", "analyzer": "english"},
+				"views":     map[string]interface{}{"type": "long"},
+				"score":     map[string]interface{}{"type": "float"},
+				"draft":     map[string]interface{}{"type": "boolean"},
+				"published": map[string]interface{}{"type": "date"},
+				"tags":      map[string]interface{}{"type": "keyword"},
+				"thumbnail": map[string]interface{}{"type": "binary"},
+				"author":    author,
+				"authors":   nested,
+				"Untagged":  map[string]interface{}{"type": "text"},
+			},
+		}
+
+		if !reflect.DeepEqual(m, expected) {
+			a, _ := json.Marshal(m)
+			b, _ := json.Marshal(expected)
+			t.Errorf("Unexpected mapping:\n got: %s\nwant: %s", a, b)
+		}
+	})
+
+	t.Run("Invalid types", func(t *testing.T) {
+		type recursive struct {
+			Children []recursive `json:"children"`
+		}
+		type badTag struct {
+			Name string `json:"name" es:"keyword"`
+		}
+
+		for _, v := range []interface{}{nil, "foo", time.Time{}, recursive{}, badTag{}} {
+			if _, err := structMapping(v); err == nil {
+				t.Errorf("Expected error for %T", v)
+			}
+		}
+	})
+
+	t.Run("Create index", func(t *testing.T) {
+		var (
+			path string
+			body map[string]interface{}
+		)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				path = r.URL.Path
+				json.NewDecoder(r.Body).Decode(&body)
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"acknowledged":true}`)),
+				}, nil
+			},
+		}})
+
+		err := CreateIndexFromStruct(context.Background(), es, "articles", mappingAuthor{}, CreateIndexOptions{
+			Settings: map[string]interface{}{"number_of_shards": 1},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if path != "/articles" {
+			t.Errorf("Unexpected path: %s", path)
+		}
+		if body["settings"].(map[string]interface{})["number_of_shards"] != float64(1) {
+			t.Errorf("Unexpected settings: %v", body["settings"])
+		}
+		props := body["mappings"].(map[string]interface{})["properties"].(map[string]interface{})
+		if props["name"].(map[string]interface{})["type"] != "keyword" {
+			t.Errorf("Unexpected mappings: %v", body["mappings"])
+		}
+	})
+
+	t.Run("Error response", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusBadRequest,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"error":{"type":"resource_already_exists_exception","reason":"index already exists"}}`)),
+				}, nil
+			},
+		}})
+
+		err := CreateIndexFromStruct(context.Background(), es, "articles", mappingAuthor{}, CreateIndexOptions{})
+		if e, ok := err.(*ESError); !ok || e.Type != "resource_already_exists_exception" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestEnsureIndex(t *testing.T) {
+	newClient := func(status int, body string, reqBody *string) *elasticsearch.Client {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				if r.Method != "PUT" || r.URL.Path != "/test" {
+					t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+				}
+				if r.Body != nil {
+					b, _ := ioutil.ReadAll(r.Body)
+					*reqBody = string(b)
+				}
+				return &http.Response{
+					StatusCode: status,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}})
+		return es
+	}
+
+	t.Run("Created", func(t *testing.T) {
+		var reqBody string
+		es := newClient(200, `{"acknowledged":true}`, &reqBody)
+
+		if err := EnsureIndex(context.Background(), es, "test", []byte(`{"mappings":{}}`)); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if reqBody != `{"mappings":{}}` {
+			t.Errorf("Unexpected request body: %s", reqBody)
+		}
+	})
+
+	t.Run("Already exists", func(t *testing.T) {
+		var reqBody string
+		es := newClient(400, `{"error":{"type":"resource_already_exists_exception","reason":"index [test] already exists"},"status":400}`, &reqBody)
+
+		if err := EnsureIndex(context.Background(), es, "test", nil); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var reqBody string
+		es := newClient(400, `{"error":{"type":"mapper_parsing_exception","reason":"bad mapping"},"status":400}`, &reqBody)
+
+		if err := EnsureIndex(context.Background(), es, "test", []byte(`{}`)); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+}
@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+// mgetChunkSize is the maximum number of IDs sent in a single multi-get request.
+//
+const mgetChunkSize = 500
+
+// mgetDoc represents a document in the Multi Get API response.
+//
+type mgetDoc struct {
+	Index  string          `json:"_index"`
+	ID     string          `json:"_id"`
+	Found  bool            `json:"found"`
+	Source json.RawMessage `json:"_source"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// MgetResult represents the result for a single ID of the Mget helper.
+//
+type MgetResult struct {
+	ID     string
+	Index  string
+	Found  bool
+	Source interface{} // The pointer to the element of the sources slice with the decoded document source, when found.
+	Err    error       // The error retrieving or decoding the document, eg. an *ESError when the index doesn't exist.
+}
+
+// Mget retrieves the documents by ID with the Multi Get API, and decodes their sources
+// into the slice pointed to by sources, which is resized to the number of IDs.
+//
+// The results, and the elements of the sources slice, follow the order of the requested IDs;
+// the source of a missing document is left as the zero value. A failure of a single document,
+// eg. when the index doesn't exist, or its source cannot be decoded, is reported in MgetResult.Err,
+// without failing the other documents; the returned error reports a failure of the whole request.
+// Large lists of IDs are split into multiple requests.
+//
+//	var articles []Article
+//	results, err := esutil.Mget(ctx, es, "articles", []string{"1", "2"}, &articles)
+//
+func Mget(ctx context.Context, client *elasticsearch.Client, index string, ids []string, sources interface{}) ([]MgetResult, error) {
+	v := reflect.ValueOf(sources)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return nil, fmt.Errorf("mget: sources must be a pointer to a slice, got %T", sources)
+	}
+	slice := reflect.MakeSlice(v.Elem().Type(), len(ids), len(ids))
+	v.Elem().Set(slice)
+
+	results := make([]MgetResult, len(ids))
+	err := mget(ctx, client, index, ids, true, func(i int, doc mgetDoc) error {
+		results[i] = MgetResult{ID: ids[i], Index: doc.Index, Found: doc.Found}
+		if doc.Error != nil {
+			results[i].Err = classifyError(&ESError{Type: doc.Error.Type, Reason: doc.Error.Reason})
+			return nil
+		}
+		if doc.Found && len(doc.Source) > 0 {
+			elem := slice.Index(i).Addr().Interface()
+			if err := json.Unmarshal(doc.Source, elem); err != nil {
+				results[i].Err = fmt.Errorf("mget: cannot decode source of document %q: %s", ids[i], err)
+				return nil
+			}
+			results[i].Source = elem
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// MgetExists returns whether the documents with the IDs exist in the index, keyed by the ID.
+//
+// It uses the Multi Get API without the document sources, which is much cheaper than
+// calling the Exists API for every ID. Large lists of IDs are split into multiple requests.
+// When a document cannot be retrieved, eg. because the index doesn't exist, it returns an error.
+//
+func MgetExists(ctx context.Context, client *elasticsearch.Client, index string, ids []string) (map[string]bool, error) {
+	out := make(map[string]bool, len(ids))
+	err := mget(ctx, client, index, ids, false, func(i int, doc mgetDoc) error {
+		if doc.Error != nil {
+			return fmt.Errorf("mget exists: document %q: %s: %s", doc.ID, doc.Error.Type, doc.Error.Reason)
+		}
+		out[ids[i]] = doc.Found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// mget retrieves the documents in chunks with the Multi Get API, and calls fn for every document
+// with the index of its ID in ids; the response preserves the order of the requested IDs.
+//
+func mget(ctx context.Context, client *elasticsearch.Client, index string, ids []string, source bool, fn func(int, mgetDoc) error) error {
+	for start := 0; start < len(ids); start += mgetChunkSize {
+		end := start + mgetChunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := ids[start:end]
+
+		var body bytes.Buffer
+		if err := json.NewEncoder(&body).Encode(map[string][]string{"ids": chunk}); err != nil {
+			return fmt.Errorf("mget: %s", err)
+		}
+
+		var out struct {
+			Docs []mgetDoc `json:"docs"`
+		}
+		res, err := client.Mget(
+			&body,
+			client.Mget.WithContext(ctx),
+			client.Mget.WithIndex(index),
+			client.Mget.WithSource(fmt.Sprint(source)),
+		)
+		if err := decodeResponse("mget", res, err, &out); err != nil {
+			return err
+		}
+		if len(out.Docs) != len(chunk) {
+			return fmt.Errorf("mget: unexpected number of documents, want=%d, got=%d", len(chunk), len(out.Docs))
+		}
+
+		for i, doc := range out.Docs {
+			if err := fn(start+i, doc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+// newMgetClient returns a client with a mock transport, which reports the documents with even IDs as found.
+//
+func newMgetClient(t *testing.T, requests *int) *elasticsearch.Client {
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+		RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+			*requests++
+			if r.URL.Path != "/test/_mget" {
+				t.Errorf("Unexpected path: %s", r.URL.Path)
+			}
+
+			var body struct {
+				IDs []string `json:"ids"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				return nil, err
+			}
+
+			var docs []string
+			for _, id := range body.IDs {
+				n, _ := strconv.Atoi(id)
+				if n%2 == 0 {
+					docs = append(docs, fmt.Sprintf(`{"_index":"test","_id":%q,"found":true,"_source":{"n":%d}}`, id, n))
+				} else {
+					docs = append(docs, fmt.Sprintf(`{"_index":"test","_id":%q,"found":false}`, id))
+				}
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"docs":[` + strings.Join(docs, ",") + `]}`)),
+			}, nil
+		},
+	}})
+	return es
+}
+
+func TestMgetExists(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var (
+			requests int
+			ids      []string
+		)
+		for i := 0; i < 1200; i++ {
+			ids = append(ids, strconv.Itoa(i))
+		}
+
+		out, err := MgetExists(context.Background(), newMgetClient(t, &requests), "test", ids)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if requests != 3 {
+			t.Errorf("Unexpected number of requests: %d", requests)
+		}
+		if len(out) != 1200 {
+			t.Errorf("Unexpected number of results: %d", len(out))
+		}
+		if !out["0"] || out["1"] || !out["1198"] || out["1199"] {
+			t.Errorf("Unexpected results: %v, %v, %v, %v", out["0"], out["1"], out["1198"], out["1199"])
+		}
+	})
+
+	t.Run("Source disabled", func(t *testing.T) {
+		var query string
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				query = r.URL.RawQuery
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"docs":[{"_index":"test","_id":"1","found":true}]}`)),
+				}, nil
+			},
+		}})
+
+		if _, err := MgetExists(context.Background(), es, "test", []string{"1"}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if query != "_source=false" {
+			t.Errorf("Unexpected query: %s", query)
+		}
+	})
+
+	t.Run("Document error", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body: ioutil.NopCloser(bytes.NewBufferString(
+						`{"docs":[{"_index":"test","_id":"1","error":{"type":"index_not_found_exception","reason":"no such index [test]"}}]}`)),
+				}, nil
+			},
+		}})
+
+		_, err := MgetExists(context.Background(), es, "test", []string{"1"})
+		if err == nil || !strings.Contains(err.Error(), "index_not_found_exception") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Error response", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusBadRequest,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"error":{"type":"action_request_validation_exception","reason":"no documents to get"}}`)),
+				}, nil
+			},
+		}})
+
+		_, err := MgetExists(context.Background(), es, "test", []string{"1"})
+		if _, ok := err.(*ESError); !ok {
+			t.Errorf("Expected *ESError, got: %T: %v", err, err)
+		}
+	})
+}
+
+func TestMget(t *testing.T) {
+	type doc struct {
+		N int `json:"n"`
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		var (
+			requests int
+			ids      []string
+			docs     []doc
+		)
+		for i := 1200; i > 0; i-- {
+			ids = append(ids, strconv.Itoa(i))
+		}
+
+		results, err := Mget(context.Background(), newMgetClient(t, &requests), "test", ids, &docs)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if requests != 3 {
+			t.Errorf("Unexpected number of requests: %d", requests)
+		}
+		if len(results) != 1200 || len(docs) != 1200 {
+			t.Fatalf("Unexpected number of results: %d, %d", len(results), len(docs))
+		}
+		for i, r := range results {
+			n, _ := strconv.Atoi(ids[i])
+			if r.ID != ids[i] || r.Found != (n%2 == 0) || r.Err != nil {
+				t.Fatalf("Unexpected result at %d: %+v", i, r)
+			}
+			if r.Found {
+				if docs[i].N != n || r.Source.(*doc).N != n {
+					t.Fatalf("Unexpected source at %d: %+v", i, docs[i])
+				}
+			} else if r.Source != nil || docs[i].N != 0 {
+				t.Fatalf("Unexpected source at %d: %+v", i, r.Source)
+			}
+		}
+	})
+
+	t.Run("Document errors", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body: ioutil.NopCloser(strings.NewReader(`{"docs":[` +
+						`{"_index":"test","_id":"1","found":true,"_source":{"n":1}},` +
+						`{"_index":"missing","_id":"2","error":{"type":"index_not_found_exception","reason":"no such index [missing]"}},` +
+						`{"_index":"test","_id":"3","found":true,"_source":{"n":"x"}}]}`)),
+				}, nil
+			},
+		}})
+
+		var docs []doc
+		results, err := Mget(context.Background(), es, "test", []string{"1", "2", "3"}, &docs)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if results[0].Err != nil || docs[0].N != 1 {
+			t.Errorf("Unexpected result: %+v", results[0])
+		}
+		if esErr, ok := results[1].Err.(*ESError); !ok || esErr.Type != "index_not_found_exception" {
+			t.Errorf("Unexpected error: %T: %v", results[1].Err, results[1].Err)
+		}
+		if results[2].Err == nil || !strings.Contains(results[2].Err.Error(), "cannot decode source") {
+			t.Errorf("Unexpected error: %v", results[2].Err)
+		}
+	})
+
+	t.Run("Invalid sources", func(t *testing.T) {
+		var docs []doc
+		if _, err := Mget(context.Background(), nil, "test", []string{"1"}, docs); err == nil {
+			t.Errorf("Expected error for a slice without a pointer")
+		}
+	})
+}
@@ -0,0 +1,83 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+// ReindexOptions configures the Reindex helper.
+//
+type ReindexOptions struct {
+	Conflicts         string        // Set to "proceed" to continue reindexing on version conflicts. Default: "abort".
+	RequestsPerSecond int           // Throttle for the operation, in sub-requests per second. Default: unthrottled.
+	Slices            interface{}   // The number of slices, or "auto". Default: 1.
+	Refresh           bool          // Refresh the destination index when the operation completes.
+	PollInterval      time.Duration // How often the task status is polled by Wait. Default: 1s.
+}
+
+// Reindex submits a reindex operation from the source to the dest index, without waiting
+// for its completion, and returns a handle to the corresponding task.
+//
+// Use the Progress method of the task to report the number of processed documents,
+// and the Wait method to block until the operation completes.
+//
+func Reindex(ctx context.Context, client *elasticsearch.Client, source, dest string, opts ReindexOptions) (*Task, error) {
+	var body bytes.Buffer
+
+	req := map[string]interface{}{
+		"source": map[string]interface{}{"index": source},
+		"dest":   map[string]interface{}{"index": dest},
+	}
+	if opts.Conflicts != "" {
+		req["conflicts"] = opts.Conflicts
+	}
+	if err := json.NewEncoder(&body).Encode(req); err != nil {
+		return nil, err
+	}
+
+	reqOpts := []func(*esapi.ReindexRequest){
+		client.Reindex.WithContext(ctx),
+		client.Reindex.WithWaitForCompletion(false),
+	}
+	if opts.RequestsPerSecond > 0 {
+		reqOpts = append(reqOpts, client.Reindex.WithRequestsPerSecond(opts.RequestsPerSecond))
+	}
+	if opts.Slices != nil {
+		reqOpts = append(reqOpts, client.Reindex.WithSlices(opts.Slices))
+	}
+	if opts.Refresh {
+		reqOpts = append(reqOpts, client.Reindex.WithRefresh(true))
+	}
+
+	var r struct {
+		Task string `json:"task"`
+	}
+	res, err := client.Reindex(&body, reqOpts...)
+	if err := decodeResponse("reindex", res, err, &r); err != nil {
+		return nil, err
+	}
+
+	return newTask(client, r.Task, opts.PollInterval), nil
+}
@@ -0,0 +1,97 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestReindex(t *testing.T) {
+	var (
+		body    map[string]interface{}
+		query   string
+		numGets int
+	)
+
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+		RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+			res := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			}
+
+			switch r.URL.Path {
+			case "/_reindex":
+				query = r.URL.RawQuery
+				json.NewDecoder(r.Body).Decode(&body)
+				res.Body = ioutil.NopCloser(strings.NewReader(`{"task":"node1:42"}`))
+			case "/_tasks/node1:42":
+				numGets++
+				if numGets < 3 {
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"completed":false,"task":{"status":{"total":100,"created":40,"updated":2}}}`))
+				} else {
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"completed":true,"task":{"status":{"total":100,"created":90,"updated":2}},"response":{"total":100,"created":98,"updated":2}}`))
+				}
+			default:
+				t.Fatalf("Unexpected request: %s %s", r.Method, r.URL)
+			}
+			return res, nil
+		},
+	}})
+
+	task, err := Reindex(context.Background(), es, "src", "dst", ReindexOptions{Conflicts: "proceed", PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if task.ID != "node1:42" {
+		t.Errorf("Unexpected task ID: %s", task.ID)
+	}
+	if !strings.Contains(query, "wait_for_completion=false") {
+		t.Errorf("Unexpected query: %s", query)
+	}
+	if body["conflicts"] != "proceed" || body["source"].(map[string]interface{})["index"] != "src" || body["dest"].(map[string]interface{})["index"] != "dst" {
+		t.Errorf("Unexpected body: %v", body)
+	}
+
+	progress, err := task.Progress(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if progress.Completed || progress.Total != 100 || progress.Created != 40 || progress.Updated != 2 {
+		t.Errorf("Unexpected progress: %+v", progress)
+	}
+
+	status, err := task.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !status.Completed || status.Created != 98 {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
@@ -0,0 +1,243 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+var (
+	defaultScrollKeepAlive = time.Minute
+
+	scrollCleanupTimeout = 5 * time.Second
+)
+
+// ScrollOptions configures the ScrollHits and ScrollChannel helpers.
+//
+type ScrollOptions struct {
+	Size       int           // The number of hits fetched per request. Default: server default (10).
+	KeepAlive  time.Duration // How long the scroll context is kept between requests. Default: 1m.
+	BufferSize int           // The capacity of the hits channel of ScrollHits. Default: 0 (unbuffered).
+}
+
+// scrollResponse represents the response of the Search and Scroll APIs for a scroll.
+//
+type scrollResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	SearchResponse
+}
+
+// ScrollHits runs the query as a scroll and sends every raw hit to the returned channel,
+// eg. when the document IDs or sort values are needed; use ScrollChannel to receive
+// the decoded documents instead.
+//
+// The channel provides backpressure: the next page is requested only once the consumer
+// has received all hits of the current page. The hit source is available, undecoded,
+// in SearchHit.Source.
+//
+// Both channels are closed when the scroll is exhausted, when a request fails,
+// or when the context is done; the error channel receives at most one error,
+// which is the context error on cancellation. The scroll is cleared on the cluster
+// in every case.
+//
+func ScrollHits(ctx context.Context, client *elasticsearch.Client, index string, query io.Reader, opts ScrollOptions) (<-chan SearchHit, <-chan error) {
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = defaultScrollKeepAlive
+	}
+
+	hitc := make(chan SearchHit, opts.BufferSize)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(hitc)
+
+		var scrollID string
+		defer func() { clearScroll(client, scrollID) }()
+
+		searchOpts := []func(*esapi.SearchRequest){
+			client.Search.WithContext(ctx),
+			client.Search.WithBody(query),
+			client.Search.WithScroll(opts.KeepAlive),
+		}
+		if index != "" {
+			searchOpts = append(searchOpts, client.Search.WithIndex(index))
+		}
+		if opts.Size > 0 {
+			searchOpts = append(searchOpts, client.Search.WithSize(opts.Size))
+		}
+
+		var page scrollResponse
+		res, err := client.Search(searchOpts...)
+		if err := decodeResponse("scroll: search", res, err, &page); err != nil {
+			errc <- scrollError(ctx, err)
+			return
+		}
+
+		for {
+			if page.ScrollID != "" {
+				scrollID = page.ScrollID
+			}
+			if len(page.Hits.Hits) == 0 {
+				return
+			}
+
+			for _, hit := range page.Hits.Hits {
+				select {
+				case hitc <- hit:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+
+			page = scrollResponse{}
+			res, err := client.Scroll(
+				client.Scroll.WithContext(ctx),
+				client.Scroll.WithScrollID(scrollID),
+				client.Scroll.WithScroll(opts.KeepAlive),
+			)
+			if err := decodeResponse("scroll: next", res, err, &page); err != nil {
+				errc <- scrollError(ctx, err)
+				return
+			}
+		}
+	}()
+
+	return hitc, errc
+}
+
+// ScrollChannel runs the query as a scroll, decodes the source of every hit into a new value
+// of the element type of docs, and sends it to docs, which must be a channel of the document type,
+// or of a pointer to it, created by the caller.
+//
+// The channel provides backpressure like in ScrollHits, and its capacity sets the number of the
+// decoded documents buffered ahead of the consumer. The docs channel and the error channel are
+// closed when the scroll is exhausted, when a request fails, when a source cannot be decoded,
+// or when the context is done; the error channel receives at most one error, which is
+// the context error on cancellation. The scroll is cleared on the cluster in every case.
+//
+//	docs := make(chan Article)
+//	errc := esutil.ScrollChannel(ctx, es, "articles", query, docs, esutil.ScrollOptions{})
+//	for doc := range docs {
+//		// ...
+//	}
+//	if err := <-errc; err != nil {
+//		// ...
+//	}
+//
+func ScrollChannel(ctx context.Context, client *elasticsearch.Client, index string, query io.Reader, docs interface{}, opts ScrollOptions) <-chan error {
+	errc := make(chan error, 1)
+
+	v := reflect.ValueOf(docs)
+	if v.Kind() != reflect.Chan || v.Type().ChanDir()&reflect.SendDir == 0 {
+		errc <- fmt.Errorf("scroll: docs must be a channel, got %T", docs)
+		close(errc)
+		return errc
+	}
+
+	elemType := v.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+
+	// Stop the scroll when decoding fails, so it's cleared on the cluster
+	ctx, cancel := context.WithCancel(ctx)
+	opts.BufferSize = 0
+	hitc, hitErrc := ScrollHits(ctx, client, index, query, opts)
+
+	go func() {
+		defer close(errc)
+		defer v.Close()
+		defer func() {
+			// Wait for the scroll to be cleared before closing the channels
+			cancel()
+			for range hitc {
+			}
+			<-hitErrc
+		}()
+
+		for hit := range hitc {
+			doc := reflect.New(elemType)
+			if len(hit.Source) > 0 {
+				if err := json.Unmarshal(hit.Source, doc.Interface()); err != nil {
+					errc <- fmt.Errorf("scroll: cannot decode source of document %q: %s", hit.ID, err)
+					return
+				}
+			}
+			if !isPtr {
+				doc = doc.Elem()
+			}
+
+			chosen, _, _ := reflect.Select([]reflect.SelectCase{
+				{Dir: reflect.SelectSend, Chan: v, Send: doc},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			})
+			if chosen == 1 {
+				errc <- ctx.Err()
+				return
+			}
+		}
+		if err := <-hitErrc; err != nil {
+			errc <- err
+		}
+	}()
+
+	return errc
+}
+
+// scrollError returns the context error when the context is done, and err otherwise.
+//
+func scrollError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// clearScroll removes the scroll context from the cluster, ignoring any errors.
+//
+// It uses a separate context bounded by scrollCleanupTimeout, so the cleanup runs
+// even when the caller's context is done.
+//
+func clearScroll(client *elasticsearch.Client, scrollID string) {
+	if scrollID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scrollCleanupTimeout)
+	defer cancel()
+
+	res, err := client.ClearScroll(
+		client.ClearScroll.WithContext(ctx),
+		client.ClearScroll.WithScrollID(scrollID),
+	)
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,291 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestScrollHits(t *testing.T) {
+	newResponse := func(body string) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	newClient := func(mu *sync.Mutex, reqs *[]string) *elasticsearch.Client {
+		var numScrolls int
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				*reqs = append(*reqs, r.Method+" "+r.URL.Path)
+
+				switch {
+				case r.Method == "DELETE":
+					return newResponse(`{"succeeded":true}`), nil
+				case strings.HasSuffix(r.URL.Path, "/_search"):
+					return newResponse(`{"_scroll_id":"abc","hits":{"hits":[{"_id":"1"},{"_id":"2"}]}}`), nil
+				default:
+					numScrolls++
+					if numScrolls < 2 {
+						return newResponse(`{"_scroll_id":"abc","hits":{"hits":[{"_id":"3"}]}}`), nil
+					}
+					return newResponse(`{"_scroll_id":"abc","hits":{"hits":[]}}`), nil
+				}
+			},
+		}})
+		return es
+	}
+
+	t.Run("Exhaust", func(t *testing.T) {
+		var (
+			mu   sync.Mutex
+			reqs []string
+		)
+		es := newClient(&mu, &reqs)
+
+		hits, errc := ScrollHits(context.Background(), es, "test", strings.NewReader(`{}`), ScrollOptions{Size: 2})
+
+		var ids []string
+		for hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if strings.Join(ids, ",") != "1,2,3" {
+			t.Errorf("Unexpected hits: %v", ids)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(reqs) != 4 || reqs[0] != "POST /test/_search" || reqs[3] != "DELETE /_search/scroll/abc" {
+			t.Errorf("Unexpected requests: %v", reqs)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		var (
+			mu   sync.Mutex
+			reqs []string
+		)
+		es := newClient(&mu, &reqs)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		hits, errc := ScrollHits(ctx, es, "test", strings.NewReader(`{}`), ScrollOptions{})
+
+		hit := <-hits
+		if hit.ID != "1" {
+			t.Errorf("Unexpected hit: %+v", hit)
+		}
+		cancel()
+
+		for range hits {
+		}
+		if err := <-errc; err != context.Canceled {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(reqs) != 2 || reqs[1] != "DELETE /_search/scroll/abc" {
+			t.Errorf("Unexpected requests: %v", reqs)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				res := newResponse(`{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`)
+				res.StatusCode = http.StatusNotFound
+				return res, nil
+			},
+		}})
+
+		hits, errc := ScrollHits(context.Background(), es, "test", strings.NewReader(`{}`), ScrollOptions{})
+
+		for range hits {
+			t.Error("Unexpected hit")
+		}
+		if err := <-errc; err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+}
+
+func TestScrollChannel(t *testing.T) {
+	type doc struct {
+		Title string `json:"title"`
+	}
+
+	newClient := func(mu *sync.Mutex, reqs *[]string, source string, endless bool) *elasticsearch.Client {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				*reqs = append(*reqs, r.Method+" "+r.URL.Path)
+
+				body := `{"_scroll_id":"abc","hits":{"hits":[]}}`
+				if endless {
+					body = `{"_scroll_id":"abc","hits":{"hits":[{"_id":"3","_source":{"title":"baz"}}]}}`
+				}
+				switch {
+				case r.Method == "DELETE":
+					body = `{"succeeded":true}`
+				case strings.HasSuffix(r.URL.Path, "/_search"):
+					body = `{"_scroll_id":"abc","hits":{"hits":[{"_id":"1","_source":{"title":"foo"}},{"_id":"2","_source":` + source + `}]}}`
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}})
+		return es
+	}
+
+	t.Run("Values", func(t *testing.T) {
+		var (
+			mu   sync.Mutex
+			reqs []string
+		)
+		es := newClient(&mu, &reqs, `{"title":"bar"}`, false)
+
+		docs := make(chan doc)
+		errc := ScrollChannel(context.Background(), es, "test", strings.NewReader(`{}`), docs, ScrollOptions{})
+
+		var titles []string
+		for d := range docs {
+			titles = append(titles, d.Title)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if strings.Join(titles, ",") != "foo,bar" {
+			t.Errorf("Unexpected documents: %v", titles)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(reqs) != 3 || reqs[2] != "DELETE /_search/scroll/abc" {
+			t.Errorf("Unexpected requests: %v", reqs)
+		}
+	})
+
+	t.Run("Pointers", func(t *testing.T) {
+		var (
+			mu   sync.Mutex
+			reqs []string
+		)
+		es := newClient(&mu, &reqs, `{"title":"bar"}`, false)
+
+		docs := make(chan *doc, 10)
+		errc := ScrollChannel(context.Background(), es, "test", strings.NewReader(`{}`), docs, ScrollOptions{})
+
+		var titles []string
+		for d := range docs {
+			titles = append(titles, d.Title)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if strings.Join(titles, ",") != "foo,bar" {
+			t.Errorf("Unexpected documents: %v", titles)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		var (
+			mu   sync.Mutex
+			reqs []string
+		)
+		es := newClient(&mu, &reqs, `{"title":"bar"}`, true)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		docs := make(chan doc)
+		errc := ScrollChannel(ctx, es, "test", strings.NewReader(`{}`), docs, ScrollOptions{})
+
+		if d := <-docs; d.Title != "foo" {
+			t.Errorf("Unexpected document: %+v", d)
+		}
+		cancel()
+
+		for range docs {
+		}
+		if err := <-errc; err != context.Canceled {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(reqs) < 2 || reqs[len(reqs)-1] != "DELETE /_search/scroll/abc" {
+			t.Errorf("Unexpected requests: %v", reqs)
+		}
+	})
+
+	t.Run("Decode error", func(t *testing.T) {
+		var (
+			mu   sync.Mutex
+			reqs []string
+		)
+		es := newClient(&mu, &reqs, `{"title":1}`, false)
+
+		docs := make(chan doc)
+		errc := ScrollChannel(context.Background(), es, "test", strings.NewReader(`{}`), docs, ScrollOptions{})
+
+		var n int
+		for range docs {
+			n++
+		}
+		err := <-errc
+		if err == nil || !strings.Contains(err.Error(), `cannot decode source of document "2"`) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if n != 1 {
+			t.Errorf("Unexpected number of documents: %d", n)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(reqs) < 2 || reqs[len(reqs)-1] != "DELETE /_search/scroll/abc" {
+			t.Errorf("Unexpected requests: %v", reqs)
+		}
+	})
+
+	t.Run("Invalid docs", func(t *testing.T) {
+		errc := ScrollChannel(context.Background(), nil, "test", strings.NewReader(`{}`), []doc{}, ScrollOptions{})
+		if err := <-errc; err == nil || !strings.Contains(err.Error(), "docs must be a channel") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,250 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+// SearchResponse represents the Elasticsearch search response.
+//
+type SearchResponse struct {
+	Took     int  `json:"took"`
+	TimedOut bool `json:"timed_out"`
+
+	Shards struct {
+		Total      int `json:"total"`
+		Successful int `json:"successful"`
+		Skipped    int `json:"skipped"`
+		Failed     int `json:"failed"`
+
+		Failures []ShardFailure `json:"failures,omitempty"`
+	} `json:"_shards"`
+
+	// The status of the clusters, present only in the responses of cross-cluster searches.
+	Clusters *SearchClusters `json:"_clusters,omitempty"`
+
+	Hits struct {
+		Total    TotalHits   `json:"total"`
+		MaxScore *float64    `json:"max_score"`
+		Hits     []SearchHit `json:"hits"`
+	} `json:"hits"`
+
+	Aggregations map[string]json.RawMessage `json:"aggregations,omitempty"`
+}
+
+// SearchClusters represents the "_clusters" section of a cross-cluster search response.
+//
+// A remote cluster configured with "skip_unavailable" is skipped when it cannot be reached,
+// and the search returns the results of the remaining clusters only.
+//
+type SearchClusters struct {
+	Total      int `json:"total"`
+	Successful int `json:"successful"`
+	Skipped    int `json:"skipped"`
+	Running    int `json:"running"`
+	Partial    int `json:"partial"`
+	Failed     int `json:"failed"`
+
+	Details map[string]SearchClusterDetails `json:"details,omitempty"` // Keyed by the cluster alias.
+}
+
+// SearchClusterDetails represents the status of a single cluster in a cross-cluster search.
+//
+type SearchClusterDetails struct {
+	Status   string `json:"status"` // One of "running", "successful", "partial", "skipped", "failed".
+	Indices  string `json:"indices"`
+	Took     int    `json:"took"`
+	TimedOut bool   `json:"timed_out"`
+}
+
+// IsPartial returns true when some of the clusters were skipped or failed,
+// or didn't return the results from all shards.
+//
+func (c *SearchClusters) IsPartial() bool {
+	return c.Skipped > 0 || c.Partial > 0 || c.Failed > 0
+}
+
+// SkippedClusters returns the aliases of the skipped clusters, when the response contains the details.
+//
+func (c *SearchClusters) SkippedClusters() []string {
+	var out []string
+	for alias, d := range c.Details {
+		if d.Status == "skipped" {
+			out = append(out, alias)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// TotalHits represents the total number of hits in the search response.
+//
+// Unless the search tracks the total hits accurately, the value is a lower bound
+// of the total number of hits, which is indicated by the "gte" relation.
+//
+type TotalHits struct {
+	Value    int64  `json:"value"`
+	Relation string `json:"relation"`
+}
+
+// IsExact returns true when the value is the exact total number of hits.
+//
+func (t TotalHits) IsExact() bool {
+	return t.Relation == "eq"
+}
+
+// UnmarshalJSON decodes the total hits, including the legacy format, where the total is a number.
+//
+func (t *TotalHits) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] != '{' && b[0] != 'n' {
+		if err := json.Unmarshal(b, &t.Value); err != nil {
+			return err
+		}
+		t.Relation = "eq"
+		return nil
+	}
+
+	type totalHits TotalHits
+	return json.Unmarshal(b, (*totalHits)(t))
+}
+
+// SearchOptions configures the Search helper.
+//
+type SearchOptions struct {
+	// Count the total number of hits accurately, instead of the default lower bound of 10,000 hits.
+	// It makes the search slower, so enable it only when the exact total is needed.
+	ExactTotalHits bool
+
+	// Optional buffer for reading the response body, which can be reused across calls to avoid
+	// allocating a new one for every search. The buffer must not be used concurrently,
+	// and its contents must not be retained after the call; see DecodeWithBuffer.
+	Buffer *bytes.Buffer
+
+	// Return only the fields matching the filter_path expressions, eg. "hits.hits._source.title",
+	// to reduce the size of the response; use FilterPathFor to derive the paths from a struct.
+	// The fields decoded into SearchResponse, except for the hit source and fields, and the error
+	// envelope are always included, so the hit IDs and sort values are kept for pagination.
+	// Default: nil, the full response is returned.
+	FilterPath []string
+}
+
+// Search executes the query and decodes the response.
+//
+// The total number of hits is returned with its relation, which tells whether
+// the value is exact ("eq") or a lower bound ("gte").
+//
+func Search(ctx context.Context, client *elasticsearch.Client, index string, query io.Reader, opts SearchOptions) (*SearchResponse, error) {
+	var result SearchResponse
+
+	searchOpts := []func(*esapi.SearchRequest){
+		client.Search.WithContext(ctx),
+		client.Search.WithBody(query),
+	}
+	if index != "" {
+		searchOpts = append(searchOpts, client.Search.WithIndex(index))
+	}
+	if opts.ExactTotalHits {
+		searchOpts = append(searchOpts, client.Search.WithTrackTotalHits(true))
+	}
+	if paths := withRequiredPaths(opts.FilterPath, requiredSearchPaths, requiredErrorPaths); paths != nil {
+		searchOpts = append(searchOpts, client.Search.WithFilterPath(paths...))
+	}
+
+	res, err := client.Search(searchOpts...)
+	if err := decodeResponseWithBuffer("search", res, err, &result, opts.Buffer); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ShardFailure represents a failure of a single shard in the search response.
+//
+type ShardFailure struct {
+	Shard  int    `json:"shard"`
+	Index  string `json:"index"`
+	Node   string `json:"node"`
+	Reason struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"reason"`
+}
+
+// PartialShardFailureError represents a successful search response with failed shards,
+// which means that the results are incomplete.
+//
+type PartialShardFailureError struct {
+	Total    int
+	Failed   int
+	Failures []ShardFailure
+}
+
+// Error returns the error as a string.
+//
+func (e *PartialShardFailureError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "search: %d of %d shards failed", e.Failed, e.Total)
+	for i, f := range e.Failures {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "[%s][%d] %s: %s", f.Index, f.Shard, f.Reason.Type, f.Reason.Reason)
+	}
+	return b.String()
+}
+
+// CheckShards returns a *PartialShardFailureError when some of the shards failed
+// to execute the search, and nil otherwise.
+//
+// Elasticsearch returns a successful response even when some of the shards fail,
+// so the hits and aggregations can be incomplete. Use the method to treat such
+// partial results as errors.
+//
+func (r *SearchResponse) CheckShards() error {
+	if r.Shards.Failed == 0 {
+		return nil
+	}
+	return &PartialShardFailureError{
+		Total:    r.Shards.Total,
+		Failed:   r.Shards.Failed,
+		Failures: r.Shards.Failures,
+	}
+}
+
+// SearchHit represents a single hit in the search response.
+//
+type SearchHit struct {
+	Index  string                 `json:"_index"`
+	ID     string                 `json:"_id"`
+	Score  *float64               `json:"_score"`
+	Source json.RawMessage        `json:"_source,omitempty"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	Sort   []interface{}          `json:"sort,omitempty"`
+}
@@ -0,0 +1,92 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EncodeSearchAfter returns an opaque token for the sort values of the last hit,
+// to be exposed as a continuation token of the "search_after" pagination.
+//
+// The values are encoded as URL-safe base64 of their JSON representation.
+// The values of type time.Time are encoded as milliseconds since the epoch,
+// which is how Elasticsearch returns the sort values of date fields.
+//
+func EncodeSearchAfter(values []interface{}) (string, error) {
+	if len(values) == 0 {
+		return "", errors.New("cannot encode search after: empty values")
+	}
+
+	vv := make([]interface{}, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case time.Time:
+			vv[i] = v.UnixNano() / int64(time.Millisecond)
+		case *time.Time:
+			if v == nil {
+				vv[i] = nil
+				break
+			}
+			vv[i] = v.UnixNano() / int64(time.Millisecond)
+		default:
+			vv[i] = v
+		}
+	}
+
+	b, err := json.Marshal(vv)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode search after: %s", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeSearchAfter returns the sort values encoded in the token by EncodeSearchAfter,
+// to be passed in the "search_after" parameter of the search request.
+//
+// The numbers are decoded as json.Number, to preserve the precision of long values,
+// eg. the dates in nanoseconds or the "_shard_doc" tiebreaker.
+//
+func DecodeSearchAfter(token string) ([]interface{}, error) {
+	if token == "" {
+		return nil, errors.New("cannot decode search after: empty token")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode search after: %s", err)
+	}
+
+	var values []interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return nil, fmt.Errorf("cannot decode search after: %s", err)
+	}
+	if len(values) == 0 {
+		return nil, errors.New("cannot decode search after: empty values")
+	}
+
+	return values, nil
+}
@@ -0,0 +1,103 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSearchAfter(t *testing.T) {
+	t.Run("Roundtrip", func(t *testing.T) {
+		values := []interface{}{
+			json.Number("1620000000000"),
+			"abc",
+			float64(1.5),
+			nil,
+			json.Number("9223372036854775807"),
+		}
+
+		token, err := EncodeSearchAfter(values)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		decoded, err := DecodeSearchAfter(token)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		b1, _ := json.Marshal(values)
+		b2, _ := json.Marshal(decoded)
+		if string(b1) != string(b2) {
+			t.Errorf("Unexpected values: want=%s, got=%s", b1, b2)
+		}
+	})
+
+	t.Run("Sort values from response", func(t *testing.T) {
+		var hit SearchHit
+		json.Unmarshal([]byte(`{"_id":"1","sort":[1620000000000,"abc",3]}`), &hit)
+
+		token, err := EncodeSearchAfter(hit.Sort)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		decoded, err := DecodeSearchAfter(token)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		b, _ := json.Marshal(map[string]interface{}{"search_after": decoded})
+		if string(b) != `{"search_after":[1620000000000,"abc",3]}` {
+			t.Errorf("Unexpected body: %s", b)
+		}
+	})
+
+	t.Run("Time", func(t *testing.T) {
+		ts := time.Date(2021, 5, 3, 0, 0, 0, 0, time.UTC)
+
+		token, err := EncodeSearchAfter([]interface{}{ts, "abc"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		decoded, _ := DecodeSearchAfter(token)
+		if decoded[0] != json.Number("1620000000000") {
+			t.Errorf("Unexpected value: %v", decoded[0])
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := EncodeSearchAfter(nil); err == nil {
+			t.Errorf("Expected error for empty values")
+		}
+		if _, err := EncodeSearchAfter([]interface{}{make(chan int)}); err == nil {
+			t.Errorf("Expected error for unsupported value")
+		}
+
+		for _, token := range []string{"", "!!!", "e30", "W10"} {
+			if _, err := DecodeSearchAfter(token); err == nil {
+				t.Errorf("Expected error for token %q", token)
+			}
+		}
+	})
+}
@@ -0,0 +1,193 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func newSearchClient(body string, query *string) *elasticsearch.Client {
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+		RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+			*query = r.URL.RawQuery
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+			}, nil
+		},
+	}})
+	return es
+}
+
+func TestSearch(t *testing.T) {
+	t.Run("Lower bound", func(t *testing.T) {
+		var query string
+		es := newSearchClient(`{"took":3,"hits":{"total":{"value":10000,"relation":"gte"},"hits":[{"_id":"1"}]}}`, &query)
+
+		res, err := Search(context.Background(), es, "test", strings.NewReader(`{}`), SearchOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if query != "" {
+			t.Errorf("Unexpected query string: %s", query)
+		}
+		if res.Hits.Total.Value != 10000 || res.Hits.Total.Relation != "gte" || res.Hits.Total.IsExact() {
+			t.Errorf("Unexpected total: %+v", res.Hits.Total)
+		}
+		if len(res.Hits.Hits) != 1 {
+			t.Errorf("Unexpected hits: %+v", res.Hits.Hits)
+		}
+	})
+
+	t.Run("Exact total hits", func(t *testing.T) {
+		var query string
+		es := newSearchClient(`{"took":3,"hits":{"total":{"value":12345,"relation":"eq"},"hits":[]}}`, &query)
+
+		res, err := Search(context.Background(), es, "test", strings.NewReader(`{}`), SearchOptions{ExactTotalHits: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if query != "track_total_hits=true" {
+			t.Errorf("Unexpected query string: %s", query)
+		}
+		if res.Hits.Total.Value != 12345 || !res.Hits.Total.IsExact() {
+			t.Errorf("Unexpected total: %+v", res.Hits.Total)
+		}
+	})
+
+	t.Run("With buffer", func(t *testing.T) {
+		var query string
+		es := newSearchClient(`{"took":3,"hits":{"total":{"value":1,"relation":"eq"},"hits":[{"_id":"1","_source":{"title":"foo"}}]}}`, &query)
+
+		buf := bytes.NewBufferString("GARBAGE")
+		res, err := Search(context.Background(), es, "test", strings.NewReader(`{}`), SearchOptions{Buffer: buf})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		// Overwrite the buffer, to make sure the results don't reference its memory
+		source := string(res.Hits.Hits[0].Source)
+		buf.Reset()
+		buf.WriteString(strings.Repeat("X", 200))
+
+		if res.Hits.Hits[0].ID != "1" || string(res.Hits.Hits[0].Source) != source || source != `{"title":"foo"}` {
+			t.Errorf("Unexpected hits: %+v", res.Hits.Hits)
+		}
+	})
+
+	t.Run("Cross-cluster search", func(t *testing.T) {
+		var query string
+		es := newSearchClient(`{"took":3,"_clusters":{"total":3,"successful":2,"skipped":1,"details":{"(local)":{"status":"successful","indices":"logs"},"eu":{"status":"successful","indices":"logs"},"us":{"status":"skipped","indices":"logs"}}},"hits":{"total":{"value":1,"relation":"eq"},"hits":[]}}`, &query)
+
+		res, err := Search(context.Background(), es, "logs,eu:logs,us:logs", strings.NewReader(`{}`), SearchOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if res.Clusters == nil {
+			t.Fatalf("Expected the clusters section")
+		}
+		if res.Clusters.Total != 3 || res.Clusters.Successful != 2 || res.Clusters.Skipped != 1 || !res.Clusters.IsPartial() {
+			t.Errorf("Unexpected clusters: %+v", res.Clusters)
+		}
+		if skipped := res.Clusters.SkippedClusters(); len(skipped) != 1 || skipped[0] != "us" {
+			t.Errorf("Unexpected skipped clusters: %q", skipped)
+		}
+	})
+
+	t.Run("Local search", func(t *testing.T) {
+		var query string
+		es := newSearchClient(`{"took":3,"hits":{"total":{"value":1,"relation":"eq"},"hits":[]}}`, &query)
+
+		res, err := Search(context.Background(), es, "logs", strings.NewReader(`{}`), SearchOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.Clusters != nil {
+			t.Errorf("Unexpected clusters: %+v", res.Clusters)
+		}
+	})
+
+	t.Run("Legacy total format", func(t *testing.T) {
+		var r SearchResponse
+		if err := json.Unmarshal([]byte(`{"hits":{"total":42}}`), &r); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if r.Hits.Total.Value != 42 || !r.Hits.Total.IsExact() {
+			t.Errorf("Unexpected total: %+v", r.Hits.Total)
+		}
+	})
+}
+
+func TestSearchResponseCheckShards(t *testing.T) {
+	t.Run("Successful", func(t *testing.T) {
+		var r SearchResponse
+		json.Unmarshal([]byte(`{"_shards":{"total":2,"successful":2,"skipped":0,"failed":0}}`), &r)
+
+		if err := r.CheckShards(); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Partial failure", func(t *testing.T) {
+		var r SearchResponse
+		body := `{"_shards":{"total":3,"successful":1,"skipped":0,"failed":2,"failures":[
+			{"shard":0,"index":"test","node":"n1","reason":{"type":"query_shard_exception","reason":"failed to create query"}},
+			{"shard":2,"index":"test","node":"n2","reason":{"type":"node_disconnected_exception","reason":"node disconnected"}}
+		]},"hits":{"total":{"value":1,"relation":"eq"},"hits":[{"_id":"1"}]}}`
+		if err := json.Unmarshal([]byte(body), &r); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		err := r.CheckShards()
+		if err == nil {
+			t.Fatalf("Expected error, got: %v", err)
+		}
+
+		shardErr, ok := err.(*PartialShardFailureError)
+		if !ok {
+			t.Fatalf("Expected *PartialShardFailureError, got: %T", err)
+		}
+		if shardErr.Total != 3 || shardErr.Failed != 2 || len(shardErr.Failures) != 2 {
+			t.Errorf("Unexpected error fields: %+v", shardErr)
+		}
+		if shardErr.Failures[1].Node != "n2" || shardErr.Failures[1].Reason.Type != "node_disconnected_exception" {
+			t.Errorf("Unexpected failure: %+v", shardErr.Failures[1])
+		}
+
+		expected := "search: 2 of 3 shards failed: " +
+			"[test][0] query_shard_exception: failed to create query; " +
+			"[test][2] node_disconnected_exception: node disconnected"
+		if err.Error() != expected {
+			t.Errorf("Unexpected error message:\nwant: %s\ngot:  %s", expected, err.Error())
+		}
+	})
+}
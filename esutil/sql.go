@@ -0,0 +1,212 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+var sqlCleanupTimeout = 5 * time.Second
+
+// SQLOptions configures the SQLQuery helper.
+//
+type SQLOptions struct {
+	FetchSize int           // The number of rows fetched per request. Default: server default (1000).
+	Params    []interface{} // The values of the "?" placeholders in the query.
+	TimeZone  string        // The time zone of the date values, eg. "Europe/Prague". Default: "Z".
+}
+
+// SQLColumn represents the metadata of a column in the SQL query response.
+//
+type SQLColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // The Elasticsearch type of the column, eg. "keyword", "long" or "datetime".
+}
+
+// sqlResponse represents the response of the SQL Query API in the JSON format.
+//
+type sqlResponse struct {
+	Columns []SQLColumn     `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+	Cursor  string          `json:"cursor"`
+}
+
+// SQLRows is an iterator over the rows of an SQL query, which fetches the next page
+// with the cursor when the rows of the current page are exhausted.
+//
+//	rows, err := esutil.SQLQuery(ctx, es, "SELECT name, age FROM people", esutil.SQLOptions{})
+//	if err != nil {
+//	    // ...
+//	}
+//	defer rows.Close()
+//	for rows.Next() {
+//	    fmt.Println(rows.Row())
+//	}
+//	if err := rows.Err(); err != nil {
+//	    // ...
+//	}
+//
+// The SQLRows is not safe for concurrent use.
+//
+type SQLRows struct {
+	ctx    context.Context
+	client *elasticsearch.Client
+
+	columns []SQLColumn
+	rows    [][]interface{}
+	row     []interface{}
+	cursor  string
+	err     error
+}
+
+// SQLQuery executes the SQL query and returns an iterator over the result rows.
+//
+// The values of the rows are decoded from JSON, so the numbers are float64,
+// and the dates are strings. The caller has to call Close when it stops iterating
+// before the rows are exhausted, so the cursor is closed on the cluster.
+//
+// The context applies to the first request and to the requests for the next pages;
+// when the context is done, the iteration stops, and Err returns the context error.
+//
+func SQLQuery(ctx context.Context, client *elasticsearch.Client, query string, opts SQLOptions) (*SQLRows, error) {
+	req := map[string]interface{}{"query": query}
+	if opts.FetchSize > 0 {
+		req["fetch_size"] = opts.FetchSize
+	}
+	if len(opts.Params) > 0 {
+		req["params"] = opts.Params
+	}
+	if opts.TimeZone != "" {
+		req["time_zone"] = opts.TimeZone
+	}
+
+	var page sqlResponse
+	if err := sqlRequest(ctx, client, "sql: query", req, &page); err != nil {
+		return nil, err
+	}
+
+	return &SQLRows{
+		ctx:     ctx,
+		client:  client,
+		columns: page.Columns,
+		rows:    page.Rows,
+		cursor:  page.Cursor,
+	}, nil
+}
+
+// Columns returns the metadata of the columns, in the order of the row values.
+//
+func (r *SQLRows) Columns() []SQLColumn {
+	return r.columns
+}
+
+// Next advances the iterator to the next row, fetching the next page when needed.
+// It returns false when the rows are exhausted, or when an error occurs.
+//
+func (r *SQLRows) Next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	for len(r.rows) == 0 {
+		if r.cursor == "" {
+			r.row = nil
+			return false
+		}
+
+		if err := r.ctx.Err(); err != nil {
+			r.fail(err)
+			return false
+		}
+
+		var page sqlResponse
+		if err := sqlRequest(r.ctx, r.client, "sql: next", map[string]interface{}{"cursor": r.cursor}, &page); err != nil {
+			r.fail(scrollError(r.ctx, err))
+			return false
+		}
+		r.rows, r.cursor = page.Rows, page.Cursor
+	}
+
+	r.row, r.rows = r.rows[0], r.rows[1:]
+	return true
+}
+
+// Row returns the values of the current row.
+//
+func (r *SQLRows) Row() []interface{} {
+	return r.row
+}
+
+// Err returns the error which stopped the iteration, if any.
+//
+func (r *SQLRows) Err() error {
+	return r.err
+}
+
+// Close stops the iteration and closes the cursor on the cluster, when it's still open.
+//
+// It uses a separate context bounded by a timeout, so the cursor is closed
+// even when the caller's context is done.
+//
+func (r *SQLRows) Close() error {
+	r.rows, r.row = nil, nil
+	if r.cursor == "" {
+		return nil
+	}
+
+	cursor := r.cursor
+	r.cursor = ""
+
+	ctx, cancel := context.WithTimeout(context.Background(), sqlCleanupTimeout)
+	defer cancel()
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(map[string]interface{}{"cursor": cursor}); err != nil {
+		return err
+	}
+	res, err := r.client.SQL.ClearCursor(&body, r.client.SQL.ClearCursor.WithContext(ctx))
+	return checkResponse("sql: close", res, err)
+}
+
+// fail records the error and closes the cursor.
+//
+func (r *SQLRows) fail(err error) {
+	r.err = err
+	r.Close()
+}
+
+// sqlRequest sends the request body to the SQL Query API and decodes the response into out.
+//
+func sqlRequest(ctx context.Context, client *elasticsearch.Client, op string, req map[string]interface{}, out *sqlResponse) error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(req); err != nil {
+		return err
+	}
+
+	res, err := client.SQL.Query(
+		&body,
+		client.SQL.Query.WithContext(ctx),
+		client.SQL.Query.WithFormat("json"),
+	)
+	return decodeResponse(op, res, err, out)
+}
@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestSQLQuery(t *testing.T) {
+	newResponse := func(status int, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	newClient := func(reqs *[]string, pages ...string) *elasticsearch.Client {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				b, _ := ioutil.ReadAll(r.Body)
+				*reqs = append(*reqs, r.URL.Path+" "+strings.TrimSpace(string(b)))
+
+				if r.URL.Path == "/_sql/close" {
+					return newResponse(http.StatusOK, `{"succeeded":true}`), nil
+				}
+				if len(pages) == 0 {
+					return newResponse(http.StatusBadRequest, `{"error":{"type":"illegal_argument_exception","reason":"unknown cursor"}}`), nil
+				}
+				page := pages[0]
+				pages = pages[1:]
+				return newResponse(http.StatusOK, page), nil
+			},
+		}})
+		return es
+	}
+
+	t.Run("Exhaust", func(t *testing.T) {
+		var reqs []string
+		es := newClient(&reqs,
+			`{"columns":[{"name":"name","type":"keyword"},{"name":"age","type":"long"}],"rows":[["a",1],["b",2]],"cursor":"abc"}`,
+			`{"rows":[["c",3]],"cursor":"def"}`,
+			`{"rows":[]}`,
+		)
+
+		rows, err := SQLQuery(context.Background(), es, "SELECT name, age FROM people", SQLOptions{FetchSize: 2})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		defer rows.Close()
+
+		if cols := rows.Columns(); len(cols) != 2 || cols[0].Name != "name" || cols[1].Type != "long" {
+			t.Errorf("Unexpected columns: %+v", cols)
+		}
+
+		var names []string
+		for rows.Next() {
+			names = append(names, rows.Row()[0].(string))
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if strings.Join(names, ",") != "a,b,c" {
+			t.Errorf("Unexpected rows: %v", names)
+		}
+
+		if len(reqs) != 3 {
+			t.Fatalf("Unexpected requests: %v", reqs)
+		}
+		if reqs[0] != `/_sql {"fetch_size":2,"query":"SELECT name, age FROM people"}` {
+			t.Errorf("Unexpected request: %s", reqs[0])
+		}
+		if reqs[1] != `/_sql {"cursor":"abc"}` || reqs[2] != `/_sql {"cursor":"def"}` {
+			t.Errorf("Unexpected requests: %v", reqs)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		var reqs []string
+		es := newClient(&reqs,
+			`{"columns":[{"name":"name","type":"keyword"}],"rows":[["a"],["b"]],"cursor":"abc"}`,
+		)
+
+		rows, err := SQLQuery(context.Background(), es, "SELECT name FROM people", SQLOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		rows.Next()
+		if err := rows.Close(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if rows.Next() {
+			t.Errorf("Unexpected row after close")
+		}
+
+		if len(reqs) != 2 || reqs[1] != `/_sql/close {"cursor":"abc"}` {
+			t.Errorf("Unexpected requests: %v", reqs)
+		}
+	})
+
+	t.Run("Context canceled", func(t *testing.T) {
+		var reqs []string
+		es := newClient(&reqs,
+			`{"columns":[{"name":"name","type":"keyword"}],"rows":[["a"]],"cursor":"abc"}`,
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		rows, err := SQLQuery(ctx, es, "SELECT name FROM people", SQLOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !rows.Next() {
+			t.Fatalf("Expected a row")
+		}
+		cancel()
+
+		if rows.Next() {
+			t.Errorf("Unexpected row after cancellation")
+		}
+		if rows.Err() != context.Canceled {
+			t.Errorf("Unexpected error: %v", rows.Err())
+		}
+		if len(reqs) != 2 || reqs[1] != `/_sql/close {"cursor":"abc"}` {
+			t.Errorf("Unexpected requests: %v", reqs)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var reqs []string
+		es := newClient(&reqs)
+
+		if _, err := SQLQuery(context.Background(), es, "SELECT", SQLOptions{}); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+}
@@ -0,0 +1,238 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+var (
+	defaultTaskPollInterval = time.Second
+	taskCancelTimeout       = 5 * time.Second
+
+	// ErrTaskNotFound is returned by Task.Progress when the task doesn't exist on the cluster.
+	ErrTaskNotFound = errors.New("task: not found")
+)
+
+// Task represents a long-running task on the cluster, such as a reindex operation.
+//
+type Task struct {
+	ID string
+
+	client       *elasticsearch.Client
+	pollInterval time.Duration
+}
+
+// TaskStatus represents the status of a task operating on documents,
+// such as reindex, update by query or delete by query.
+//
+type TaskStatus struct {
+	Completed bool `json:"-"`
+
+	Total            int64 `json:"total"`
+	Created          int64 `json:"created"`
+	Updated          int64 `json:"updated"`
+	Deleted          int64 `json:"deleted"`
+	Batches          int64 `json:"batches"`
+	VersionConflicts int64 `json:"version_conflicts"`
+	Noops            int64 `json:"noops"`
+
+	Retries struct {
+		Bulk   int64 `json:"bulk"`
+		Search int64 `json:"search"`
+	} `json:"retries"`
+
+	ThrottledMillis      int64   `json:"throttled_millis"`
+	RequestsPerSecond    float64 `json:"requests_per_second"`
+	ThrottledUntilMillis int64   `json:"throttled_until_millis"`
+
+	Failures []json.RawMessage `json:"failures,omitempty"`
+}
+
+// taskResponse represents the Tasks Get API response.
+//
+type taskResponse struct {
+	Completed bool `json:"completed"`
+	Task      struct {
+		Status TaskStatus `json:"status"`
+	} `json:"task"`
+	Response *TaskStatus `json:"response"`
+	Error    *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// newTask creates a new task handle.
+//
+func newTask(client *elasticsearch.Client, id string, pollInterval time.Duration) *Task {
+	if pollInterval == 0 {
+		pollInterval = defaultTaskPollInterval
+	}
+	return &Task{ID: id, client: client, pollInterval: pollInterval}
+}
+
+// Progress returns the current status of the task.
+//
+// When the task doesn't exist on the cluster, ErrTaskNotFound is returned.
+//
+func (t *Task) Progress(ctx context.Context) (TaskStatus, error) {
+	var tr taskResponse
+
+	res, err := t.client.Tasks.Get(t.ID, t.client.Tasks.Get.WithContext(ctx))
+	if err == nil && res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return TaskStatus{}, ErrTaskNotFound
+	}
+	if err := decodeResponse("task: get", res, err, &tr); err != nil {
+		return TaskStatus{}, err
+	}
+
+	status := tr.Task.Status
+	if tr.Response != nil {
+		status = *tr.Response
+	}
+	status.Completed = tr.Completed
+
+	if tr.Error != nil {
+		return status, fmt.Errorf("task: %s: %s", tr.Error.Type, tr.Error.Reason)
+	}
+
+	return status, nil
+}
+
+// Wait blocks until the task is completed, polling its status periodically.
+//
+// When the context is done before the task completes, the task is cancelled on the cluster,
+// and the last known status is returned together with the context error.
+//
+// When the task disappears from the cluster after it has been observed, because its result
+// hasn't been stored, it is considered completed, and the last known status is returned.
+// When the task is not found on the first poll, ErrTaskNotFound is returned.
+//
+func (t *Task) Wait(ctx context.Context) (TaskStatus, error) {
+	return t.wait(ctx, nil)
+}
+
+// wait implements Wait, calling onProgress, when not nil, with the status of each poll
+// of the task in progress.
+//
+func (t *Task) wait(ctx context.Context, onProgress func(TaskStatus)) (TaskStatus, error) {
+	var (
+		last   TaskStatus
+		polled bool
+	)
+
+	stop := CancelableTask(ctx, t.client, t.ID)
+	defer stop()
+
+	for {
+		status, err := t.Progress(ctx)
+		if err == ErrTaskNotFound && polled {
+			last.Completed = true
+			return last, nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return last, ctx.Err()
+			}
+			return status, err
+		}
+
+		if status.Completed {
+			return status, nil
+		}
+		last = status
+		polled = true
+		if onProgress != nil {
+			onProgress(status)
+		}
+
+		timer := time.NewTimer(t.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return last, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Cancel cancels the task on the cluster.
+//
+func (t *Task) Cancel(ctx context.Context) error {
+	res, err := t.client.Tasks.Cancel(
+		t.client.Tasks.Cancel.WithContext(ctx),
+		t.client.Tasks.Cancel.WithTaskID(t.ID),
+	)
+	return checkResponse("task: cancel", res, err)
+}
+
+// CancelableTask cancels the task with taskID on the cluster when the context is done,
+// so the server-side operation doesn't keep running after the caller has given up.
+//
+// Call the returned stop function once the task is no longer of interest, eg. when it completes;
+// when the context is already done by then, the task is cancelled before stop returns.
+// The stop function returns true when the task hasn't been cancelled.
+//
+// The cancellation uses a separate context bounded by taskCancelTimeout, so it runs
+// even though the caller's context is done, and its errors are ignored.
+//
+//	stop := esutil.CancelableTask(ctx, es, taskID)
+//	defer stop()
+//
+func CancelableTask(ctx context.Context, client *elasticsearch.Client, taskID string) (stop func() bool) {
+	var (
+		stopc     = make(chan struct{})
+		done      = make(chan struct{})
+		cancelled bool
+		once      sync.Once
+	)
+
+	go func() {
+		defer close(done)
+
+		select {
+		case <-ctx.Done():
+		case <-stopc:
+			if ctx.Err() == nil {
+				return
+			}
+		}
+
+		cctx, cancel := context.WithTimeout(context.Background(), taskCancelTimeout)
+		defer cancel()
+
+		newTask(client, taskID, 0).Cancel(cctx) // errcheck exclude
+		cancelled = true
+	}()
+
+	return func() bool {
+		once.Do(func() { close(stopc) })
+		<-done
+		return !cancelled
+	}
+}
@@ -0,0 +1,217 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestTask(t *testing.T) {
+	t.Run("Cancel on context done", func(t *testing.T) {
+		var (
+			mu        sync.Mutex
+			cancelled bool
+		)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				res := &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}
+				if r.URL.Path == "/_tasks/node1:42/_cancel" {
+					mu.Lock()
+					cancelled = true
+					mu.Unlock()
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"nodes":{}}`))
+					return res, nil
+				}
+				res.Body = ioutil.NopCloser(strings.NewReader(`{"completed":false,"task":{"status":{"total":100}}}`))
+				return res, nil
+			},
+		}})
+
+		task := newTask(es, "node1:42", 10*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		status, err := task.Wait(ctx)
+		if err != context.DeadlineExceeded {
+			t.Fatalf("Expected context.DeadlineExceeded, got: %v", err)
+		}
+		if status.Total != 100 {
+			t.Errorf("Expected the last known status, got: %+v", status)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !cancelled {
+			t.Errorf("Expected the task to be cancelled")
+		}
+	})
+
+	t.Run("Bounded cancel on unresponsive cluster", func(t *testing.T) {
+		defer func(d time.Duration) { taskCancelTimeout = d }(taskCancelTimeout)
+		taskCancelTimeout = 50 * time.Millisecond
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			},
+		}})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if _, err := newTask(es, "node1:42", 0).Wait(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("Expected context.DeadlineExceeded, got: %v", err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("Expected the cancel to be bounded, took: %s", d)
+		}
+	})
+
+	t.Run("Task not found", func(t *testing.T) {
+		var numGets int
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				numGets++
+				res := &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"completed":false,"task":{"status":{"total":100,"created":50}}}`)),
+				}
+				if strings.HasSuffix(r.URL.Path, "/missing") || numGets > 1 {
+					res.StatusCode = http.StatusNotFound
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"error":{"type":"resource_not_found_exception","reason":"task not found"}}`))
+				}
+				return res, nil
+			},
+		}})
+
+		if _, err := newTask(es, "missing", time.Millisecond).Wait(context.Background()); err != ErrTaskNotFound {
+			t.Fatalf("Expected ErrTaskNotFound, got: %v", err)
+		}
+
+		numGets = 0
+		status, err := newTask(es, "node1:42", time.Millisecond).Wait(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !status.Completed || status.Created != 50 {
+			t.Errorf("Expected the last known status marked as completed, got: %+v", status)
+		}
+	})
+
+	t.Run("Task error", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"completed":true,"error":{"type":"index_not_found_exception","reason":"no such index [src]"}}`)),
+				}, nil
+			},
+		}})
+
+		status, err := newTask(es, "node1:42", 0).Wait(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "index_not_found_exception") {
+			t.Fatalf("Expected task error, got: %v", err)
+		}
+		if !status.Completed {
+			t.Errorf("Expected the task to be completed")
+		}
+	})
+}
+
+func TestCancelableTask(t *testing.T) {
+	newClient := func(mu *sync.Mutex, paths *[]string) *elasticsearch.Client {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				mu.Lock()
+				*paths = append(*paths, r.URL.Path)
+				mu.Unlock()
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"nodes":{}}`)),
+				}, nil
+			},
+		}})
+		return es
+	}
+
+	t.Run("Cancel on context done", func(t *testing.T) {
+		var (
+			mu    sync.Mutex
+			paths []string
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stop := CancelableTask(ctx, newClient(&mu, &paths), "node1:42")
+		cancel()
+
+		if stop() {
+			t.Errorf("Expected the task to be cancelled")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(paths) != 1 || paths[0] != "/_tasks/node1:42/_cancel" {
+			t.Errorf("Unexpected requests: %v", paths)
+		}
+	})
+
+	t.Run("Stop before context done", func(t *testing.T) {
+		var (
+			mu    sync.Mutex
+			paths []string
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stop := CancelableTask(ctx, newClient(&mu, &paths), "node1:42")
+		if !stop() {
+			t.Errorf("Expected the task to not be cancelled")
+		}
+		if !stop() {
+			t.Errorf("Expected repeated stop to report the same result")
+		}
+		cancel()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(paths) != 0 {
+			t.Errorf("Unexpected requests: %v", paths)
+		}
+	})
+}
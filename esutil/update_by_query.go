@@ -0,0 +1,104 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+	"github.com/Tritura/go-elasticsearch/v8/esapi"
+)
+
+// UpdateByQueryOptions configures the UpdateByQuery helper.
+//
+type UpdateByQueryOptions struct {
+	Conflicts         string           // Set to "proceed" to continue updating on version conflicts. Default: "abort".
+	RequestsPerSecond int              // Throttle for the operation, in sub-requests per second. Default: unthrottled.
+	Slices            interface{}      // The number of slices, or "auto". Default: 1.
+	Refresh           bool             // Refresh the affected indices when the operation completes.
+	PollInterval      time.Duration    // How often the task status is polled. Default: 1s.
+	OnProgress        func(TaskStatus) // Called with the status of the task after each poll, while it's running.
+}
+
+// Script represents a script for the update by query operation.
+//
+type Script struct {
+	Source string                 `json:"source"`
+	Lang   string                 `json:"lang,omitempty"` // Default: "painless".
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// UpdateByQuery submits an update by query operation, which runs the script for the documents
+// in index matching the query, and waits for its completion by polling the corresponding task.
+//
+// The query is the JSON of the query clause, eg. `{"term":{"user":"kimchy"}}`;
+// when it is nil, all documents in index are updated.
+//
+// The returned status contains the number of updated documents, the number of version conflicts,
+// and the throttling information. When the context is done before the operation completes,
+// the task is cancelled on the cluster.
+//
+func UpdateByQuery(ctx context.Context, client *elasticsearch.Client, index string, query io.Reader, script Script, opts UpdateByQueryOptions) (TaskStatus, error) {
+	var body bytes.Buffer
+
+	req := map[string]interface{}{"script": script}
+	if query != nil {
+		q, err := ioutil.ReadAll(query)
+		if err != nil {
+			return TaskStatus{}, fmt.Errorf("update by query: cannot read query: %s", err)
+		}
+		req["query"] = json.RawMessage(q)
+	}
+	if err := json.NewEncoder(&body).Encode(req); err != nil {
+		return TaskStatus{}, fmt.Errorf("update by query: %s", err)
+	}
+
+	reqOpts := []func(*esapi.UpdateByQueryRequest){
+		client.UpdateByQuery.WithContext(ctx),
+		client.UpdateByQuery.WithBody(&body),
+		client.UpdateByQuery.WithWaitForCompletion(false),
+	}
+	if opts.Conflicts != "" {
+		reqOpts = append(reqOpts, client.UpdateByQuery.WithConflicts(opts.Conflicts))
+	}
+	if opts.RequestsPerSecond > 0 {
+		reqOpts = append(reqOpts, client.UpdateByQuery.WithRequestsPerSecond(opts.RequestsPerSecond))
+	}
+	if opts.Slices != nil {
+		reqOpts = append(reqOpts, client.UpdateByQuery.WithSlices(opts.Slices))
+	}
+	if opts.Refresh {
+		reqOpts = append(reqOpts, client.UpdateByQuery.WithRefresh(true))
+	}
+
+	var r struct {
+		Task string `json:"task"`
+	}
+	res, err := client.UpdateByQuery([]string{index}, reqOpts...)
+	if err := decodeResponse("update by query", res, err, &r); err != nil {
+		return TaskStatus{}, err
+	}
+
+	return newTask(client, r.Task, opts.PollInterval).wait(ctx, opts.OnProgress)
+}
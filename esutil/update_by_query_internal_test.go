@@ -0,0 +1,130 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tritura/go-elasticsearch/v8"
+)
+
+func TestUpdateByQuery(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var (
+			query    string
+			body     map[string]interface{}
+			numGets  int
+			progress []TaskStatus
+		)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				res := &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}
+
+				switch r.URL.Path {
+				case "/test/_update_by_query":
+					query = r.URL.RawQuery
+					json.NewDecoder(r.Body).Decode(&body)
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"task":"node1:9"}`))
+				case "/_tasks/node1:9":
+					numGets++
+					if numGets < 3 {
+						res.Body = ioutil.NopCloser(strings.NewReader(`{"completed":false,"task":{"status":{"total":10,"updated":4}}}`))
+					} else {
+						res.Body = ioutil.NopCloser(strings.NewReader(`{"completed":true,"response":{"total":10,"updated":7,"version_conflicts":3}}`))
+					}
+				default:
+					t.Fatalf("Unexpected request: %s %s", r.Method, r.URL)
+				}
+				return res, nil
+			},
+		}})
+
+		status, err := UpdateByQuery(
+			context.Background(), es, "test",
+			strings.NewReader(`{"term":{"user":"foo"}}`),
+			Script{Source: "ctx._source.count++", Params: map[string]interface{}{"n": 1}},
+			UpdateByQueryOptions{
+				Conflicts:    "proceed",
+				PollInterval: time.Millisecond,
+				OnProgress:   func(s TaskStatus) { progress = append(progress, s) },
+			},
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if !strings.Contains(query, "conflicts=proceed") || !strings.Contains(query, "wait_for_completion=false") {
+			t.Errorf("Unexpected query: %s", query)
+		}
+		if body["query"].(map[string]interface{})["term"] == nil {
+			t.Errorf("Unexpected body: %v", body)
+		}
+		if script := body["script"].(map[string]interface{}); script["source"] != "ctx._source.count++" || script["lang"] != nil {
+			t.Errorf("Unexpected script: %v", script)
+		}
+		if !status.Completed || status.Updated != 7 || status.VersionConflicts != 3 {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+		if len(progress) != 2 || progress[0].Updated != 4 {
+			t.Errorf("Unexpected progress: %+v", progress)
+		}
+	})
+
+	t.Run("Without query", func(t *testing.T) {
+		var body map[string]interface{}
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+				res := &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}
+				if r.URL.Path == "/test/_update_by_query" {
+					json.NewDecoder(r.Body).Decode(&body)
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"task":"node1:9"}`))
+				} else {
+					res.Body = ioutil.NopCloser(strings.NewReader(`{"completed":true,"response":{"total":1,"updated":1}}`))
+				}
+				return res, nil
+			},
+		}})
+
+		status, err := UpdateByQuery(context.Background(), es, "test", nil, Script{Source: "ctx._source.n = 1"}, UpdateByQueryOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if _, ok := body["query"]; ok {
+			t.Errorf("Unexpected body: %v", body)
+		}
+		if status.Updated != 1 {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+	})
+}
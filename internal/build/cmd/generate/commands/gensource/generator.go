@@ -627,7 +627,7 @@ func (r ` + g.Endpoint.MethodWithNamespace() + `Request) Do(ctx context.Context,
 							pathContent.WriteString(`	path.WriteString(strconv.Itoa(*r.` + p + `))` + "\n")
 						case "string":
 							pathGrow.WriteString(`len(r.` + p + `) + `)
-							pathContent.WriteString(`	path.WriteString(r.` + p + `)` + "\n")
+							pathContent.WriteString(`	path.WriteString(` + pathPartValue(p) + `)` + "\n")
 						case "list":
 							pathGrow.WriteString(`len(strings.Join(r.` + p + `, ",")) + `)
 							pathContent.WriteString(`	path.WriteString(strings.Join(r.` + p + `, ","))` + "\n")
@@ -653,7 +653,7 @@ func (r ` + g.Endpoint.MethodWithNamespace() + `Request) Do(ctx context.Context,
 								pathGrow.WriteString(`1 + len(r.` + p + `) + `)
 								pathContent.WriteString(`	if r.` + p + ` != "" {` + "\n")
 								pathContent.WriteString(`		path.WriteString("/")` + "\n")
-								pathContent.WriteString(`		path.WriteString(r.` + p + `)` + "\n")
+								pathContent.WriteString(`		path.WriteString(` + pathPartValue(p) + `)` + "\n")
 								pathContent.WriteString(`	}` + "\n")
 							case "list":
 								pathGrow.WriteString(`1 + len(strings.Join(r.` + p + `, ",")) + `)
@@ -687,7 +687,7 @@ func (r ` + g.Endpoint.MethodWithNamespace() + `Request) Do(ctx context.Context,
 							switch a.Type {
 							case "string":
 								pathGrow.WriteString(`len(r.` + p + `)`)
-								pathContent.WriteString(`	path.WriteString(r.` + p + `)` + "\n")
+								pathContent.WriteString(`	path.WriteString(` + pathPartValue(p) + `)` + "\n")
 							case "list":
 								pathGrow.WriteString(`len(strings.Join(r.` + p + `, ","))`)
 								pathContent.WriteString(`	path.WriteString(strings.Join(r.` + p + `, ","))` + "\n")
@@ -852,3 +852,13 @@ func (r ` + g.Endpoint.MethodWithNamespace() + `Request) Do(ctx context.Context,
 
 	g.w("}\n")
 }
+
+// pathPartValue returns the expression for the value of the URL part,
+// escaping the document IDs, which can contain any characters.
+//
+func pathPartValue(p string) string {
+	if p == "DocumentID" {
+		return `escapePathSegment(r.` + p + `)`
+	}
+	return `r.` + p
+}